@@ -20,6 +20,11 @@ const _ = grpc.SupportPackageIsVersion7
 
 const (
 	MetricsService_UpdateBatchMetrics_FullMethodName = "/metrics.MetricsService/UpdateBatchMetrics"
+	MetricsService_StreamMetrics_FullMethodName      = "/metrics.MetricsService/StreamMetrics"
+	MetricsService_GetMetric_FullMethodName          = "/metrics.MetricsService/GetMetric"
+	MetricsService_ListMetrics_FullMethodName        = "/metrics.MetricsService/ListMetrics"
+	MetricsService_QueryRange_FullMethodName         = "/metrics.MetricsService/QueryRange"
+	MetricsService_Watch_FullMethodName              = "/metrics.MetricsService/Watch"
 )
 
 // MetricsServiceClient is the client API for MetricsService service.
@@ -27,6 +32,15 @@ const (
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type MetricsServiceClient interface {
 	UpdateBatchMetrics(ctx context.Context, in *UpdateBatchMetricsRequest, opts ...grpc.CallOption) (*UpdateBatchMetricsResponse, error)
+	// StreamMetrics takes a long-lived stream of metric batches. The server
+	// acknowledges every batch with its sequence number once it is stored.
+	StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (MetricsService_StreamMetricsClient, error)
+	GetMetric(ctx context.Context, in *GetMetricRequest, opts ...grpc.CallOption) (*Metric, error)
+	ListMetrics(ctx context.Context, in *ListMetricsRequest, opts ...grpc.CallOption) (*ListMetricsResponse, error)
+	// QueryRange returns the recorded values of a metric, oldest first.
+	QueryRange(ctx context.Context, in *QueryRangeRequest, opts ...grpc.CallOption) (*QueryRangeResponse, error)
+	// Watch sends every metric update selected by the request as it is stored.
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (MetricsService_WatchClient, error)
 }
 
 type metricsServiceClient struct {
@@ -46,11 +60,110 @@ func (c *metricsServiceClient) UpdateBatchMetrics(ctx context.Context, in *Updat
 	return out, nil
 }
 
+func (c *metricsServiceClient) StreamMetrics(ctx context.Context, opts ...grpc.CallOption) (MetricsService_StreamMetricsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricsService_ServiceDesc.Streams[0], MetricsService_StreamMetrics_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricsServiceStreamMetricsClient{stream}
+	return x, nil
+}
+
+type MetricsService_StreamMetricsClient interface {
+	Send(*MetricsBatch) error
+	Recv() (*BatchAck, error)
+	grpc.ClientStream
+}
+
+type metricsServiceStreamMetricsClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricsServiceStreamMetricsClient) Send(m *MetricsBatch) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *metricsServiceStreamMetricsClient) Recv() (*BatchAck, error) {
+	m := new(BatchAck)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *metricsServiceClient) GetMetric(ctx context.Context, in *GetMetricRequest, opts ...grpc.CallOption) (*Metric, error) {
+	out := new(Metric)
+	err := c.cc.Invoke(ctx, MetricsService_GetMetric_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricsServiceClient) ListMetrics(ctx context.Context, in *ListMetricsRequest, opts ...grpc.CallOption) (*ListMetricsResponse, error) {
+	out := new(ListMetricsResponse)
+	err := c.cc.Invoke(ctx, MetricsService_ListMetrics_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricsServiceClient) QueryRange(ctx context.Context, in *QueryRangeRequest, opts ...grpc.CallOption) (*QueryRangeResponse, error) {
+	out := new(QueryRangeResponse)
+	err := c.cc.Invoke(ctx, MetricsService_QueryRange_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *metricsServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (MetricsService_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MetricsService_ServiceDesc.Streams[1], MetricsService_Watch_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &metricsServiceWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MetricsService_WatchClient interface {
+	Recv() (*MetricUpdate, error)
+	grpc.ClientStream
+}
+
+type metricsServiceWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *metricsServiceWatchClient) Recv() (*MetricUpdate, error) {
+	m := new(MetricUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // MetricsServiceServer is the server API for MetricsService service.
 // All implementations must embed UnimplementedMetricsServiceServer
 // for forward compatibility
 type MetricsServiceServer interface {
 	UpdateBatchMetrics(context.Context, *UpdateBatchMetricsRequest) (*UpdateBatchMetricsResponse, error)
+	// StreamMetrics takes a long-lived stream of metric batches. The server
+	// acknowledges every batch with its sequence number once it is stored.
+	StreamMetrics(MetricsService_StreamMetricsServer) error
+	GetMetric(context.Context, *GetMetricRequest) (*Metric, error)
+	ListMetrics(context.Context, *ListMetricsRequest) (*ListMetricsResponse, error)
+	// QueryRange returns the recorded values of a metric, oldest first.
+	QueryRange(context.Context, *QueryRangeRequest) (*QueryRangeResponse, error)
+	// Watch sends every metric update selected by the request as it is stored.
+	Watch(*WatchRequest, MetricsService_WatchServer) error
 	mustEmbedUnimplementedMetricsServiceServer()
 }
 
@@ -61,6 +174,21 @@ type UnimplementedMetricsServiceServer struct {
 func (UnimplementedMetricsServiceServer) UpdateBatchMetrics(context.Context, *UpdateBatchMetricsRequest) (*UpdateBatchMetricsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateBatchMetrics not implemented")
 }
+func (UnimplementedMetricsServiceServer) StreamMetrics(MetricsService_StreamMetricsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamMetrics not implemented")
+}
+func (UnimplementedMetricsServiceServer) GetMetric(context.Context, *GetMetricRequest) (*Metric, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMetric not implemented")
+}
+func (UnimplementedMetricsServiceServer) ListMetrics(context.Context, *ListMetricsRequest) (*ListMetricsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMetrics not implemented")
+}
+func (UnimplementedMetricsServiceServer) QueryRange(context.Context, *QueryRangeRequest) (*QueryRangeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QueryRange not implemented")
+}
+func (UnimplementedMetricsServiceServer) Watch(*WatchRequest, MetricsService_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
 func (UnimplementedMetricsServiceServer) mustEmbedUnimplementedMetricsServiceServer() {}
 
 // UnsafeMetricsServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -92,6 +220,107 @@ func _MetricsService_UpdateBatchMetrics_Handler(srv interface{}, ctx context.Con
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MetricsService_StreamMetrics_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MetricsServiceServer).StreamMetrics(&metricsServiceStreamMetricsServer{stream})
+}
+
+type MetricsService_StreamMetricsServer interface {
+	Send(*BatchAck) error
+	Recv() (*MetricsBatch, error)
+	grpc.ServerStream
+}
+
+type metricsServiceStreamMetricsServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricsServiceStreamMetricsServer) Send(m *BatchAck) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *metricsServiceStreamMetricsServer) Recv() (*MetricsBatch, error) {
+	m := new(MetricsBatch)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _MetricsService_GetMetric_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMetricRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsServiceServer).GetMetric(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MetricsService_GetMetric_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsServiceServer).GetMetric(ctx, req.(*GetMetricRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricsService_ListMetrics_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMetricsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsServiceServer).ListMetrics(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MetricsService_ListMetrics_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsServiceServer).ListMetrics(ctx, req.(*ListMetricsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricsService_QueryRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MetricsServiceServer).QueryRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MetricsService_QueryRange_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MetricsServiceServer).QueryRange(ctx, req.(*QueryRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MetricsService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MetricsServiceServer).Watch(m, &metricsServiceWatchServer{stream})
+}
+
+type MetricsService_WatchServer interface {
+	Send(*MetricUpdate) error
+	grpc.ServerStream
+}
+
+type metricsServiceWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *metricsServiceWatchServer) Send(m *MetricUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // MetricsService_ServiceDesc is the grpc.ServiceDesc for MetricsService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -103,7 +332,31 @@ var MetricsService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateBatchMetrics",
 			Handler:    _MetricsService_UpdateBatchMetrics_Handler,
 		},
+		{
+			MethodName: "GetMetric",
+			Handler:    _MetricsService_GetMetric_Handler,
+		},
+		{
+			MethodName: "ListMetrics",
+			Handler:    _MetricsService_ListMetrics_Handler,
+		},
+		{
+			MethodName: "QueryRange",
+			Handler:    _MetricsService_QueryRange_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamMetrics",
+			Handler:       _MetricsService_StreamMetrics_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _MetricsService_Watch_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "api/proto/server.proto",
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/elina-chertova/metrics-alerting.git/internal/asymencrypt"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"io"
 	"log"
 	"os"
 	"os/signal"
@@ -41,6 +42,11 @@ func main() {
 		Config:   config.NewAgent(),
 	}
 
+	if w.Config.BatchContentType != "" {
+		w.Settings.IsSendBatch = true
+		w.Settings.FlagContentType = w.Config.BatchContentType
+	}
+
 	cryptoKeyPing(w.Config.CryptoKey)
 
 	logger.LogInit("info")
@@ -58,6 +64,7 @@ func main() {
 		defer conn.Close()
 		client := pb.NewMetricsServiceClient(conn)
 		metricsSender = &senderGRPC.SenderGRPC{Client: client, Config: w.Config}
+		w.Collected = make(chan struct{}, 1)
 
 	} else {
 		metricsSender = &senderRest.SenderRest{Settings: w.Settings, Config: w.Config}
@@ -68,6 +75,11 @@ func main() {
 	waitForSignals(stopCh)
 
 	wg.Wait()
+	if closer, ok := metricsSender.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("failed to close sender: %v", err)
+		}
+	}
 }
 
 func startWorkers(
@@ -80,11 +92,18 @@ func startWorkers(
 	numSendWorkers := w.Config.RateLimit
 	numExtractWorkers := w.Config.RateLimit
 
-	wg.Add(numSendWorkers + 2*numExtractWorkers)
-
-	for sw := 0; sw < numSendWorkers; sw++ {
-		go sender.SendMetricsWorker(storage, w, metricsSender, stopCh, wg)
+	if w.Collected != nil {
+		// The metrics are pushed after every collection, not on the report interval.
+		wg.Add(1)
+		go sender.PushMetricsWorker(storage, w, metricsSender, stopCh, wg)
+	} else {
+		wg.Add(numSendWorkers)
+		for sw := 0; sw < numSendWorkers; sw++ {
+			go sender.SendMetricsWorker(storage, w, metricsSender, stopCh, wg)
+		}
 	}
+	wg.Add(2 * numExtractWorkers)
+
 	for ew := 0; ew < numExtractWorkers; ew++ {
 		go st.ExtractMetricsWorker(storage, w, stopCh, wg)
 		go st.ExtractOSMetricsWorker(storage, w, stopCh, wg)
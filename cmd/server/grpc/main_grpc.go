@@ -8,11 +8,12 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/elina-chertova/metrics-alerting.git/api/otlp"
 	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
 	"github.com/elina-chertova/metrics-alerting.git/internal/config"
 	handler "github.com/elina-chertova/metrics-alerting.git/internal/handlers/grpc"
-	"github.com/elina-chertova/metrics-alerting.git/internal/storage/db"
-	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/backends"
+	"github.com/elina-chertova/metrics-alerting.git/internal/tenant"
 
 	"google.golang.org/grpc"
 )
@@ -29,7 +30,23 @@ func main() {
 	fmt.Printf("Build commit:%s\n", buildCommit)
 
 	serverConfig := config.NewServer()
-	h := buildStorageGRPC(serverConfig)
+	stop := make(chan struct{})
+	var opts []grpc.ServerOption
+	var h *handler.Handler
+	if serverConfig.MultiTenant {
+		registry, _, err := backends.BuildTenants(serverConfig, stop)
+		if err != nil {
+			log.Fatalf("failed to build storage: %v", err)
+		}
+		opts = append(
+			opts,
+			grpc.UnaryInterceptor(tenant.UnaryServerInterceptor(registry)),
+			grpc.StreamInterceptor(tenant.StreamServerInterceptor(registry)),
+		)
+		h = handler.NewTenantHandler(registry)
+	} else {
+		h = buildStorageGRPC(serverConfig, stop)
+	}
 
 	lis, err := net.Listen("tcp", ":"+serverConfig.GRPCPort)
 
@@ -37,7 +54,7 @@ func main() {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(opts...)
 	pb.RegisterMetricsServiceServer(
 		grpcServer,
 		&handler.Server{
@@ -46,6 +63,7 @@ func main() {
 			CryptoKey: serverConfig.CryptoKey,
 		},
 	)
+	otlp.RegisterMetricsServiceServer(grpcServer, &handler.OTLPServer{Handler: h})
 
 	go func() {
 		quit := make(chan os.Signal, 1)
@@ -55,6 +73,7 @@ func main() {
 		log.Println("Shutting down server...")
 
 		grpcServer.GracefulStop()
+		close(stop)
 
 		log.Println("Server exiting")
 	}()
@@ -65,12 +84,10 @@ func main() {
 	}
 }
 
-func buildStorageGRPC(config *config.Server) *handler.Handler {
-	if config.DatabaseDSN != "" {
-		connection := db.Connect(config.DatabaseDSN)
-		return handler.NewHandler(connection)
-	} else {
-		s := filememory.NewMemStorage(true, config)
-		return handler.NewHandler(s)
+func buildStorageGRPC(config *config.Server, stop <-chan struct{}) *handler.Handler {
+	st, _, err := backends.Build(config, stop)
+	if err != nil {
+		log.Fatalf("failed to build storage: %v", err)
 	}
+	return handler.NewHandler(st)
 }
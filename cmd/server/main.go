@@ -2,21 +2,32 @@ package main
 
 import (
 	"fmt"
+	_ "github.com/elina-chertova/metrics-alerting.git/docs"
+	"github.com/elina-chertova/metrics-alerting.git/internal/agents"
 	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/graphite"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
 	"github.com/elina-chertova/metrics-alerting.git/internal/handlers/rest"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/compression"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/security"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/subnet"
-	"github.com/elina-chertova/metrics-alerting.git/internal/storage/db"
-	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/rules"
+	"github.com/elina-chertova/metrics-alerting.git/internal/statsd"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/backends"
+	"github.com/elina-chertova/metrics-alerting.git/internal/tenant"
 	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
 	"golang.org/x/net/context"
 	"log"
+	"net"
 	"net/http"
 	"net/http/pprof"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
@@ -27,6 +38,10 @@ var (
 	buildCommit  = "N/A"
 )
 
+// @title        Metrics Alerting API
+// @version      1.0
+// @description  Collects runtime metrics from agents and serves them to dashboards and alerting.
+// @BasePath     /
 func main() {
 	fmt.Printf("Build version:%s\n", buildVersion)
 	fmt.Printf("Build date:%s\n", buildDate)
@@ -49,35 +64,37 @@ func run() error {
 		router.Use(subnet.TrustedIPMiddleware(serverConfig.TrustedSubnet))
 	}
 
-	h := buildStorage(serverConfig, router)
+	stop := make(chan struct{})
+	h, ingest := buildStorage(serverConfig, router, stop)
+	agentRegistry := agents.NewRegistry()
+	router.Use(agents.Middleware(agentRegistry))
+
+	var listeners sync.WaitGroup
+	if serverConfig.StatsdAddress != "" {
+		if err := startStatsd(serverConfig, ingest, stop, &listeners); err != nil {
+			return err
+		}
+	}
+	if serverConfig.GraphiteAddress != "" {
+		if err := startGraphite(serverConfig, ingest, stop, &listeners); err != nil {
+			return err
+		}
+	}
+	if serverConfig.RulesFile != "" {
+		if err := startRules(serverConfig, ingest, stop, &listeners); err != nil {
+			return err
+		}
+	}
 
 	RegisterPprofRoutes(router)
-	router.POST(
-		"/updates/",
-		security.HashCheckMiddleware(serverConfig.SecretKey),
-		h.UpdateBatchMetrics(serverConfig.SecretKey, serverConfig.CryptoKey),
-	)
-	router.POST(
-		"/update/",
-		security.HashCheckMiddleware(serverConfig.SecretKey),
-		h.MetricsJSONHandler(serverConfig.SecretKey, serverConfig.CryptoKey),
-	)
-	router.POST("/update/:metricType/:metricName/:metricValue", h.MetricsTextPlainHandler())
-	router.GET(
-		"/value/:metricType/:metricName",
-		h.GetMetricsTextPlainHandler(serverConfig.SecretKey),
-	)
-	router.POST("/value/", h.GetMetricsJSONHandler(serverConfig.SecretKey))
-	router.GET("/", h.MetricsListHandler())
-	router.NoRoute(
-		func(c *gin.Context) {
-			c.String(http.StatusNotFound, "Page not found")
-		},
-	)
+	shutdown := make(chan struct{})
+	registerRoutes(router, h, serverConfig, agentRegistry, shutdown)
 	srv := &http.Server{
 		Addr:    serverConfig.FlagAddress,
 		Handler: router,
 	}
+	// Streams never become idle, so end them for Shutdown to complete.
+	srv.RegisterOnShutdown(func() { close(shutdown) })
 
 	//if err := router.Run(serverConfig.FlagAddress); err != nil {
 	//	return err
@@ -103,21 +120,218 @@ func run() error {
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("listen: %s\n", err)
 	}
+	close(stop)
+	listeners.Wait()
 
 	return nil
 }
 
-func buildStorage(config *config.Server, router *gin.Engine) *rest.Handler {
-	if config.DatabaseDSN != "" {
-		connection := db.Connect(config.DatabaseDSN)
-		database := rest.NewHandlerDB(connection)
-		router.GET("/ping", database.PingDB())
-		return rest.NewHandler(connection)
+// startStatsd receives StatsD metrics over UDP and TCP on the configured
+// address and flushes them into st until stop is closed.
+func startStatsd(
+	cfg *config.Server,
+	st serviceInterface.MetricsStorage,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+) error {
+	udp, err := net.ListenPacket("udp", cfg.StatsdAddress)
+	if err != nil {
+		return err
+	}
+	tcp, err := net.Listen("tcp", cfg.StatsdAddress)
+	if err != nil {
+		udp.Close()
+		return err
+	}
+	server := statsd.NewServer(st)
+	go server.ServeUDP(udp)
+	go server.ServeTCP(tcp)
 
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		server.Run(time.Duration(cfg.StatsdFlush)*time.Second, stop)
+		udp.Close()
+		tcp.Close()
+	}()
+	log.Printf("StatsD listening on %s", cfg.StatsdAddress)
+	return nil
+}
+
+// startGraphite receives Graphite plaintext metrics over TCP on the
+// configured address and writes them into st until stop is closed.
+func startGraphite(
+	cfg *config.Server,
+	st serviceInterface.MetricsStorage,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+) error {
+	if cfg.GraphiteFlush <= 0 {
+		return fmt.Errorf("invalid graphite flush interval: %d", cfg.GraphiteFlush)
+	}
+	templates, err := graphite.ParseTemplates(strings.Split(cfg.GraphiteTemplates, ";"))
+	if err != nil {
+		return err
+	}
+	tcp, err := net.Listen("tcp", cfg.GraphiteAddress)
+	if err != nil {
+		return err
+	}
+	server := graphite.NewServer(st, templates)
+	go server.ServeTCP(tcp)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		server.Run(time.Duration(cfg.GraphiteFlush)*time.Second, stop)
+		tcp.Close()
+	}()
+	log.Printf("Graphite listening on %s", cfg.GraphiteAddress)
+	return nil
+}
+
+// startRules evaluates the recording rules of the configured file over st
+// until stop is closed.
+func startRules(
+	cfg *config.Server,
+	st serviceInterface.MetricsStorage,
+	stop <-chan struct{},
+	wg *sync.WaitGroup,
+) error {
+	if cfg.RulesInterval <= 0 {
+		return fmt.Errorf("invalid rules interval: %d", cfg.RulesInterval)
+	}
+	loaded, err := rules.Load(cfg.RulesFile)
+	if err != nil {
+		return err
+	}
+	engine, err := rules.New(st, loaded)
+	if err != nil {
+		return err
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		engine.Run(time.Duration(cfg.RulesInterval)*time.Second, stop)
+	}()
+	log.Printf("Evaluating %d recording rules from %s", len(loaded), cfg.RulesFile)
+	return nil
+}
+
+// registerRoutes registers the REST API, the dashboard and the API
+// documentation on router. Streams end when shutdown is closed. Keep the
+// annotations of the handlers in sync, TestRoutesDocumented fails otherwise.
+func registerRoutes(
+	router *gin.Engine,
+	h *rest.Handler,
+	config *config.Server,
+	agentRegistry *agents.Registry,
+	shutdown <-chan struct{},
+) {
+	// The dashboard assets, the agents page and the documentation do not
+	// depend on the tenant, so only the routes serving metrics resolve it.
+	data := router.Group("/", h.Middleware()...)
+	data.POST(
+		"/updates/",
+		security.HashCheckMiddleware(config.SecretKey),
+		h.UpdateBatchMetrics(config.SecretKey, config.CryptoKey),
+	)
+	data.POST(
+		"/update/",
+		security.HashCheckMiddleware(config.SecretKey),
+		h.MetricsJSONHandler(config.SecretKey, config.CryptoKey),
+	)
+	data.POST("/update/:metricType/:metricName/:metricValue", h.MetricsTextPlainHandler())
+	data.GET(
+		"/value/:metricType/:metricName",
+		h.GetMetricsTextPlainHandler(config.SecretKey),
+	)
+	data.POST("/value/", h.GetMetricsJSONHandler(config.SecretKey))
+	data.GET("/", h.MetricsListHandler())
+	data.GET("/ui/metric", h.MetricPageHandler())
+	data.GET("/metrics", h.PrometheusHandler())
+	data.POST("/api/v2/write", h.InfluxWriteHandler(config.InfluxIntegerCounters))
+	data.GET("/metadata/", h.ListMetadataHandler())
+	data.GET("/metadata/:metricName", h.GetMetadataHandler())
+	data.PUT(
+		"/metadata/:metricName",
+		security.HashCheckMiddleware(config.SecretKey),
+		h.SetMetadataHandler(),
+	)
+
+	api := data.Group(rest.APIPrefix)
+	api.GET("/metrics", h.APIListHandler())
+	api.POST("/metrics", h.APIUpdateHandler())
+	api.GET("/metrics/:type/*id", h.APIMetricHandler())
+	api.GET("/metadata", h.APIListMetadataHandler())
+	api.GET("/metadata/:name", h.APIGetMetadataHandler())
+	api.PUT("/metadata/:name", h.APISetMetadataHandler())
+	api.POST("/bulk", h.BulkHandler())
+	api.GET("/export", h.ExportHandler())
+	api.POST("/import", h.ImportHandler())
+	api.GET("/stream", h.StreamHandler(shutdown))
+	api.GET("/render", h.RenderHandler())
+	api.GET("/query", h.QueryHandler())
+	api.POST("/write", h.RemoteWriteHandler())
+
+	router.GET("/ui/agents", rest.AgentsHandler(agentRegistry))
+	router.StaticFS("/ui/static", rest.DashboardAssets())
+	router.GET("/swagger/*any", swaggerHandler())
+	router.NoRoute(rest.NotFoundHandler())
+}
+
+// swaggerHandler serves the Swagger UI of the API documentation in the docs
+// package, redirecting /swagger/ to its index page.
+func swaggerHandler() gin.HandlerFunc {
+	ui := ginSwagger.WrapHandler(swaggerFiles.Handler)
+	return func(c *gin.Context) {
+		if c.Param("any") == "/" {
+			c.Redirect(http.StatusMovedPermanently, "/swagger/index.html")
+			return
+		}
+		ui(c)
+	}
+}
+
+// pinger is implemented by backends that expose a health check route.
+type pinger interface {
+	PingDB() gin.HandlerFunc
+}
+
+// buildStorage creates the REST handler and returns the storage of the
+// default tenant for the ingest listeners. Background checks of the storage
+// stop when stop is closed.
+func buildStorage(
+	config *config.Server,
+	router *gin.Engine,
+	stop <-chan struct{},
+) (*rest.Handler, serviceInterface.MetricsStorage) {
+	var (
+		h       *rest.Handler
+		ingest  serviceInterface.MetricsStorage
+		backend serviceInterface.MetricsStorage
+	)
+	if config.MultiTenant {
+		registry, st, err := backends.BuildTenants(config, stop)
+		if err != nil {
+			log.Fatalf("failed to build storage: %v", err)
+		}
+		h, backend = rest.NewTenantHandler(registry), st
+		if ingest, err = registry.Storage(tenant.Default); err != nil {
+			log.Fatalf("failed to build storage: %v", err)
+		}
 	} else {
-		s := filememory.NewMemStorage(true, config)
-		return rest.NewHandler(s)
+		st, b, err := backends.Build(config, stop)
+		if err != nil {
+			log.Fatalf("failed to build storage: %v", err)
+		}
+		h, ingest, backend = rest.NewHandler(st), st, b
+	}
+	if p, ok := backend.(pinger); ok {
+		router.GET("/ping", rest.NewHandlerDB(p).PingDB())
 	}
+	return h, ingest
 }
 
 func RegisterPprofRoutes(router *gin.Engine) {
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/docs"
+	"github.com/elina-chertova/metrics-alerting.git/internal/agents"
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/handlers/rest"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/security"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// undocumented are the routes that are not part of the API.
+var undocumented = map[string]bool{
+	"GET /swagger/{any}":         true,
+	"GET /ui/static/{filepath}":  true,
+	"HEAD /ui/static/{filepath}": true,
+}
+
+// conditional are the documented routes registered only in some setups.
+var conditional = []string{"GET /ping"}
+
+var routeParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+func testRouter() *gin.Engine {
+	return testRouterWith(&config.Server{})
+}
+
+func testRouterWith(cfg *config.Server) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	st := metadata.NewStorage(filememory.NewMemStorage(false, nil), metadata.NewRegistry(""))
+	registerRoutes(router, rest.NewHandler(st), cfg, agents.NewRegistry(), make(chan struct{}))
+	return router
+}
+
+func TestRoutesDocumented(t *testing.T) {
+	var routes []string
+	for _, r := range testRouter().Routes() {
+		route := r.Method + " " + routeParam.ReplaceAllString(r.Path, "{$1}")
+		if !undocumented[route] {
+			routes = append(routes, route)
+		}
+	}
+	routes = append(routes, conditional...)
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(docs.SwaggerInfo.ReadDoc()), &spec))
+	var documented []string
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	assert.ElementsMatch(
+		t, routes, documented,
+		"routes and the API documentation differ, update the annotations and run swag init "+
+			"-d cmd/server,internal/handlers/rest,internal/formatter,internal/metadata -g main.go -o docs",
+	)
+}
+
+func TestSetMetadataHashChecked(t *testing.T) {
+	router := testRouterWith(&config.Server{SecretKey: "key"})
+	body := `{"unit":"bytes"}`
+	tests := []struct {
+		name     string
+		hash     string
+		expected int
+	}{
+		{name: "Valid Hash", hash: security.Hash(body, []byte("key")), expected: http.StatusOK},
+		{name: "Invalid Hash", hash: security.Hash(body, []byte("other")), expected: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodPut, "/metadata/Alloc", strings.NewReader(body))
+				req.Header.Set("HashSHA256", tt.hash)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tt.expected, w.Code)
+			},
+		)
+	}
+}
+
+func TestSwaggerUI(t *testing.T) {
+	router := testRouter()
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{path: "/swagger/", expected: http.StatusMovedPermanently},
+		{path: "/swagger/index.html", expected: http.StatusOK},
+		{path: "/swagger/doc.json", expected: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.path, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				assert.Equal(t, tt.expected, w.Code)
+			},
+		)
+	}
+}
+
+func TestTenantResolvedOnDataRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registry := tenant.NewRegistry(
+		func(id string) (serviceInterface.MetricsStorage, error) {
+			return filememory.NewMemStorage(false, nil), nil
+		}, tenant.Limits{}, map[string]string{"secret": "team-a"},
+	)
+	registerRoutes(
+		router, rest.NewTenantHandler(registry), &config.Server{}, agents.NewRegistry(), make(chan struct{}),
+	)
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{path: "/api/v1/metrics", expected: http.StatusUnauthorized},
+		{path: "/value/gauge/Alloc", expected: http.StatusUnauthorized},
+		{path: "/ui/metric?type=gauge&id=Alloc", expected: http.StatusUnauthorized},
+		{path: "/ui/agents", expected: http.StatusOK},
+		{path: "/ui/static/dashboard.css", expected: http.StatusOK},
+		{path: "/swagger/index.html", expected: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.path, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				assert.Equal(t, tt.expected, w.Code)
+			},
+		)
+	}
+}
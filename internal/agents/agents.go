@@ -0,0 +1,82 @@
+// Package agents keeps track of the agents that report metrics to the
+// server, so that operators can see which of them are still sending.
+package agents
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Agent is a client that has written metrics to the server. It is
+// identified by the X-Real-IP header the agent sends, or the address of the
+// connection when the header is missing.
+type Agent struct {
+	Address   string    `json:"address"`
+	UserAgent string    `json:"user_agent"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Requests  int64     `json:"requests"`
+}
+
+// Registry records the agents seen by Middleware.
+type Registry struct {
+	mu     sync.Mutex
+	agents map[string]*Agent
+	now    func() time.Time
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{agents: make(map[string]*Agent), now: time.Now}
+}
+
+// Seen records a request of the agent at address.
+func (r *Registry) Seen(address, userAgent string) {
+	now := r.now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	a, ok := r.agents[address]
+	if !ok {
+		a = &Agent{Address: address, FirstSeen: now}
+		r.agents[address] = a
+	}
+	a.UserAgent = userAgent
+	a.LastSeen = now
+	a.Requests++
+}
+
+// List returns the recorded agents ordered by address.
+func (r *Registry) List() []Agent {
+	r.mu.Lock()
+	list := make([]Agent, 0, len(r.agents))
+	for _, a := range r.agents {
+		list = append(list, *a)
+	}
+	r.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Address < list[j].Address })
+	return list
+}
+
+// Middleware records the sender of every successful POST or PUT request,
+// which are the requests that write metrics or their metadata.
+func Middleware(r *Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		method := c.Request.Method
+		if method != http.MethodPost && method != http.MethodPut {
+			return
+		}
+		if c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+		address := c.GetHeader("X-Real-IP")
+		if address == "" {
+			address = c.ClientIP()
+		}
+		r.Seen(address, c.Request.UserAgent())
+	}
+}
@@ -0,0 +1,67 @@
+package agents
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewRegistry()
+	now := time.Unix(1700000000, 0)
+	r.now = func() time.Time { return now }
+
+	router := gin.New()
+	router.Use(Middleware(r))
+	router.POST("/update/", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/fail/", func(c *gin.Context) { c.Status(http.StatusBadRequest) })
+	router.GET("/value/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		realIP string
+	}{
+		{name: "Real IP", method: http.MethodPost, path: "/update/", realIP: "10.0.0.2"},
+		{name: "Real IP Again", method: http.MethodPost, path: "/update/", realIP: "10.0.0.2"},
+		{name: "Remote Address", method: http.MethodPost, path: "/update/"},
+		{name: "Failed Request", method: http.MethodPost, path: "/fail/", realIP: "10.0.0.3"},
+		{name: "Read Request", method: http.MethodGet, path: "/value/", realIP: "10.0.0.4"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(tt.method, tt.path, nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				req.Header.Set("User-Agent", "agent/1.0")
+				if tt.realIP != "" {
+					req.Header.Set("X-Real-IP", tt.realIP)
+				}
+				router.ServeHTTP(httptest.NewRecorder(), req)
+				now = now.Add(time.Second)
+			},
+		)
+	}
+
+	list := r.List()
+	require.Len(t, list, 2)
+	assert.Equal(
+		t,
+		Agent{
+			Address:   "10.0.0.2",
+			UserAgent: "agent/1.0",
+			FirstSeen: time.Unix(1700000000, 0),
+			LastSeen:  time.Unix(1700000001, 0),
+			Requests:  2,
+		},
+		list[0],
+	)
+	assert.Equal(t, "192.0.2.1", list[1].Address)
+}
@@ -0,0 +1,255 @@
+// Package chart renders the history of a metric as an SVG line chart, so
+// that it can be embedded where no JavaScript runs, such as emails and
+// wiki pages.
+package chart
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+)
+
+const (
+	DefaultWidth  = 600
+	DefaultHeight = 200
+
+	// yTicks is the number of value labels on the vertical axis.
+	yTicks = 5
+	// fontSize is the size of all labels in pixels.
+	fontSize = 11
+)
+
+// Options configures a chart. From and To are the time range of the
+// horizontal axis. A sparkline is the line only, without title, axes and
+// labels.
+type Options struct {
+	Title     string
+	Width     int
+	Height    int
+	From      time.Time
+	To        time.Time
+	Sparkline bool
+}
+
+// margins of the plot area inside the chart.
+type margins struct {
+	top, right, bottom, left float64
+}
+
+// SVG writes the chart of samples, which are ordered oldest first, to w.
+func SVG(w io.Writer, samples []history.Sample, opts Options) error {
+	if opts.Width <= 0 {
+		opts.Width = DefaultWidth
+	}
+	if opts.Height <= 0 {
+		opts.Height = DefaultHeight
+	}
+	m := margins{top: 28, right: 12, bottom: 22, left: 56}
+	if opts.Sparkline {
+		m = margins{top: 2, right: 2, bottom: 2, left: 2}
+	}
+	plotW := float64(opts.Width) - m.left - m.right
+	plotH := float64(opts.Height) - m.top - m.bottom
+	if plotW <= 0 || plotH <= 0 {
+		return fmt.Errorf("chart of %dx%d is too small", opts.Width, opts.Height)
+	}
+
+	samples = downsample(samples, opts.From, opts.To, int(plotW))
+	lo, hi := valueRange(samples)
+	ticks := niceTicks(lo, hi, yTicks)
+	if len(ticks) > 1 {
+		lo, hi = ticks[0], ticks[len(ticks)-1]
+	}
+	span := opts.To.Sub(opts.From)
+	x := func(t time.Time) float64 {
+		if span <= 0 {
+			return m.left + plotW
+		}
+		return m.left + float64(t.Sub(opts.From))/float64(span)*plotW
+	}
+	y := func(v float64) float64 {
+		return m.top + (hi-v)/(hi-lo)*plotH
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(
+		b,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" `+
+			`font-family="sans-serif" font-size="%d">`+"\n",
+		opts.Width, opts.Height, opts.Width, opts.Height, fontSize,
+	)
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" fill="#ffffff"/>`+"\n")
+
+	if !opts.Sparkline {
+		fmt.Fprintf(
+			b, `<text x="%g" y="%g" font-size="%d" font-weight="bold" fill="#1f2328">%s</text>`+"\n",
+			m.left, m.top-12, fontSize+2, html.EscapeString(opts.Title),
+		)
+		for _, v := range ticks {
+			fmt.Fprintf(
+				b, `<line x1="%g" y1="%.1f" x2="%g" y2="%.1f" stroke="#d0d7de" stroke-width="1"/>`+"\n",
+				m.left, y(v), m.left+plotW, y(v),
+			)
+			fmt.Fprintf(
+				b,
+				`<text x="%g" y="%.1f" text-anchor="end" dominant-baseline="middle" `+
+					`fill="#656d76">%s</text>`+"\n",
+				m.left-6, y(v), FormatValue(v),
+			)
+		}
+		layout := timeLayout(span)
+		fmt.Fprintf(
+			b, `<text x="%g" y="%g" fill="#656d76">%s</text>`+"\n",
+			m.left, m.top+plotH+16, opts.From.Format(layout),
+		)
+		fmt.Fprintf(
+			b, `<text x="%g" y="%g" text-anchor="end" fill="#656d76">%s</text>`+"\n",
+			m.left+plotW, m.top+plotH+16, opts.To.Format(layout),
+		)
+	}
+
+	switch {
+	case len(samples) == 0:
+		if !opts.Sparkline {
+			fmt.Fprintf(
+				b, `<text x="%g" y="%g" text-anchor="middle" fill="#656d76">No data</text>`+"\n",
+				m.left+plotW/2, m.top+plotH/2,
+			)
+		}
+	case len(samples) == 1:
+		fmt.Fprintf(
+			b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="#0969da"/>`+"\n",
+			x(samples[0].Time), y(samples[0].Value),
+		)
+	default:
+		fmt.Fprint(
+			b, `<polyline fill="none" stroke="#0969da" stroke-width="1.5" stroke-linejoin="round" points="`,
+		)
+		for i, s := range samples {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(b, "%.1f,%.1f", x(s.Time), y(s.Value))
+		}
+		fmt.Fprint(b, `"/>`+"\n")
+	}
+	fmt.Fprint(b, "</svg>\n")
+	return b.Flush()
+}
+
+// downsample reduces samples to the first, minimum, maximum and last
+// sample of each of width time buckets, which keeps the shape of the line
+// when there are more samples than pixels.
+func downsample(samples []history.Sample, from, to time.Time, width int) []history.Sample {
+	if len(samples) <= 4*width || !to.After(from) {
+		return samples
+	}
+	span := float64(to.Sub(from))
+	result := make([]history.Sample, 0, 4*width)
+	for start := 0; start < len(samples); {
+		bucket := int(float64(samples[start].Time.Sub(from)) / span * float64(width))
+		end := start + 1
+		for end < len(samples) &&
+			int(float64(samples[end].Time.Sub(from))/span*float64(width)) == bucket {
+			end++
+		}
+		minI, maxI := start, start
+		for i := start; i < end; i++ {
+			if samples[i].Value < samples[minI].Value {
+				minI = i
+			}
+			if samples[i].Value > samples[maxI].Value {
+				maxI = i
+			}
+		}
+		for _, i := range []int{start, minI, maxI, end - 1} {
+			if len(result) == 0 || result[len(result)-1] != samples[i] {
+				result = append(result, samples[i])
+			}
+		}
+		start = end
+	}
+	return result
+}
+
+// valueRange returns the range of the values of samples, widened when all
+// of them are equal so that the line is drawn in the middle.
+func valueRange(samples []history.Sample) (float64, float64) {
+	if len(samples) == 0 {
+		return 0, 1
+	}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range samples {
+		lo = math.Min(lo, s.Value)
+		hi = math.Max(hi, s.Value)
+	}
+	if lo == hi {
+		d := math.Max(math.Abs(lo)*0.1, 1)
+		return lo - d, hi + d
+	}
+	return lo, hi
+}
+
+// niceTicks returns about n evenly spaced round values covering lo to hi.
+func niceTicks(lo, hi float64, n int) []float64 {
+	if math.IsInf(lo, 0) || math.IsInf(hi, 0) || math.IsNaN(lo) || math.IsNaN(hi) || lo >= hi {
+		return nil
+	}
+	step := niceNumber((hi - lo) / float64(n-1))
+	first := math.Floor(lo / step)
+	var ticks []float64
+	for i := first; ; i++ {
+		// Multiplying avoids labels like 0.30000000000000004.
+		v := i * step
+		ticks = append(ticks, v)
+		if v >= hi {
+			return ticks
+		}
+	}
+}
+
+// niceNumber rounds x up to 1, 2, 5 or 10 times a power of ten.
+func niceNumber(x float64) float64 {
+	exp := math.Floor(math.Log10(x))
+	f := x / math.Pow(10, exp)
+	switch {
+	case f <= 1:
+		f = 1
+	case f <= 2:
+		f = 2
+	case f <= 5:
+		f = 5
+	default:
+		f = 10
+	}
+	return f * math.Pow(10, exp)
+}
+
+// FormatValue formats v with at most three significant digits and an SI
+// suffix, e.g. 1.5k for 1500.
+func FormatValue(v float64) string {
+	suffixes := []string{"", "k", "M", "G", "T", "P"}
+	i := 0
+	for math.Abs(v) >= 1000 && i < len(suffixes)-1 {
+		v /= 1000
+		i++
+	}
+	return strconv.FormatFloat(v, 'g', 3, 64) + suffixes[i]
+}
+
+// timeLayout returns the layout of the time labels of a chart covering
+// span.
+func timeLayout(span time.Duration) string {
+	switch {
+	case span <= 24*time.Hour:
+		return "15:04:05"
+	default:
+		return "2006-01-02 15:04"
+	}
+}
@@ -0,0 +1,118 @@
+package chart
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wellFormed checks that data is a well-formed XML document.
+func wellFormed(t *testing.T, data []byte) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestSVG(t *testing.T) {
+	from := time.Unix(1700000000, 0)
+	to := from.Add(time.Hour)
+	samples := []history.Sample{
+		{Time: from, Value: 1},
+		{Time: from.Add(30 * time.Minute), Value: 2500},
+		{Time: to, Value: 10},
+	}
+
+	tests := []struct {
+		name     string
+		samples  []history.Sample
+		opts     Options
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "Chart",
+			samples:  samples,
+			opts:     Options{Title: `Alloc <"bytes">`, From: from, To: to},
+			contains: []string{`width="600"`, "<polyline", "Alloc &lt;&#34;bytes&#34;&gt;", ">3k<"},
+		},
+		{
+			name:     "Sparkline",
+			samples:  samples,
+			opts:     Options{Title: "Alloc", Width: 100, Height: 20, From: from, To: to, Sparkline: true},
+			contains: []string{`width="100"`, "<polyline"},
+			excludes: []string{"<text"},
+		},
+		{
+			name:     "Single Sample",
+			samples:  samples[:1],
+			opts:     Options{From: from, To: to},
+			contains: []string{"<circle"},
+		},
+		{
+			name:     "No Data",
+			opts:     Options{From: from, To: to},
+			contains: []string{"No data"},
+			excludes: []string{"<polyline"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				var buf bytes.Buffer
+				require.NoError(t, SVG(&buf, tt.samples, tt.opts))
+				wellFormed(t, buf.Bytes())
+				for _, s := range tt.contains {
+					assert.Contains(t, buf.String(), s)
+				}
+				for _, s := range tt.excludes {
+					assert.NotContains(t, buf.String(), s)
+				}
+			},
+		)
+	}
+
+	assert.Error(t, SVG(io.Discard, samples, Options{Width: 10, Height: 10, From: from, To: to}))
+}
+
+func TestDownsample(t *testing.T) {
+	from := time.Unix(1700000000, 0)
+	samples := make([]history.Sample, 10000)
+	for i := range samples {
+		samples[i] = history.Sample{Time: from.Add(time.Duration(i) * time.Second), Value: float64(i % 100)}
+	}
+	reduced := downsample(samples, from, from.Add(10000*time.Second), 100)
+	assert.LessOrEqual(t, len(reduced), 400)
+	assert.Equal(t, samples[0], reduced[0])
+	assert.Equal(t, samples[len(samples)-1], reduced[len(reduced)-1])
+	var hasMax bool
+	for _, s := range reduced {
+		hasMax = hasMax || s.Value == 99
+	}
+	assert.True(t, hasMax, "downsampling dropped the maximum")
+}
+
+func TestFormatValue(t *testing.T) {
+	for v, expected := range map[float64]string{
+		0:        "0",
+		0.25:     "0.25",
+		999:      "999",
+		1500:     "1.5k",
+		-2500000: "-2.5M",
+		3.2e12:   "3.2T",
+	} {
+		assert.Equal(t, expected, FormatValue(v))
+	}
+	assert.False(t, strings.Contains(FormatValue(0.1+0.2), "0000"))
+}
@@ -18,13 +18,18 @@ type Agent struct {
 	CryptoKey      string `json:"crypto_key"`
 	UseGRPC        bool   `json:"use_grpc"`
 	GRPCAddress    string `json:"grpc_address"`
+	// BatchContentType makes the REST sender send all metrics to /updates/
+	// in one request encoded as application/json, application/x-protobuf or
+	// application/msgpack. Metrics are sent one by one when it is empty.
+	BatchContentType string `json:"batch_content_type"`
 }
 
 type AgentConfigJSON struct {
-	Address        string `json:"address"`
-	PollInterval   string `json:"poll_interval"`
-	ReportInterval string `json:"report_interval"`
-	CryptoKey      string `json:"crypto_key"`
+	Address          string `json:"address"`
+	PollInterval     string `json:"poll_interval"`
+	ReportInterval   string `json:"report_interval"`
+	CryptoKey        string `json:"crypto_key"`
+	BatchContentType string `json:"batch_content_type"`
 }
 
 func ParseAgentFlags(a *Agent) {
@@ -42,6 +47,13 @@ func ParseAgentFlags(a *Agent) {
 		"crypto key public",
 	)
 	flag.BoolVar(&a.UseGRPC, "u", true, "is use GRPC")
+	flag.StringVar(
+		&a.BatchContentType,
+		"batch-content-type",
+		"",
+		"send metrics over REST in batches of this content type: "+
+			"application/json, application/x-protobuf or application/msgpack",
+	)
 
 	configFilePath := flag.String(
 		"c",
@@ -79,6 +91,9 @@ func ParseAgentFlags(a *Agent) {
 	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
 		a.CryptoKey = envCryptoKey
 	}
+	if envBatchContentType := os.Getenv("BATCH_CONTENT_TYPE"); envBatchContentType != "" {
+		a.BatchContentType = envBatchContentType
+	}
 
 }
 
@@ -117,6 +132,10 @@ func readFromJSON(a *Agent, configFilePath *string, configFilePathAlt *string) e
 			if flag.Lookup("crypto-key").Value.String() == "" {
 				a.CryptoKey = jsonConfig.CryptoKey
 			}
+
+			if flag.Lookup("batch-content-type").Value.String() == "" {
+				a.BatchContentType = jsonConfig.BatchContentType
+			}
 		}
 	}
 	return nil
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -16,20 +17,61 @@ type Server struct {
 	FlagRestore     bool   `json:"restore"`
 	DatabaseDSN     string `json:"database_dsn"`
 	SecretKey       string
-	CryptoKey       string `json:"crypto_key"`
-	TrustedSubnet   string `json:"trusted_subnet"`
-	GRPCPort        string `json:"grpc_port"`
+	CryptoKey       string  `json:"crypto_key"`
+	TrustedSubnet   string  `json:"trusted_subnet"`
+	GRPCPort        string  `json:"grpc_port"`
+	MultiTenant     bool    `json:"multi_tenant"`
+	TenantTokens    string  `json:"tenant_tokens"`
+	TenantMaxSeries int     `json:"tenant_max_series"`
+	TenantMaxRate   float64 `json:"tenant_max_rate"`
+	MetadataFile    string  `json:"metadata_file"`
+	StorageURL      string  `json:"storage"`
+	Cache           bool    `json:"cache"`
+	CacheTTL        int     `json:"cache_ttl"`
+	MirrorURL       string  `json:"mirror"`
+	MirrorCheck     int     `json:"mirror_check"`
+	StatsdAddress   string  `json:"statsd_address"`
+	StatsdFlush     int     `json:"statsd_flush"`
+	GraphiteAddress string  `json:"graphite_address"`
+	GraphiteFlush   int     `json:"graphite_flush"`
+	// GraphiteTemplates holds graphite templates separated by semicolons.
+	GraphiteTemplates string `json:"graphite_templates"`
+	// InfluxIntegerCounters stores integer line protocol fields as counters.
+	InfluxIntegerCounters bool `json:"influx_integer_counters"`
+	HistoryRetention      int  `json:"history_retention"`
+	// RulesFile holds the recording rules evaluated every RulesInterval seconds.
+	RulesFile     string `json:"rules_file"`
+	RulesInterval int    `json:"rules_interval"`
 }
 
 type ServerConfigJSON struct {
-	Address         string `json:"address"`
-	StoreInterval   string `json:"store_interval"`
-	FileStoragePath string `json:"store_file"`
-	Restore         bool   `json:"restore"`
-	DatabaseDSN     string `json:"database_dsn"`
-	CryptoKey       string `json:"crypto_key"`
-	TrustedSubnet   string `json:"trusted_subnet"`
-	GRPCPort        string `json:"grpc_port"`
+	Address               string   `json:"address"`
+	StoreInterval         string   `json:"store_interval"`
+	FileStoragePath       string   `json:"store_file"`
+	Restore               bool     `json:"restore"`
+	DatabaseDSN           string   `json:"database_dsn"`
+	CryptoKey             string   `json:"crypto_key"`
+	TrustedSubnet         string   `json:"trusted_subnet"`
+	GRPCPort              string   `json:"grpc_port"`
+	MultiTenant           bool     `json:"multi_tenant"`
+	TenantTokens          string   `json:"tenant_tokens"`
+	TenantMaxSeries       int      `json:"tenant_max_series"`
+	TenantMaxRate         float64  `json:"tenant_max_rate"`
+	MetadataFile          string   `json:"metadata_file"`
+	StorageURL            string   `json:"storage"`
+	Cache                 bool     `json:"cache"`
+	CacheTTL              string   `json:"cache_ttl"`
+	MirrorURL             string   `json:"mirror"`
+	MirrorCheck           string   `json:"mirror_check"`
+	StatsdAddress         string   `json:"statsd_address"`
+	StatsdFlush           string   `json:"statsd_flush"`
+	GraphiteAddress       string   `json:"graphite_address"`
+	GraphiteFlush         string   `json:"graphite_flush"`
+	GraphiteTemplates     []string `json:"graphite_templates"`
+	InfluxIntegerCounters bool     `json:"influx_integer_counters"`
+	HistoryRetention      string   `json:"history_retention"`
+	RulesFile             string   `json:"rules_file"`
+	RulesInterval         string   `json:"rules_interval"`
 }
 
 func ParseServerFlags(s *Server) {
@@ -57,6 +99,70 @@ func ParseServerFlags(s *Server) {
 	)
 	flag.StringVar(&s.TrustedSubnet, "t", "", "CIDR")
 	flag.StringVar(&s.GRPCPort, "g", "50051", "GRPC port")
+	flag.BoolVar(&s.MultiTenant, "multi-tenant", false, "partition storage by tenant")
+	flag.StringVar(
+		&s.TenantTokens,
+		"tenant-tokens",
+		"",
+		"path to JSON file mapping API tokens to tenant ids",
+	)
+	flag.IntVar(&s.TenantMaxSeries, "tenant-max-series", 0, "max series per tenant, 0 - unlimited")
+	flag.StringVar(&s.MetadataFile, "metadata-file", "", "file to persist metric metadata")
+	flag.StringVar(
+		&s.StorageURL,
+		"storage",
+		"",
+		"storage URL. Ex: memory://, file:///path, postgres://..., bolt:///path",
+	)
+	flag.Float64Var(
+		&s.TenantMaxRate,
+		"tenant-max-rate",
+		0,
+		"max metric updates per second per tenant, 0 - unlimited",
+	)
+	flag.BoolVar(&s.Cache, "cache", false, "cache metrics in memory in front of the storage")
+	flag.IntVar(&s.CacheTTL, "cache-ttl", 0, "seconds to keep cached metrics, 0 - until changed")
+	flag.StringVar(&s.MirrorURL, "mirror", "", "storage URL to mirror all writes to")
+	flag.IntVar(
+		&s.MirrorCheck,
+		"mirror-check",
+		0,
+		"seconds between comparisons of the mirrored storages, 0 - never",
+	)
+	flag.StringVar(
+		&s.StatsdAddress,
+		"statsd",
+		"",
+		"address to receive StatsD metrics on over UDP and TCP. Ex: :8125",
+	)
+	flag.IntVar(&s.StatsdFlush, "statsd-flush", 10, "seconds to aggregate StatsD metrics for")
+	flag.StringVar(
+		&s.GraphiteAddress,
+		"graphite",
+		"",
+		"address to receive Graphite plaintext metrics on over TCP. Ex: :2003",
+	)
+	flag.IntVar(&s.GraphiteFlush, "graphite-flush", 1, "seconds between writes of Graphite metrics")
+	flag.StringVar(
+		&s.GraphiteTemplates,
+		"graphite-templates",
+		"",
+		"semicolon-separated templates mapping Graphite paths to metric IDs and labels",
+	)
+	flag.BoolVar(
+		&s.InfluxIntegerCounters,
+		"influx-int-counters",
+		false,
+		"store integer InfluxDB line protocol fields as counters instead of gauges",
+	)
+	flag.IntVar(
+		&s.HistoryRetention,
+		"history-retention",
+		3600,
+		"seconds to keep the history of metric values in memory, 0 - no history",
+	)
+	flag.StringVar(&s.RulesFile, "rules-file", "", "path to JSON file with recording rules")
+	flag.IntVar(&s.RulesInterval, "rules-interval", 10, "seconds between evaluations of recording rules")
 
 	configFilePath := flag.String(
 		"c",
@@ -101,6 +207,63 @@ func ParseServerFlags(s *Server) {
 	if envGRPCPort := os.Getenv("GRPC_PORT"); envGRPCPort != "" {
 		s.GRPCPort = envGRPCPort
 	}
+	if envMultiTenant := os.Getenv("MULTI_TENANT"); envMultiTenant != "" {
+		s.MultiTenant, _ = strconv.ParseBool(envMultiTenant)
+	}
+	if envTenantTokens := os.Getenv("TENANT_TOKENS"); envTenantTokens != "" {
+		s.TenantTokens = envTenantTokens
+	}
+	if envTenantMaxSeries := os.Getenv("TENANT_MAX_SERIES"); envTenantMaxSeries != "" {
+		s.TenantMaxSeries, _ = strconv.Atoi(envTenantMaxSeries)
+	}
+	if envTenantMaxRate := os.Getenv("TENANT_MAX_RATE"); envTenantMaxRate != "" {
+		s.TenantMaxRate, _ = strconv.ParseFloat(envTenantMaxRate, 64)
+	}
+	if envMetadataFile := os.Getenv("METADATA_FILE"); envMetadataFile != "" {
+		s.MetadataFile = envMetadataFile
+	}
+	if envStorageURL := os.Getenv("STORAGE_URL"); envStorageURL != "" {
+		s.StorageURL = envStorageURL
+	}
+	if envCache := os.Getenv("CACHE"); envCache != "" {
+		s.Cache, _ = strconv.ParseBool(envCache)
+	}
+	if envCacheTTL := os.Getenv("CACHE_TTL"); envCacheTTL != "" {
+		s.CacheTTL, _ = strconv.Atoi(envCacheTTL)
+	}
+	if envMirrorURL := os.Getenv("MIRROR_URL"); envMirrorURL != "" {
+		s.MirrorURL = envMirrorURL
+	}
+	if envMirrorCheck := os.Getenv("MIRROR_CHECK"); envMirrorCheck != "" {
+		s.MirrorCheck, _ = strconv.Atoi(envMirrorCheck)
+	}
+	if envStatsdAddress := os.Getenv("STATSD_ADDRESS"); envStatsdAddress != "" {
+		s.StatsdAddress = envStatsdAddress
+	}
+	if envStatsdFlush := os.Getenv("STATSD_FLUSH"); envStatsdFlush != "" {
+		s.StatsdFlush, _ = strconv.Atoi(envStatsdFlush)
+	}
+	if envGraphiteAddress := os.Getenv("GRAPHITE_ADDRESS"); envGraphiteAddress != "" {
+		s.GraphiteAddress = envGraphiteAddress
+	}
+	if envGraphiteFlush := os.Getenv("GRAPHITE_FLUSH"); envGraphiteFlush != "" {
+		s.GraphiteFlush, _ = strconv.Atoi(envGraphiteFlush)
+	}
+	if envGraphiteTemplates := os.Getenv("GRAPHITE_TEMPLATES"); envGraphiteTemplates != "" {
+		s.GraphiteTemplates = envGraphiteTemplates
+	}
+	if envInfluxIntegerCounters := os.Getenv("INFLUX_INT_COUNTERS"); envInfluxIntegerCounters != "" {
+		s.InfluxIntegerCounters, _ = strconv.ParseBool(envInfluxIntegerCounters)
+	}
+	if envHistoryRetention := os.Getenv("HISTORY_RETENTION"); envHistoryRetention != "" {
+		s.HistoryRetention, _ = strconv.Atoi(envHistoryRetention)
+	}
+	if envRulesFile := os.Getenv("RULES_FILE"); envRulesFile != "" {
+		s.RulesFile = envRulesFile
+	}
+	if envRulesInterval := os.Getenv("RULES_INTERVAL"); envRulesInterval != "" {
+		s.RulesInterval, _ = strconv.Atoi(envRulesInterval)
+	}
 
 }
 
@@ -150,6 +313,88 @@ func readJSONConfig(s *Server, configFilePath *string, configFilePathAlt *string
 		if flag.Lookup("crypto-key").Value.String() == "" {
 			s.CryptoKey = jsonConfig.CryptoKey
 		}
+		if !s.MultiTenant {
+			s.MultiTenant = jsonConfig.MultiTenant
+		}
+		if s.TenantTokens == "" {
+			s.TenantTokens = jsonConfig.TenantTokens
+		}
+		if s.TenantMaxSeries == 0 {
+			s.TenantMaxSeries = jsonConfig.TenantMaxSeries
+		}
+		if s.TenantMaxRate == 0 {
+			s.TenantMaxRate = jsonConfig.TenantMaxRate
+		}
+		if s.MetadataFile == "" {
+			s.MetadataFile = jsonConfig.MetadataFile
+		}
+		if s.StorageURL == "" {
+			s.StorageURL = jsonConfig.StorageURL
+		}
+		if !s.Cache {
+			s.Cache = jsonConfig.Cache
+		}
+		if s.CacheTTL == 0 && jsonConfig.CacheTTL != "" {
+			if dur, err := time.ParseDuration(jsonConfig.CacheTTL); err == nil {
+				s.CacheTTL = int(dur.Seconds())
+			} else {
+				return err
+			}
+		}
+		if s.MirrorURL == "" {
+			s.MirrorURL = jsonConfig.MirrorURL
+		}
+		if s.MirrorCheck == 0 && jsonConfig.MirrorCheck != "" {
+			if dur, err := time.ParseDuration(jsonConfig.MirrorCheck); err == nil {
+				s.MirrorCheck = int(dur.Seconds())
+			} else {
+				return err
+			}
+		}
+		if s.StatsdAddress == "" {
+			s.StatsdAddress = jsonConfig.StatsdAddress
+		}
+		if flag.Lookup("statsd-flush").Value.String() == "10" && jsonConfig.StatsdFlush != "" {
+			if dur, err := time.ParseDuration(jsonConfig.StatsdFlush); err == nil {
+				s.StatsdFlush = int(dur.Seconds())
+			} else {
+				return err
+			}
+		}
+		if s.GraphiteAddress == "" {
+			s.GraphiteAddress = jsonConfig.GraphiteAddress
+		}
+		if flag.Lookup("graphite-flush").Value.String() == "1" && jsonConfig.GraphiteFlush != "" {
+			if dur, err := time.ParseDuration(jsonConfig.GraphiteFlush); err == nil {
+				s.GraphiteFlush = int(dur.Seconds())
+			} else {
+				return err
+			}
+		}
+		if s.GraphiteTemplates == "" {
+			s.GraphiteTemplates = strings.Join(jsonConfig.GraphiteTemplates, ";")
+		}
+		if !s.InfluxIntegerCounters {
+			s.InfluxIntegerCounters = jsonConfig.InfluxIntegerCounters
+		}
+		if flag.Lookup("history-retention").Value.String() == "3600" &&
+			jsonConfig.HistoryRetention != "" {
+			if dur, err := time.ParseDuration(jsonConfig.HistoryRetention); err == nil {
+				s.HistoryRetention = int(dur.Seconds())
+			} else {
+				return err
+			}
+		}
+		if s.RulesFile == "" {
+			s.RulesFile = jsonConfig.RulesFile
+		}
+		if flag.Lookup("rules-interval").Value.String() == "10" && jsonConfig.RulesInterval != "" {
+			if dur, err := time.ParseDuration(jsonConfig.RulesInterval); err == nil {
+				s.RulesInterval = int(dur.Seconds())
+			} else {
+				return err
+			}
+		}
 	}
 	return nil
 }
@@ -0,0 +1,232 @@
+// Package exposition renders metrics in the Prometheus text exposition
+// format and in OpenMetrics, so the server can be scraped by Prometheus.
+package exposition
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+)
+
+const (
+	// FormatText is the content type of the Prometheus text format.
+	FormatText = "text/plain; version=0.0.4; charset=utf-8"
+	// FormatOpenMetrics is the content type of OpenMetrics.
+	FormatOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// Label is a name-value pair identifying a sample within its family.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single value of a family. Suffix is appended to the family
+// name, e.g. "_total" for counters in OpenMetrics or "_bucket" for histograms.
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family is a group of samples sharing a name, type, help text and unit.
+type Family struct {
+	Name    string
+	Type    string
+	Help    string
+	Unit    string
+	Samples []Sample
+}
+
+// Negotiate selects the format to respond with for the Accept header of a
+// request. OpenMetrics is chosen when the client prefers it over plain text.
+func Negotiate(accept string) string {
+	var openMetrics, text float64
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		switch mediaType {
+		case "application/openmetrics-text":
+			openMetrics = math.Max(openMetrics, q)
+		case "text/plain", "text/*", "*/*":
+			text = math.Max(text, q)
+		}
+	}
+	if openMetrics > 0 && openMetrics >= text {
+		return FormatOpenMetrics
+	}
+	return FormatText
+}
+
+// Families converts stored counters and gauges into sorted metric families.
+// Metric IDs carrying labels, such as http_requests{code="200"}, become
+// samples of the family named before the braces. Names are sanitized to
+// valid Prometheus names; a family keeps the type of its first metric in
+// sort order, and metrics of a different type under the same name are
+// dropped. registry may be nil.
+func Families(
+	counters map[string]int64,
+	gauges map[string]float64,
+	registry *metadata.Registry,
+) []Family {
+	var families []*Family
+	byName := make(map[string]*Family)
+	add := func(id string, mType string, value float64) {
+		name, labels := f.ParseSeriesID(id)
+		sanitized := Sanitize(name)
+		family, ok := byName[sanitized]
+		if !ok {
+			family = &Family{Name: sanitized, Type: mType}
+			if registry != nil {
+				if m, ok := registry.Get(name); ok {
+					family.Help, family.Unit = m.Description, m.Unit
+				}
+			}
+			byName[sanitized] = family
+			families = append(families, family)
+		}
+		if family.Type != mType {
+			return
+		}
+		sample := Sample{Value: value}
+		for _, l := range labels {
+			sample.Labels = append(sample.Labels, Label{Name: Sanitize(l.Name), Value: l.Value})
+		}
+		family.Samples = append(family.Samples, sample)
+	}
+
+	for _, id := range sortedKeys(counters) {
+		add(id, config.Counter, float64(counters[id]))
+	}
+	for _, id := range sortedKeys(gauges) {
+		add(id, config.Gauge, gauges[id])
+	}
+
+	sort.SliceStable(
+		families, func(i, j int) bool {
+			return families[i].Name < families[j].Name
+		},
+	)
+	result := make([]Family, len(families))
+	for i, family := range families {
+		result[i] = *family
+	}
+	return result
+}
+
+// Write renders families in the given format, FormatText or FormatOpenMetrics.
+func Write(w io.Writer, families []Family, format string) error {
+	openMetrics := format == FormatOpenMetrics
+	b := bufio.NewWriter(w)
+	for _, family := range families {
+		name := family.Name
+		suffix := ""
+		if openMetrics && family.Type == config.Counter {
+			name = strings.TrimSuffix(name, "_total")
+			suffix = "_total"
+		}
+		if family.Help != "" {
+			b.WriteString("# HELP " + name + " " + escape(family.Help, openMetrics) + "\n")
+		}
+		b.WriteString("# TYPE " + name + " " + family.Type + "\n")
+		// OpenMetrics only allows a unit that is also the suffix of the name.
+		if openMetrics && family.Unit != "" && strings.HasSuffix(name, "_"+family.Unit) {
+			b.WriteString("# UNIT " + name + " " + family.Unit + "\n")
+		}
+		for _, sample := range family.Samples {
+			b.WriteString(name + suffix + sample.Suffix)
+			writeLabels(b, sample.Labels)
+			b.WriteString(" " + formatValue(sample.Value) + "\n")
+		}
+	}
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	return b.Flush()
+}
+
+// Sanitize replaces characters that are not allowed in Prometheus metric
+// names with underscores.
+func Sanitize(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+func sortedKeys[V int64 | float64](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeLabels(b *bufio.Writer, labels []Label) {
+	if len(labels) == 0 {
+		return
+	}
+	b.WriteByte('{')
+	for i, l := range labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name + `="` + escape(l.Value, true) + `"`)
+	}
+	b.WriteByte('}')
+}
+
+// escape escapes backslashes and line feeds, and double quotes when quoted
+// is set, as required in label values and OpenMetrics help texts.
+func escape(s string, quoted bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quoted {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,112 @@
+package exposition
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		expected string
+	}{
+		{name: "Empty", accept: "", expected: FormatText},
+		{name: "Text", accept: "text/plain", expected: FormatText},
+		{
+			name: "Prometheus Scraper",
+			accept: "application/openmetrics-text;version=1.0.0,application/openmetrics-text;" +
+				"version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1",
+			expected: FormatOpenMetrics,
+		},
+		{
+			name:     "Text Preferred",
+			accept:   "application/openmetrics-text;q=0.2,text/plain",
+			expected: FormatText,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, Negotiate(tt.accept))
+			},
+		)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	registry := metadata.NewRegistry("")
+	require.NoError(
+		t, registry.Set(
+			metadata.Metadata{
+				Name:        "HeapAlloc_bytes",
+				Type:        config.Gauge,
+				Unit:        "bytes",
+				Description: "heap \"alloc\"\nin bytes",
+			},
+		),
+	)
+	families := Families(
+		map[string]int64{"PollCount": 5},
+		map[string]float64{"HeapAlloc_bytes": 1.5, "1 bad.name": math.Inf(1)},
+		registry,
+	)
+
+	var text strings.Builder
+	require.NoError(t, Write(&text, families, FormatText))
+	assert.Equal(
+		t, "# HELP HeapAlloc_bytes heap \"alloc\"\\nin bytes\n"+
+			"# TYPE HeapAlloc_bytes gauge\nHeapAlloc_bytes 1.5\n"+
+			"# TYPE PollCount counter\nPollCount 5\n"+
+			"# TYPE _1_bad_name gauge\n_1_bad_name +Inf\n",
+		text.String(),
+	)
+
+	var openMetrics strings.Builder
+	require.NoError(t, Write(&openMetrics, families, FormatOpenMetrics))
+	assert.Equal(
+		t, "# HELP HeapAlloc_bytes heap \\\"alloc\\\"\\nin bytes\n"+
+			"# TYPE HeapAlloc_bytes gauge\n# UNIT HeapAlloc_bytes bytes\nHeapAlloc_bytes 1.5\n"+
+			"# TYPE PollCount counter\nPollCount_total 5\n"+
+			"# TYPE _1_bad_name gauge\n_1_bad_name +Inf\n# EOF\n",
+		openMetrics.String(),
+	)
+}
+
+func TestWriteLabels(t *testing.T) {
+	var b strings.Builder
+	families := []Family{
+		{
+			Name: "requests",
+			Type: "gauge",
+			Samples: []Sample{
+				{Labels: []Label{{Name: "path", Value: `a"b`}, {Name: "code", Value: "200"}}, Value: 1},
+			},
+		},
+	}
+	require.NoError(t, Write(&b, families, FormatText))
+	assert.Equal(t, "# TYPE requests gauge\nrequests{path=\"a\\\"b\",code=\"200\"} 1\n", b.String())
+}
+
+func TestFamiliesLabels(t *testing.T) {
+	families := Families(
+		map[string]int64{`requests{code="200"}`: 3, `requests{code="500"}`: 1, "requests": 4},
+		map[string]float64{`requests{code="404"}`: 2},
+		nil,
+	)
+	require.Len(t, families, 1)
+
+	var b strings.Builder
+	require.NoError(t, Write(&b, families, FormatText))
+	assert.Equal(
+		t, "# TYPE requests counter\nrequests 4\n"+
+			"requests{code=\"200\"} 3\nrequests{code=\"500\"} 1\n",
+		b.String(),
+	)
+}
@@ -0,0 +1,510 @@
+// Package expr parses and evaluates queries over stored metrics, such as
+// (TotalMemory - FreeMemory) / TotalMemory * 100 or
+// sum by (code) (rate(requests[5m])).
+//
+// An expression evaluates to a scalar or to a vector: a set of series,
+// each a metric name with labels in the notation of formatter.SeriesID
+// and a value. The language has
+//
+//   - numbers;
+//   - selectors: a metric name with optional label matchers, e.g.
+//     requests{code="200", method!="POST"}, selecting the current value
+//     of every matching series. A selector followed by a range, e.g.
+//     requests[5m], selects the samples of that period from the history
+//     instead and may only be passed to a range function;
+//   - the operators +, -, * and / with the usual precedence, between
+//     scalars, between a vector and a scalar, and between vectors, whose
+//     series are matched by their labels;
+//   - the functions abs, rate, avg_over_time, min_over_time and
+//     max_over_time;
+//   - the aggregations sum, avg, min, max and count, optionally grouped
+//     by labels, e.g. sum by (code) (requests).
+//
+// Operators, functions and aggregations drop the metric name from the
+// series they return.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+)
+
+var (
+	ErrSyntax    = errors.New("syntax error")
+	ErrType      = errors.New("type error")
+	ErrNoData    = errors.New("no data")
+	ErrNotFinite = errors.New("result is not finite")
+	ErrNoHistory = errors.New("range selectors need the metrics history")
+)
+
+// Value is the result of an expression: a Scalar or a Vector.
+type Value interface {
+	value()
+}
+
+// Scalar is a single number.
+type Scalar float64
+
+// Vector is a set of series, sorted by ID.
+type Vector []Series
+
+// Series is a series of a vector with its current value.
+type Series struct {
+	Name   string
+	Labels []f.Label
+	Value  float64
+}
+
+// ID returns the series ID of s.
+func (s Series) ID() string {
+	return f.SeriesID(s.Name, s.Labels)
+}
+
+// matrix is the value of a range selector.
+type matrix []rangeSeries
+
+type rangeSeries struct {
+	labels  []f.Label
+	samples []history.Sample
+}
+
+func (Scalar) value() {}
+func (Vector) value() {}
+func (matrix) value() {}
+
+// Expr is a parsed expression.
+type Expr interface {
+	eval(env *env) (Value, error)
+	String() string
+}
+
+// env is the input of an evaluation.
+type env struct {
+	st   serviceInterface.MetricsStorage
+	hist *history.Storage
+	now  time.Time
+}
+
+// Eval evaluates e over the metrics of st at now. A scalar that is not
+// finite, e.g. the result of a division by zero, is reported as
+// ErrNotFinite; such series are dropped from vectors.
+func Eval(e Expr, st serviceInterface.MetricsStorage, now time.Time) (Value, error) {
+	v, err := e.eval(&env{st: st, hist: history.Find(st), now: now})
+	if err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case Scalar:
+		if !finite(float64(v)) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFinite, e)
+		}
+		return v, nil
+	case Vector:
+		result := make(Vector, 0, len(v))
+		for _, s := range v {
+			if finite(s.Value) {
+				result = append(result, s)
+			}
+		}
+		sort.Slice(
+			result, func(i, j int) bool {
+				return result[i].ID() < result[j].ID()
+			},
+		)
+		return result, nil
+	default:
+		return nil, fmt.Errorf("%w: range selector %s must be passed to a function", ErrType, e)
+	}
+}
+
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// number is a numeric literal.
+type number float64
+
+func (n number) eval(*env) (Value, error) {
+	return Scalar(n), nil
+}
+
+func (n number) String() string {
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+// matcher selects the series whose label equals, or with not differs
+// from, value. A missing label has the empty value.
+type matcher struct {
+	label string
+	value string
+	not   bool
+}
+
+func (m matcher) matches(labels []f.Label) bool {
+	return (labelValue(labels, m.label) == m.value) != m.not
+}
+
+func (m matcher) String() string {
+	op := "="
+	if m.not {
+		op = "!="
+	}
+	return m.label + op + strconv.Quote(m.value)
+}
+
+func labelValue(labels []f.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// selector selects the series of a metric. With a range it selects their
+// samples over the range instead of their current values.
+type selector struct {
+	name     string
+	matchers []matcher
+	rng      time.Duration
+}
+
+func (s selector) eval(env *env) (Value, error) {
+	if s.rng > 0 && env.hist == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoHistory, s)
+	}
+	metrics, err := storage.List(env.st, storage.ListOptions{Prefix: s.name})
+	if err != nil {
+		return nil, err
+	}
+	// A series stored as gauge and counter selects the gauge.
+	selected := make(map[string]f.Metric, len(metrics))
+	for _, m := range metrics {
+		name, labels := f.ParseSeriesID(m.ID)
+		if name != s.name || !s.matches(labels) {
+			continue
+		}
+		if _, ok := selected[m.ID]; !ok || m.MType == config.Gauge {
+			selected[m.ID] = m
+		}
+	}
+
+	if s.rng == 0 {
+		v := make(Vector, 0, len(selected))
+		for _, m := range selected {
+			_, labels := f.ParseSeriesID(m.ID)
+			value := float64(0)
+			if m.Value != nil {
+				value = *m.Value
+			} else if m.Delta != nil {
+				value = float64(*m.Delta)
+			}
+			v = append(v, Series{Name: s.name, Labels: labels, Value: value})
+		}
+		return v, nil
+	}
+	v := make(matrix, 0, len(selected))
+	for _, m := range selected {
+		samples := env.hist.Range(m.MType, m.ID, env.now.Add(-s.rng), env.now)
+		if len(samples) > 0 {
+			_, labels := f.ParseSeriesID(m.ID)
+			v = append(v, rangeSeries{labels: labels, samples: samples})
+		}
+	}
+	return v, nil
+}
+
+func (s selector) matches(labels []f.Label) bool {
+	for _, m := range s.matchers {
+		if !m.matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s selector) String() string {
+	var b strings.Builder
+	b.WriteString(s.name)
+	if len(s.matchers) > 0 {
+		b.WriteByte('{')
+		for i, m := range s.matchers {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(m.String())
+		}
+		b.WriteByte('}')
+	}
+	if s.rng > 0 {
+		b.WriteString("[" + s.rng.String() + "]")
+	}
+	return b.String()
+}
+
+// negation is the unary minus.
+type negation struct {
+	x Expr
+}
+
+func (n negation) eval(env *env) (Value, error) {
+	return binary{op: '*', x: number(-1), y: n.x}.eval(env)
+}
+
+func (n negation) String() string {
+	return "-" + n.x.String()
+}
+
+// binary is an arithmetic operation. Between vectors it applies to the
+// series of both sides with the same labels; series without a match are
+// dropped.
+type binary struct {
+	op   byte
+	x, y Expr
+}
+
+func (b binary) eval(env *env) (Value, error) {
+	x, err := b.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	y, err := b.y.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch x := x.(type) {
+	case Scalar:
+		switch y := y.(type) {
+		case Scalar:
+			return Scalar(apply(b.op, float64(x), float64(y))), nil
+		case Vector:
+			return mapVector(
+				y, func(v float64) float64 {
+					return apply(b.op, float64(x), v)
+				},
+			), nil
+		}
+	case Vector:
+		switch y := y.(type) {
+		case Scalar:
+			return mapVector(
+				x, func(v float64) float64 {
+					return apply(b.op, v, float64(y))
+				},
+			), nil
+		case Vector:
+			right := make(map[string]float64, len(y))
+			for _, s := range y {
+				right[f.SeriesID("", s.Labels)] = s.Value
+			}
+			result := make(Vector, 0, len(x))
+			for _, s := range x {
+				if v, ok := right[f.SeriesID("", s.Labels)]; ok {
+					result = append(result, Series{Labels: s.Labels, Value: apply(b.op, s.Value, v)})
+				}
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: range selector in %s", ErrType, b)
+}
+
+func (b binary) String() string {
+	return "(" + b.x.String() + " " + string(b.op) + " " + b.y.String() + ")"
+}
+
+func apply(op byte, x, y float64) float64 {
+	switch op {
+	case '+':
+		return x + y
+	case '-':
+		return x - y
+	case '*':
+		return x * y
+	default:
+		return x / y
+	}
+}
+
+// mapVector applies fn to the values of v and drops the metric name.
+func mapVector(v Vector, fn func(float64) float64) Vector {
+	result := make(Vector, len(v))
+	for i, s := range v {
+		result[i] = Series{Labels: s.Labels, Value: fn(s.Value)}
+	}
+	return result
+}
+
+// functions maps function names to whether they take a range selector.
+var functions = map[string]bool{
+	"abs":           false,
+	"rate":          true,
+	"avg_over_time": true,
+	"min_over_time": true,
+	"max_over_time": true,
+}
+
+// call is a function call.
+type call struct {
+	fn  string
+	arg Expr
+}
+
+func (c call) eval(env *env) (Value, error) {
+	arg, err := c.arg.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if !functions[c.fn] {
+		switch arg := arg.(type) {
+		case Scalar:
+			return Scalar(math.Abs(float64(arg))), nil
+		case Vector:
+			return mapVector(arg, math.Abs), nil
+		}
+		return nil, fmt.Errorf("%w: %s takes a scalar or an instant vector", ErrType, c.fn)
+	}
+
+	m, ok := arg.(matrix)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s takes a range selector", ErrType, c.fn)
+	}
+	result := make(Vector, 0, len(m))
+	for _, s := range m {
+		if v, ok := overTime(c.fn, s.samples); ok {
+			result = append(result, Series{Labels: s.labels, Value: v})
+		}
+	}
+	return result, nil
+}
+
+func (c call) String() string {
+	return c.fn + "(" + c.arg.String() + ")"
+}
+
+// overTime applies the range function fn to samples. rate is the
+// per-second increase between the first and the last sample, where a
+// decrease is taken for a counter reset; it needs two samples.
+func overTime(fn string, samples []history.Sample) (float64, bool) {
+	switch fn {
+	case "rate":
+		if len(samples) < 2 {
+			return 0, false
+		}
+		var increase float64
+		for i := 1; i < len(samples); i++ {
+			if d := samples[i].Value - samples[i-1].Value; d >= 0 {
+				increase += d
+			} else {
+				increase += samples[i].Value
+			}
+		}
+		elapsed := samples[len(samples)-1].Time.Sub(samples[0].Time).Seconds()
+		return increase / elapsed, elapsed > 0
+	default:
+		values := make([]float64, len(samples))
+		for i, s := range samples {
+			values[i] = s.Value
+		}
+		return aggregations[strings.TrimSuffix(fn, "_over_time")](values), true
+	}
+}
+
+// aggregations maps aggregation names to the functions aggregating the
+// values of a group.
+var aggregations = map[string]func(values []float64) float64{
+	"sum": sum,
+	"avg": func(values []float64) float64 {
+		return sum(values) / float64(len(values))
+	},
+	"min": func(values []float64) float64 {
+		v := values[0]
+		for _, x := range values[1:] {
+			v = math.Min(v, x)
+		}
+		return v
+	},
+	"max": func(values []float64) float64 {
+		v := values[0]
+		for _, x := range values[1:] {
+			v = math.Max(v, x)
+		}
+		return v
+	},
+	"count": func(values []float64) float64 {
+		return float64(len(values))
+	},
+}
+
+func sum(values []float64) float64 {
+	var s float64
+	for _, v := range values {
+		s += v
+	}
+	return s
+}
+
+// aggregation aggregates the series of a vector into one series per
+// distinct value of the by labels.
+type aggregation struct {
+	op string
+	by []string
+	x  Expr
+}
+
+func (a aggregation) eval(env *env) (Value, error) {
+	x, err := a.x.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := x.(Vector)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s takes an instant vector", ErrType, a.op)
+	}
+	type group struct {
+		labels []f.Label
+		values []float64
+	}
+	groups := make(map[string]*group)
+	var order []string
+	for _, s := range v {
+		var labels []f.Label
+		for _, name := range a.by {
+			if value := labelValue(s.Labels, name); value != "" {
+				labels = append(labels, f.Label{Name: name, Value: value})
+			}
+		}
+		id := f.SeriesID("", labels)
+		g, ok := groups[id]
+		if !ok {
+			g = &group{labels: labels}
+			groups[id] = g
+			order = append(order, id)
+		}
+		g.values = append(g.values, s.Value)
+	}
+	result := make(Vector, 0, len(order))
+	for _, id := range order {
+		g := groups[id]
+		result = append(result, Series{Labels: g.labels, Value: aggregations[a.op](g.values)})
+	}
+	return result, nil
+}
+
+func (a aggregation) String() string {
+	if len(a.by) == 0 {
+		return a.op + "(" + a.x.String() + ")"
+	}
+	return a.op + " by (" + strings.Join(a.by, ", ") + ") (" + a.x.String() + ")"
+}
@@ -0,0 +1,155 @@
+package expr
+
+import (
+	"testing"
+	"time"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEval(t *testing.T) {
+	st := history.New(filememory.NewMemStorage(false, nil), time.Hour)
+	require.NoError(t, st.UpdateGauge("HeapInuse", 30))
+	require.NoError(t, st.UpdateGauge("HeapSys", 120))
+	require.NoError(t, st.UpdateGauge("TotalMemory", 8))
+	require.NoError(t, st.UpdateGauge("FreeMemory", 2))
+	require.NoError(t, st.UpdateGauge("Zero", 0))
+	require.NoError(t, st.UpdateGauge("Load", 1))
+	require.NoError(t, st.UpdateGauge("Load", 4))
+	require.NoError(t, st.UpdateCounter("PollCount", 5, false))
+	require.NoError(t, st.UpdateCounter(`requests{code="200",method="GET"}`, 7, false))
+	require.NoError(t, st.UpdateCounter(`requests{code="200",method="POST"}`, 2, false))
+	require.NoError(t, st.UpdateCounter(`requests{code="500",method="GET"}`, 1, false))
+	require.NoError(t, st.UpdateCounter(`errors{code="500",method="GET"}`, 1, false))
+	require.NoError(t, st.UpdateGauge(`latency{method="GET"}`, -3))
+	require.NoError(t, st.UpdateGauge(`latency{method="POST"}`, 5))
+
+	get := []f.Label{{Name: "method", Value: "GET"}}
+	post := []f.Label{{Name: "method", Value: "POST"}}
+	tests := []struct {
+		name     string
+		expr     string
+		expected Value
+		wantErr  error
+	}{
+		{name: "Ratio", expr: "HeapInuse / HeapSys", expected: Vector{{Value: 0.25}}},
+		{
+			name:     "Percent",
+			expr:     "(TotalMemory - FreeMemory) / TotalMemory * 100",
+			expected: Vector{{Value: 75}},
+		},
+		{name: "Precedence", expr: "1 + 2 * 3 - 4 / 2", expected: Scalar(5)},
+		{name: "Left Associative", expr: "8 - 4 - 2", expected: Scalar(2)},
+		{name: "Unary Minus", expr: "-PollCount * -2", expected: Vector{{Value: 10}}},
+		{name: "Exponent", expr: "1.5e2 + .5", expected: Scalar(150.5)},
+		{name: "Selector", expr: "HeapInuse", expected: Vector{{Name: "HeapInuse", Value: 30}}},
+		{
+			name: "Matchers",
+			expr: `requests{method="GET", code!="500"}`,
+			expected: Vector{
+				{
+					Name:   "requests",
+					Labels: []f.Label{{Name: "code", Value: "200"}, {Name: "method", Value: "GET"}},
+					Value:  7,
+				},
+			},
+		},
+		{
+			name: "Vector Matching",
+			expr: "errors / requests",
+			expected: Vector{
+				{Labels: []f.Label{{Name: "code", Value: "500"}, {Name: "method", Value: "GET"}}, Value: 1},
+			},
+		},
+		{name: "Sum", expr: "sum(requests)", expected: Vector{{Value: 10}}},
+		{
+			name: "Sum By",
+			expr: "sum by (method) (requests)",
+			expected: Vector{
+				{Labels: get, Value: 8},
+				{Labels: post, Value: 2},
+			},
+		},
+		{name: "Avg", expr: "avg(requests)", expected: Vector{{Value: 10.0 / 3}}},
+		{name: "Count By Missing Label", expr: "count by (host) (requests)", expected: Vector{{Value: 3}}},
+		{name: "Min", expr: "min(latency)", expected: Vector{{Value: -3}}},
+		{name: "Max", expr: "max(latency)", expected: Vector{{Value: 5}}},
+		{
+			name: "Abs",
+			expr: "abs(latency)",
+			expected: Vector{
+				{Labels: get, Value: 3},
+				{Labels: post, Value: 5},
+			},
+		},
+		{name: "Abs Scalar", expr: "abs(-2)", expected: Scalar(2)},
+		{name: "Avg Over Time", expr: "avg_over_time(Load[1h])", expected: Vector{{Value: 2.5}}},
+		{name: "Max Over Time", expr: "max_over_time(Load[1h]) * 2", expected: Vector{{Value: 8}}},
+		{name: "Min Over Time", expr: "min_over_time(Load[1h])", expected: Vector{{Value: 1}}},
+		{name: "Rate Needs Two Samples", expr: "rate(PollCount[1h])", expected: Vector{}},
+		{name: "Unknown Metric", expr: "HeapInuse / Nope", expected: Vector{}},
+		{name: "Division By Zero", expr: "HeapInuse / Zero", expected: Vector{}},
+		{name: "Scalar Division By Zero", expr: "1 / 0", wantErr: ErrNotFinite},
+		{name: "Range Without Function", expr: "Load[1h]", wantErr: ErrType},
+		{name: "Function Without Range", expr: "rate(Load)", wantErr: ErrType},
+		{name: "Aggregation Of Scalar", expr: "sum(1)", wantErr: ErrType},
+		{name: "Range In Arithmetic", expr: "Load[1h] + 1", wantErr: ErrType},
+		{name: "Unknown Function", expr: "sqrt(Load)", wantErr: ErrSyntax},
+		{name: "Invalid Range", expr: "Load[1x]", wantErr: ErrSyntax},
+		{name: "Missing Operand", expr: "HeapInuse /", wantErr: ErrSyntax},
+		{name: "Unbalanced", expr: "(1 + 2", wantErr: ErrSyntax},
+		{name: "Trailing Input", expr: "1 2", wantErr: ErrSyntax},
+		{name: "Invalid Character", expr: "1 % 2", wantErr: ErrSyntax},
+		{name: "Invalid Labels", expr: "requests{code=200}", wantErr: ErrSyntax},
+		{name: "Empty", expr: "", wantErr: ErrSyntax},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				e, err := Parse(tt.expr)
+				if err == nil {
+					var v Value
+					v, err = Eval(e, st, time.Now())
+					if tt.wantErr == nil {
+						require.NoError(t, err)
+						assert.Equal(t, tt.expected, v)
+						return
+					}
+				}
+				assert.ErrorIs(t, err, tt.wantErr)
+			},
+		)
+	}
+}
+
+func TestEvalWithoutHistory(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	require.NoError(t, st.UpdateGauge("Load", 1))
+	e, err := Parse("max_over_time(Load[5m])")
+	require.NoError(t, err)
+	_, err = Eval(e, st, time.Now())
+	assert.ErrorIs(t, err, ErrNoHistory)
+}
+
+func TestRate(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	samples := []history.Sample{
+		{Time: base, Value: 10},
+		{Time: base.Add(10 * time.Second), Value: 30},
+		{Time: base.Add(20 * time.Second), Value: 5},
+		{Time: base.Add(40 * time.Second), Value: 15},
+	}
+	v, ok := overTime("rate", samples)
+	require.True(t, ok)
+	// 20 before the reset, 5 after it and 10 since then.
+	assert.InDelta(t, 35.0/40, v, 1e-9)
+
+	_, ok = overTime("rate", samples[:1])
+	assert.False(t, ok)
+	_, ok = overTime("rate", []history.Sample{samples[0], {Time: base, Value: 20}})
+	assert.False(t, ok)
+}
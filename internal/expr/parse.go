@@ -0,0 +1,374 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parse parses an expression.
+func Parse(s string) (Expr, error) {
+	p := &parser{lex: lexer{input: s}}
+	p.next()
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	return e, nil
+}
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) next() {
+	p.tok = p.lex.next()
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("%w at position %d: %s", ErrSyntax, p.tok.pos+1, fmt.Sprintf(format, args...))
+}
+
+// expect consumes a token of kind or fails with what was expected.
+func (p *parser) expect(kind tokenKind, what string) error {
+	if p.tok.kind != kind {
+		return p.errorf("expected %s instead of %s", what, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+// parseExpr parses a sum of terms.
+func (p *parser) parseExpr() (Expr, error) {
+	x, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOperator && (p.tok.text == "+" || p.tok.text == "-") {
+		op := p.tok.text[0]
+		p.next()
+		y, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+// parseTerm parses a product of unary expressions.
+func (p *parser) parseTerm() (Expr, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == tokOperator && (p.tok.text == "*" || p.tok.text == "/") {
+		op := p.tok.text[0]
+		p.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = binary{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.tok.kind == tokOperator && (p.tok.text == "-" || p.tok.text == "+") {
+		negative := p.tok.text == "-"
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil || !negative {
+			return x, err
+		}
+		return negation{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	switch p.tok.kind {
+	case tokNumber:
+		v, err := strconv.ParseFloat(p.tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", p.tok.text)
+		}
+		p.next()
+		return number(v), nil
+	case tokIdent:
+		return p.parseIdent()
+	case tokLeftParen:
+		p.next()
+		x, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	case tokError:
+		return nil, p.errorf("%s", p.tok.text)
+	default:
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+}
+
+// parseIdent parses an aggregation, a function call or a selector.
+func (p *parser) parseIdent() (Expr, error) {
+	name := p.tok.text
+	p.next()
+	_, isAggregation := aggregations[name]
+	switch {
+	case isAggregation && (p.tok.kind == tokLeftParen || p.tok.kind == tokIdent && p.tok.text == "by"):
+		return p.parseAggregation(name)
+	case p.tok.kind == tokLeftParen:
+		if _, ok := functions[name]; !ok {
+			return nil, p.errorf("unknown function %s", name)
+		}
+		p.next()
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRightParen, ")"); err != nil {
+			return nil, err
+		}
+		return call{fn: name, arg: arg}, nil
+	default:
+		return p.parseSelector(name)
+	}
+}
+
+// parseAggregation parses the optional grouping and the argument of an
+// aggregation, e.g. sum by (code) (requests).
+func (p *parser) parseAggregation(op string) (Expr, error) {
+	a := aggregation{op: op}
+	if p.tok.kind == tokIdent {
+		p.next()
+		if err := p.expect(tokLeftParen, "( after by"); err != nil {
+			return nil, err
+		}
+		for p.tok.kind != tokRightParen {
+			if p.tok.kind != tokIdent {
+				return nil, p.errorf("expected label name instead of %s", p.tok)
+			}
+			a.by = append(a.by, p.tok.text)
+			p.next()
+			if p.tok.kind != tokComma {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(tokRightParen, ")"); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect(tokLeftParen, "("); err != nil {
+		return nil, err
+	}
+	x, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokRightParen, ")"); err != nil {
+		return nil, err
+	}
+	a.x = x
+	return a, nil
+}
+
+// parseSelector parses the optional label matchers and range of a
+// selector, e.g. requests{code!="200"}[5m].
+func (p *parser) parseSelector(name string) (Expr, error) {
+	s := selector{name: name}
+	if p.tok.kind == tokLeftBrace {
+		p.next()
+		for p.tok.kind != tokRightBrace {
+			if p.tok.kind != tokIdent {
+				return nil, p.errorf("expected label name instead of %s", p.tok)
+			}
+			m := matcher{label: p.tok.text}
+			p.next()
+			switch p.tok.kind {
+			case tokEqual:
+			case tokNotEqual:
+				m.not = true
+			default:
+				return nil, p.errorf("expected = or != instead of %s", p.tok)
+			}
+			p.next()
+			if p.tok.kind != tokString {
+				return nil, p.errorf("expected quoted label value instead of %s", p.tok)
+			}
+			m.value = p.tok.text
+			s.matchers = append(s.matchers, m)
+			p.next()
+			if p.tok.kind != tokComma {
+				break
+			}
+			p.next()
+		}
+		if err := p.expect(tokRightBrace, "}"); err != nil {
+			return nil, err
+		}
+	}
+	if p.tok.kind == tokLeftBracket {
+		raw, ok := p.lex.until(']')
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if !ok || err != nil || d <= 0 {
+			return nil, p.errorf("invalid range [%s]", raw)
+		}
+		s.rng = d
+		p.next()
+	}
+	return s, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokError
+	tokNumber
+	tokIdent
+	tokString
+	tokOperator
+	tokLeftParen
+	tokRightParen
+	tokLeftBrace
+	tokRightBrace
+	tokLeftBracket
+	tokComma
+	tokEqual
+	tokNotEqual
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokString:
+		return "string " + strconv.Quote(t.text)
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+// punctuation maps single characters to their tokens.
+var punctuation = map[byte]tokenKind{
+	'(': tokLeftParen,
+	')': tokRightParen,
+	'{': tokLeftBrace,
+	'}': tokRightBrace,
+	'[': tokLeftBracket,
+	',': tokComma,
+	'=': tokEqual,
+	'+': tokOperator,
+	'-': tokOperator,
+	'*': tokOperator,
+	'/': tokOperator,
+}
+
+type lexer struct {
+	input string
+	pos   int
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.input) && isSpace(l.input[l.pos]) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.input) {
+		return token{kind: tokEOF, pos: start}
+	}
+	c := l.input[l.pos]
+	if kind, ok := punctuation[c]; ok {
+		l.pos++
+		return token{kind: kind, text: string(c), pos: start}
+	}
+	switch {
+	case c == '!' && strings.HasPrefix(l.input[l.pos:], "!="):
+		l.pos += 2
+		return token{kind: tokNotEqual, text: "!=", pos: start}
+	case c == '"':
+		value, err := strconv.QuotedPrefix(l.input[l.pos:])
+		if err != nil {
+			l.pos = len(l.input)
+			return token{kind: tokError, text: "unterminated string", pos: start}
+		}
+		l.pos += len(value)
+		unquoted, _ := strconv.Unquote(value)
+		return token{kind: tokString, text: unquoted, pos: start}
+	case isDigit(c) || c == '.':
+		return l.number()
+	case isIdentStart(c):
+		for l.pos < len(l.input) && isIdentChar(l.input[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.input[start:l.pos], pos: start}
+	default:
+		l.pos++
+		return token{kind: tokError, text: fmt.Sprintf("unexpected character %q", c), pos: start}
+	}
+}
+
+func (l *lexer) number() token {
+	start := l.pos
+	for l.pos < len(l.input) && (isDigit(l.input[l.pos]) || l.input[l.pos] == '.') {
+		l.pos++
+	}
+	if l.pos < len(l.input) && (l.input[l.pos] == 'e' || l.input[l.pos] == 'E') {
+		l.pos++
+		if l.pos < len(l.input) && (l.input[l.pos] == '+' || l.input[l.pos] == '-') {
+			l.pos++
+		}
+		for l.pos < len(l.input) && isDigit(l.input[l.pos]) {
+			l.pos++
+		}
+	}
+	return token{kind: tokNumber, text: l.input[start:l.pos], pos: start}
+}
+
+// until returns the input up to the next end and skips past it. It reports
+// false when end is missing.
+func (l *lexer) until(end byte) (string, bool) {
+	i := strings.IndexByte(l.input[l.pos:], end)
+	if i < 0 {
+		raw := l.input[l.pos:]
+		l.pos = len(l.input)
+		return raw, false
+	}
+	raw := l.input[l.pos : l.pos+i]
+	l.pos += i + 1
+	return raw, true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '.' || c == ':'
+}
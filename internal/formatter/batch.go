@@ -0,0 +1,106 @@
+package formatter
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+
+	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// ContentTypeProtobuf specifies the MIME type of batches encoded as an
+	// UpdateBatchMetricsRequest protobuf message.
+	ContentTypeProtobuf = "application/x-protobuf"
+	// ContentTypeMsgPack specifies the MIME type of MessagePack batches.
+	ContentTypeMsgPack = "application/msgpack"
+)
+
+var ErrUnsupportedType = errors.New("unsupported metric type")
+
+// msgpackHandle encodes metrics with the names of their JSON fields.
+var msgpackHandle = &codec.MsgpackHandle{}
+
+// EncodeBatch encodes metrics in the format of contentType. Any content
+// type other than protobuf and MessagePack is encoded as JSON.
+func EncodeBatch(metrics []Metric, contentType string) ([]byte, error) {
+	switch mediaType(contentType) {
+	case ContentTypeProtobuf:
+		m, err := ToProto(metrics)
+		if err != nil {
+			return nil, err
+		}
+		return proto.Marshal(&pb.UpdateBatchMetricsRequest{Metrics: m})
+	case ContentTypeMsgPack, "application/x-msgpack":
+		var out []byte
+		err := codec.NewEncoderBytes(&out, msgpackHandle).Encode(metrics)
+		return out, err
+	default:
+		return json.Marshal(metrics)
+	}
+}
+
+// DecodeBatch decodes a batch of metrics encoded by EncodeBatch. Bodies of
+// unknown content types are decoded as JSON, as before the binary formats
+// were supported.
+func DecodeBatch(data []byte, contentType string) ([]Metric, error) {
+	var metrics []Metric
+	switch mediaType(contentType) {
+	case ContentTypeProtobuf:
+		var req pb.UpdateBatchMetricsRequest
+		if err := proto.Unmarshal(data, &req); err != nil {
+			return nil, err
+		}
+		return FromProto(req.Metrics)
+	case ContentTypeMsgPack, "application/x-msgpack":
+		err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&metrics)
+		return metrics, err
+	default:
+		err := json.Unmarshal(data, &metrics)
+		return metrics, err
+	}
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	return mt
+}
+
+// ToProto converts metrics to their protobuf representation.
+func ToProto(metrics []Metric) ([]*pb.Metric, error) {
+	result := make([]*pb.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		switch {
+		case m.MType == "gauge" && m.Value != nil:
+			result = append(result, &pb.Metric{Id: m.ID, Type: pb.MetricType_GAUGE, Value: *m.Value})
+		case m.MType == "counter" && m.Delta != nil:
+			result = append(result, &pb.Metric{Id: m.ID, Type: pb.MetricType_COUNTER, Delta: *m.Delta})
+		default:
+			return nil, ErrUnsupportedType
+		}
+	}
+	return result, nil
+}
+
+// FromProto converts protobuf metrics to Metric.
+func FromProto(metrics []*pb.Metric) ([]Metric, error) {
+	result := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		switch m.Type {
+		case pb.MetricType_GAUGE:
+			value := m.Value
+			result = append(result, Metric{ID: m.Id, MType: "gauge", Value: &value})
+		case pb.MetricType_COUNTER:
+			delta := m.Delta
+			result = append(result, Metric{ID: m.Id, MType: "counter", Delta: &delta})
+		default:
+			return nil, ErrUnsupportedType
+		}
+	}
+	return result, nil
+}
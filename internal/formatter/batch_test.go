@@ -0,0 +1,39 @@
+package formatter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeBatch(t *testing.T) {
+	delta, value := int64(5), 1.5
+	metrics := []Metric{
+		{ID: "PollCount", MType: "counter", Delta: &delta},
+		{ID: "Alloc", MType: "gauge", Value: &value},
+	}
+	for _, contentType := range []string{
+		ContentTypeJSON,
+		ContentTypeProtobuf,
+		ContentTypeMsgPack,
+		"application/x-msgpack",
+		"application/x-protobuf; charset=binary",
+		"",
+	} {
+		t.Run(
+			contentType, func(t *testing.T) {
+				data, err := EncodeBatch(metrics, contentType)
+				require.NoError(t, err)
+				decoded, err := DecodeBatch(data, contentType)
+				require.NoError(t, err)
+				assert.Equal(t, metrics, decoded)
+			},
+		)
+	}
+
+	_, err := EncodeBatch([]Metric{{ID: "Alloc", MType: "gauge"}}, ContentTypeProtobuf)
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = DecodeBatch([]byte{0xc1}, ContentTypeMsgPack)
+	assert.Error(t, err)
+}
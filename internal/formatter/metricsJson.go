@@ -13,13 +13,16 @@ const ContentTypeJSON = "application/json"
 const ContentTypeTextPlain = "text/plain"
 
 // Metric represents a measurement or other quantifiable data point in an application.
-// It includes an identifier, a type, and optional delta and value fields.
+// It includes an identifier, a type, optional delta and value fields and
+// optional unit and description metadata.
 // The struct is designed to be marshaled into JSON, handling nil delta or value appropriately.
 type Metric struct {
-	ID    string   `json:"id"`
-	MType string   `json:"type"`
-	Delta *int64   `json:"delta,omitempty"`
-	Value *float64 `json:"value,omitempty"`
+	ID          string   `json:"id"`
+	MType       string   `json:"type"`
+	Delta       *int64   `json:"delta,omitempty"`
+	Value       *float64 `json:"value,omitempty"`
+	Unit        string   `json:"unit,omitempty"`
+	Description string   `json:"description,omitempty"`
 }
 
 // MarshalJSON customizes the JSON marshaling for Metric. It ensures that
@@ -19,3 +19,22 @@ func TestMetrics_MarshalJSON(t *testing.T) {
 		t.Fatalf("Error marshalling Metrics to JSON: %v", err)
 	}
 }
+
+func TestSeriesID(t *testing.T) {
+	labels := []Label{{Name: "path", Value: `/a"b`}, {Name: "code", Value: "200"}}
+	id := SeriesID("http_requests", labels)
+	if id != `http_requests{code="200",path="/a\"b"}` {
+		t.Fatalf("unexpected series ID %s", id)
+	}
+
+	name, parsed := ParseSeriesID(id)
+	if name != "http_requests" || len(parsed) != 2 || parsed[1] != labels[0] {
+		t.Fatalf("unexpected parse result %s %v", name, parsed)
+	}
+
+	for _, plain := range []string{"Alloc", "{x}", `broken{a=b}`} {
+		if name, parsed := ParseSeriesID(plain); name != plain || parsed != nil {
+			t.Fatalf("%s should not be parsed as a labelled series", plain)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package formatter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Label is a name-value pair distinguishing series of the same metric name.
+type Label struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SeriesID returns the metric ID of a labelled series. Labels are sorted
+// by name and written in the Prometheus notation, e.g. http_requests{code="200"}.
+// Without labels the ID is the name itself.
+func SeriesID(name string, labels []Label) string {
+	if len(labels) == 0 {
+		return name
+	}
+	sorted := make([]Label, len(labels))
+	copy(sorted, labels)
+	sort.Slice(
+		sorted, func(i, j int) bool {
+			return sorted[i].Name < sorted[j].Name
+		},
+	)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, l := range sorted {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(l.Name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l.Value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// ParseSeriesID splits a metric ID created by SeriesID into the name and
+// labels. IDs that are not in that notation are returned as the name
+// without labels.
+func ParseSeriesID(id string) (string, []Label) {
+	open := strings.IndexByte(id, '{')
+	if open <= 0 || !strings.HasSuffix(id, "}") {
+		return id, nil
+	}
+	name, rest := id[:open], id[open+1:len(id)-1]
+
+	var labels []Label
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return id, nil
+		}
+		value, err := strconv.QuotedPrefix(rest[eq+1:])
+		if err != nil {
+			return id, nil
+		}
+		unquoted, _ := strconv.Unquote(value)
+		labels = append(labels, Label{Name: rest[:eq], Value: unquoted})
+
+		rest = rest[eq+1+len(value):]
+		if rest != "" {
+			if rest[0] != ',' {
+				return id, nil
+			}
+			rest = rest[1:]
+		}
+	}
+	return name, labels
+}
@@ -0,0 +1,196 @@
+// Package graphite receives metrics in the Graphite plaintext protocol,
+// "path value timestamp" lines over TCP, and stores them as gauges.
+// Templates map the dotted paths to metric IDs with labels; paths no
+// template matches are stored under the path itself.
+package graphite
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+)
+
+var ErrInvalidLine = errors.New("invalid graphite line")
+
+type point struct {
+	value     float64
+	timestamp int64
+}
+
+// Server collects Graphite metrics and writes the latest value of every
+// metric to the storage on Flush.
+type Server struct {
+	st        serviceInterface.MetricsStorage
+	templates []Template
+
+	mu     sync.Mutex
+	points map[string]point
+}
+
+// NewServer creates a Server writing into st. The first template matching
+// a path is applied.
+func NewServer(st serviceInterface.MetricsStorage, templates []Template) *Server {
+	return &Server{st: st, templates: templates, points: make(map[string]point)}
+}
+
+// ParseTemplates parses a list of templates, see Template.
+func ParseTemplates(templates []string) ([]Template, error) {
+	parsed := make([]Template, 0, len(templates))
+	for _, s := range templates {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		t, err := ParseTemplate(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, t)
+	}
+	return parsed, nil
+}
+
+// ServeTCP accepts connections on l until it is closed and reads
+// newline-separated lines from each of them.
+func (s *Server) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := s.Handle(line); err != nil {
+			logger.Log.Error(err.Error())
+		}
+	}
+}
+
+// Handle parses a single line. A later timestamp replaces the value
+// received for the same metric in the current interval; a timestamp of
+// -1 stands for the time of arrival.
+func (s *Server) Handle(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) != 3 && len(fields) != 2 {
+		return fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+	path := fields[0]
+	if path == "" || strings.Contains(path, "..") {
+		return fmt.Errorf("%w: path in %q", ErrInvalidLine, line)
+	}
+	value, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w: value in %q", ErrInvalidLine, line)
+	}
+	timestamp := time.Now().Unix()
+	if len(fields) == 3 && fields[2] != "-1" {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return fmt.Errorf("%w: timestamp in %q", ErrInvalidLine, line)
+		}
+		timestamp = int64(ts)
+	}
+
+	id := s.metricID(path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p, ok := s.points[id]; !ok || timestamp >= p.timestamp {
+		s.points[id] = point{value: value, timestamp: timestamp}
+	}
+	return nil
+}
+
+func (s *Server) metricID(path string) string {
+	parts := strings.Split(path, ".")
+	for _, t := range s.templates {
+		if t.Match(parts) {
+			return t.Apply(parts)
+		}
+	}
+	return path
+}
+
+// Flush writes the metrics received since the previous flush as gauges.
+// Metrics the storage rejects are dropped, while those failing for another
+// reason are kept for the next flush unless a newer value arrived since.
+func (s *Server) Flush() error {
+	s.mu.Lock()
+	points := s.points
+	s.points = make(map[string]point)
+	s.mu.Unlock()
+	if len(points) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(points))
+	for id := range points {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	metrics := make([]f.Metric, 0, len(ids))
+	for _, id := range ids {
+		value := points[id].value
+		metrics = append(metrics, f.Metric{ID: id, MType: config.Gauge, Value: &value})
+	}
+
+	var failed error
+	failures := storage.InsertEach(s.st, metrics)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, fail := range failures {
+		if errors.Is(fail.Err, storage.ErrRejected) {
+			logger.Log.Error(fmt.Sprintf("graphite metric %s dropped: %v", fail.Metric.ID, fail.Err))
+			continue
+		}
+		id := fail.Metric.ID
+		if _, ok := s.points[id]; !ok {
+			s.points[id] = points[id]
+		}
+		failed = fail.Err
+	}
+	return failed
+}
+
+// Run flushes every interval until stop is closed, then flushes once more.
+func (s *Server) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			if err := s.Flush(); err != nil {
+				logger.Log.Error(fmt.Sprintf("graphite flush failed: %v", err))
+			}
+			return
+		}
+		if err := s.Flush(); err != nil {
+			logger.Log.Error(fmt.Sprintf("graphite flush failed: %v", err))
+		}
+	}
+}
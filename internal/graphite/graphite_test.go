@@ -0,0 +1,157 @@
+package graphite
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		path     string
+		match    bool
+		expected string
+	}{
+		{
+			name:     "Filter And Labels",
+			template: "servers.* .host.measurement*",
+			path:     "servers.web01.cpu.load",
+			match:    true,
+			expected: `cpu.load{host="web01"}`,
+		},
+		{
+			name:     "Fixed Labels",
+			template: "cron.* .job.measurement env=prod,team=ops",
+			path:     "cron.backup.duration",
+			match:    true,
+			expected: `duration{env="prod",job="backup",team="ops"}`,
+		},
+		{
+			name:     "No Filter",
+			template: "region.measurement*",
+			path:     "eu.requests.total",
+			match:    true,
+			expected: `requests.total{region="eu"}`,
+		},
+		{
+			name:     "Filter Mismatch",
+			template: "servers.* .host.measurement*",
+			path:     "cron.backup",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				tmpl, err := ParseTemplate(tt.template)
+				require.NoError(t, err)
+				parts := strings.Split(tt.path, ".")
+				assert.Equal(t, tt.match, tmpl.Match(parts))
+				if tt.match {
+					assert.Equal(t, tt.expected, tmpl.Apply(parts))
+				}
+			},
+		)
+	}
+
+	for _, invalid := range []string{"host.name", "measurement*.host", "a b c d", "measurement x"} {
+		_, err := ParseTemplate(invalid)
+		assert.ErrorIs(t, err, ErrInvalidTemplate, invalid)
+	}
+}
+
+func TestServer(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	templates, err := ParseTemplates([]string{"servers.* .host.measurement*", ""})
+	require.NoError(t, err)
+	s := NewServer(st, templates)
+
+	require.NoError(t, s.Handle("servers.web01.cpu 0.5 100"))
+	require.NoError(t, s.Handle("servers.web01.cpu 0.7 300"))
+	require.NoError(t, s.Handle("servers.web01.cpu 0.6 200"))
+	require.NoError(t, s.Handle("jobs.backup.duration 12 -1"))
+	assert.ErrorIs(t, s.Handle("jobs.backup 12 x"), ErrInvalidLine)
+	assert.ErrorIs(t, s.Handle("jobs..backup 12 100"), ErrInvalidLine)
+	assert.ErrorIs(t, s.Handle("jobs.backup"), ErrInvalidLine)
+	require.NoError(t, s.Flush())
+
+	_, gauges := st.GetMetrics()
+	assert.Equal(t, map[string]float64{`cpu{host="web01"}`: 0.7, "jobs.backup.duration": 12}, gauges)
+}
+
+// failingStorage fails every write while fail is set.
+type failingStorage struct {
+	*filememory.MemStorage
+	fail bool
+}
+
+func (s *failingStorage) InsertBatchMetrics(metrics []f.Metric) error {
+	if s.fail {
+		return errors.New("storage is down")
+	}
+	return s.MemStorage.InsertBatchMetrics(metrics)
+}
+
+func TestFlushFailureKeepsPoints(t *testing.T) {
+	st := &failingStorage{MemStorage: filememory.NewMemStorage(false, nil), fail: true}
+	s := NewServer(st, nil)
+
+	require.NoError(t, s.Handle("queue 5 100"))
+	require.NoError(t, s.Handle("load 1 100"))
+	require.Error(t, s.Flush())
+	require.NoError(t, s.Handle("load 2 200"))
+	st.fail = false
+	require.NoError(t, s.Flush())
+
+	_, gauges := st.GetMetrics()
+	assert.Equal(t, map[string]float64{"queue": 5, "load": 2}, gauges)
+}
+
+func TestFlushDropsRejectedPoints(t *testing.T) {
+	logger.LogInit("info")
+	st := metadata.NewStorage(filememory.NewMemStorage(false, nil), metadata.NewRegistry(""))
+	require.NoError(t, st.UpdateCounter("aaa", 1, true))
+	s := NewServer(st, nil)
+
+	require.NoError(t, s.Handle("aaa 1 100"))
+	require.NoError(t, s.Handle("zzz 5 100"))
+	require.NoError(t, s.Flush())
+	require.NoError(t, s.Flush())
+
+	_, gauges := st.GetMetrics()
+	assert.Equal(t, map[string]float64{"zzz": 5}, gauges)
+	assert.Empty(t, s.points)
+}
+
+func TestServeTCP(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	s := NewServer(st, nil)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go s.ServeTCP(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("legacy.cron.rows 42 1700000000\n"))
+	require.NoError(t, err)
+	conn.Close()
+
+	assert.Eventually(
+		t, func() bool {
+			require.NoError(t, s.Flush())
+			_, gauges := st.GetMetrics()
+			return gauges["legacy.cron.rows"] == 42
+		}, time.Second, 10*time.Millisecond,
+	)
+}
@@ -0,0 +1,110 @@
+package graphite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+)
+
+var ErrInvalidTemplate = errors.New("invalid graphite template")
+
+// Template maps the dotted path of a Graphite metric to a metric name and
+// labels. It is written as "[filter] pattern [label=value,...]":
+//
+//	servers.*.cpu .host.measurement*
+//
+// The filter selects paths by their components, where * matches any single
+// component. Each pattern component names the role of the path component at
+// the same position: "measurement" becomes part of the metric name,
+// "measurement*" takes the remaining components into the name, an empty
+// component skips the path component and any other word becomes a label
+// with that name. The optional last field adds fixed labels.
+type Template struct {
+	filter  []string
+	pattern []string
+	labels  []f.Label
+}
+
+// ParseTemplate parses a template in the form described on Template.
+func ParseTemplate(s string) (Template, error) {
+	var t Template
+	fields := strings.Fields(s)
+	if n := len(fields); n > 1 && strings.Contains(fields[n-1], "=") {
+		for _, pair := range strings.Split(fields[n-1], ",") {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok || name == "" {
+				return Template{}, fmt.Errorf("%w: label %q in %q", ErrInvalidTemplate, pair, s)
+			}
+			t.labels = append(t.labels, f.Label{Name: name, Value: value})
+		}
+		fields = fields[:n-1]
+	}
+	switch len(fields) {
+	case 1:
+		t.pattern = strings.Split(fields[0], ".")
+	case 2:
+		t.filter = strings.Split(fields[0], ".")
+		t.pattern = strings.Split(fields[1], ".")
+	default:
+		return Template{}, fmt.Errorf("%w: %q", ErrInvalidTemplate, s)
+	}
+
+	hasName := false
+	for i, part := range t.pattern {
+		switch part {
+		case "measurement":
+			hasName = true
+		case "measurement*":
+			if i != len(t.pattern)-1 {
+				return Template{}, fmt.Errorf("%w: measurement* must be last in %q", ErrInvalidTemplate, s)
+			}
+			hasName = true
+		}
+	}
+	if !hasName {
+		return Template{}, fmt.Errorf("%w: no measurement in %q", ErrInvalidTemplate, s)
+	}
+	return t, nil
+}
+
+// Match reports whether the template applies to the path components.
+func (t Template) Match(parts []string) bool {
+	if len(t.filter) > len(parts) {
+		return false
+	}
+	for i, filter := range t.filter {
+		if filter != "*" && filter != parts[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply returns the metric ID for the path components. Components beyond
+// the pattern are ignored unless the pattern ends with measurement*.
+func (t Template) Apply(parts []string) string {
+	var (
+		name   []string
+		labels = append([]f.Label(nil), t.labels...)
+	)
+	for i, part := range t.pattern {
+		if i >= len(parts) {
+			break
+		}
+		switch part {
+		case "":
+		case "measurement":
+			name = append(name, parts[i])
+		case "measurement*":
+			name = append(name, parts[i:]...)
+		default:
+			labels = append(labels, f.Label{Name: part, Value: parts[i]})
+		}
+	}
+	if len(name) == 0 {
+		return strings.Join(parts, ".")
+	}
+	return f.SeriesID(strings.Join(name, "."), labels)
+}
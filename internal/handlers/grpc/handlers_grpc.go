@@ -2,21 +2,58 @@ package grpc
 
 import (
 	"context"
-	"fmt"
+	"errors"
+	"io"
+
 	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
-	"github.com/elina-chertova/metrics-alerting.git/internal/config"
 	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
 	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // Handler encapsulates handling logic for metric-related HTTP endpoints.
 type Handler struct {
 	memStorage serviceInterface.MetricsStorage
+	tenants    *tenant.Registry
 }
 
 // NewHandler creates a new Handler with the given metrics storage.
 func NewHandler(st serviceInterface.MetricsStorage) *Handler {
-	return &Handler{st}
+	return &Handler{memStorage: st}
+}
+
+// NewTenantHandler creates a new Handler that serves every call from the
+// storage of the tenant resolved by the tenant interceptors.
+func NewTenantHandler(r *tenant.Registry) *Handler {
+	return &Handler{tenants: r}
+}
+
+// storage returns the metrics storage serving the call.
+func (h *Handler) storage(ctx context.Context) (serviceInterface.MetricsStorage, error) {
+	if h.tenants == nil {
+		return h.memStorage, nil
+	}
+	st, err := h.tenants.Storage(tenant.FromIncomingContext(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return st, nil
+}
+
+// storageError converts a storage error to a gRPC status error.
+func storageError(err error) error {
+	switch {
+	case errors.Is(err, tenant.ErrSeriesLimit), errors.Is(err, tenant.ErrRateLimit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, metadata.ErrTypeMismatch):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
 }
 
 type Server struct {
@@ -30,33 +67,48 @@ func (s *Server) UpdateBatchMetrics(
 	ctx context.Context,
 	req *pb.UpdateBatchMetricsRequest,
 ) (*pb.UpdateBatchMetricsResponse, error) {
-	metrics := make([]f.Metric, 0, len(req.Metrics))
-
-	for _, m := range req.Metrics {
-		var metric f.Metric
-
-		switch m.Type {
-		case pb.MetricType_GAUGE:
-			metric = f.Metric{
-				ID:    m.Id,
-				MType: config.Gauge,
-				Value: &m.Value,
-			}
-		case pb.MetricType_COUNTER:
-			metric = f.Metric{
-				ID:    m.Id,
-				MType: config.Counter,
-				Delta: &m.Delta,
-			}
-		default:
-			return &pb.UpdateBatchMetricsResponse{Status: "Failed"}, fmt.Errorf("unsupported metric type")
-		}
-
-		metrics = append(metrics, metric)
+	metrics, err := f.FromProto(req.Metrics)
+	if err != nil {
+		return &pb.UpdateBatchMetricsResponse{Status: "Failed"}, err
 	}
-	err := s.Handler.memStorage.InsertBatchMetrics(metrics)
+	st, err := s.Handler.storage(ctx)
 	if err != nil {
 		return &pb.UpdateBatchMetricsResponse{Status: "Failed"}, err
 	}
+	err = storage.InsertBatch(st, metrics)
+	if err != nil {
+		return &pb.UpdateBatchMetricsResponse{Status: "Failed"}, storageError(err)
+	}
 	return &pb.UpdateBatchMetricsResponse{Status: "Success"}, nil
 }
+
+// StreamMetrics stores the batches of a stream as they arrive and
+// acknowledges each of them. A batch that cannot be stored is acknowledged
+// with status "Failed" and the error; the stream stays open.
+func (s *Server) StreamMetrics(stream pb.MetricsService_StreamMetricsServer) error {
+	st, err := s.Handler.storage(stream.Context())
+	if err != nil {
+		return err
+	}
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ack := &pb.BatchAck{Seq: batch.Seq, Status: "Success"}
+		metrics, err := f.FromProto(batch.Metrics)
+		if err == nil {
+			err = storage.InsertBatch(st, metrics)
+		}
+		if err != nil {
+			ack.Status, ack.Error = "Failed", err.Error()
+		}
+		if err = stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,355 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/elina-chertova/metrics-alerting.git/api/otlp"
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+)
+
+const (
+	// flagNoRecordedValue marks a data point that carries no value.
+	flagNoRecordedValue = 1
+	deltaTemporality    = otlp.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	// seriesLocks is the number of mutexes the series of Export calls are
+	// spread over.
+	seriesLocks = 64
+)
+
+// OTLPServer receives metrics from OpenTelemetry SDKs and collectors
+// through the OTLP MetricsService/Export call.
+//
+// Monotonic sums become counters and all other sums and gauges become
+// gauges. Histograms are stored the way Prometheus exposes them: the
+// counters name_bucket{le="..."} and name_count and the gauge name_sum.
+// Resource and data point attributes become labels of the metric IDs, the
+// data point attributes win on conflicts. Cumulative values replace the
+// stored ones while delta values are added to them; a cumulative counter
+// lower than the stored one is taken as a reset and added as a whole.
+// Export calls updating the same series are serialized, so that two of
+// them do not compute their deltas from the same stored value.
+type OTLPServer struct {
+	otlp.UnimplementedMetricsServiceServer
+	Handler *Handler
+
+	locks [seriesLocks]sync.Mutex
+}
+
+func (s *OTLPServer) Export(
+	ctx context.Context,
+	req *otlp.ExportMetricsServiceRequest,
+) (*otlp.ExportMetricsServiceResponse, error) {
+	st, err := s.Handler.storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	b := newOTLPBatch()
+	for _, rm := range req.ResourceMetrics {
+		resource := attributeLabels(rm.GetResource().GetAttributes())
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				b.add(m, resource)
+			}
+		}
+	}
+
+	defer s.lock(b.stored())()
+	metrics, err := b.metrics(st)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if len(metrics) > 0 {
+		if err = storage.InsertBatch(st, metrics); err != nil {
+			return nil, storageError(err)
+		}
+	}
+	resp := &otlp.ExportMetricsServiceResponse{}
+	if b.rejected > 0 {
+		resp.PartialSuccess = &otlp.ExportMetricsPartialSuccess{
+			RejectedDataPoints: b.rejected,
+			ErrorMessage:       b.lastError,
+		}
+	}
+	return resp, nil
+}
+
+// lock locks the mutexes of the series ids in ascending order, so that
+// concurrent calls can not deadlock, and returns the function unlocking
+// them.
+func (s *OTLPServer) lock(ids []string) func() {
+	var locked [seriesLocks]bool
+	for _, id := range ids {
+		h := fnv.New32a()
+		h.Write([]byte(id))
+		locked[h.Sum32()%seriesLocks] = true
+	}
+	for i := range locked {
+		if locked[i] {
+			s.locks[i].Lock()
+		}
+	}
+	return func() {
+		for i := range locked {
+			if locked[i] {
+				s.locks[i].Unlock()
+			}
+		}
+	}
+}
+
+type otlpPoint struct {
+	value float64
+	time  uint64
+}
+
+// otlpBatch collects the data points of a request per metric ID. Points
+// of a cumulative series keep the latest value, delta points are summed.
+type otlpBatch struct {
+	counters      map[string]otlpPoint
+	counterDeltas map[string]float64
+	gauges        map[string]otlpPoint
+	gaugeDeltas   map[string]float64
+
+	rejected  int64
+	lastError string
+}
+
+func newOTLPBatch() *otlpBatch {
+	return &otlpBatch{
+		counters:      make(map[string]otlpPoint),
+		counterDeltas: make(map[string]float64),
+		gauges:        make(map[string]otlpPoint),
+		gaugeDeltas:   make(map[string]float64),
+	}
+}
+
+func (b *otlpBatch) reject(name string, reason string) {
+	b.rejected++
+	b.lastError = fmt.Sprintf("metric %q: %s", name, reason)
+}
+
+func (b *otlpBatch) add(m *otlp.Metric, resource []f.Label) {
+	if m.Name == "" {
+		b.reject(m.Name, "missing name")
+		return
+	}
+	switch data := m.Data.(type) {
+	case *otlp.Metric_Gauge:
+		for _, p := range data.Gauge.DataPoints {
+			b.addNumber(m.Name, resource, p, false, false)
+		}
+	case *otlp.Metric_Sum:
+		delta := data.Sum.AggregationTemporality == deltaTemporality
+		for _, p := range data.Sum.DataPoints {
+			b.addNumber(m.Name, resource, p, data.Sum.IsMonotonic, delta)
+		}
+	case *otlp.Metric_Histogram:
+		delta := data.Histogram.AggregationTemporality == deltaTemporality
+		for _, p := range data.Histogram.DataPoints {
+			b.addHistogram(m.Name, resource, p, delta)
+		}
+	default:
+		b.reject(m.Name, "unsupported metric type")
+	}
+}
+
+func (b *otlpBatch) addNumber(
+	name string,
+	resource []f.Label,
+	p *otlp.NumberDataPoint,
+	counter, delta bool,
+) {
+	if p.Flags&flagNoRecordedValue != 0 {
+		return
+	}
+	var value float64
+	switch v := p.Value.(type) {
+	case *otlp.NumberDataPoint_AsDouble:
+		value = v.AsDouble
+	case *otlp.NumberDataPoint_AsInt:
+		value = float64(v.AsInt)
+	default:
+		b.reject(name, "data point without value")
+		return
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		b.reject(name, "NaN and infinity are not supported")
+		return
+	}
+	id := f.SeriesID(name, mergeLabels(resource, attributeLabels(p.Attributes)))
+	if counter {
+		b.set(b.counters, b.counterDeltas, id, value, p.TimeUnixNano, delta)
+	} else {
+		b.set(b.gauges, b.gaugeDeltas, id, value, p.TimeUnixNano, delta)
+	}
+}
+
+func (b *otlpBatch) addHistogram(
+	name string,
+	resource []f.Label,
+	p *otlp.HistogramDataPoint,
+	delta bool,
+) {
+	if p.Flags&flagNoRecordedValue != 0 {
+		return
+	}
+	if len(p.BucketCounts) != 0 && len(p.BucketCounts) != len(p.ExplicitBounds)+1 {
+		b.reject(name, "bucket counts do not match the bounds")
+		return
+	}
+	labels := mergeLabels(resource, attributeLabels(p.Attributes))
+	var cumulative uint64
+	for i, count := range p.BucketCounts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(p.ExplicitBounds) {
+			le = strconv.FormatFloat(p.ExplicitBounds[i], 'g', -1, 64)
+		}
+		bucketLabels := append(append([]f.Label(nil), labels...), f.Label{Name: "le", Value: le})
+		id := f.SeriesID(name+"_bucket", bucketLabels)
+		b.set(b.counters, b.counterDeltas, id, float64(cumulative), p.TimeUnixNano, delta)
+	}
+	countID := f.SeriesID(name+"_count", labels)
+	b.set(b.counters, b.counterDeltas, countID, float64(p.Count), p.TimeUnixNano, delta)
+	if p.Sum != nil {
+		sumID := f.SeriesID(name+"_sum", labels)
+		b.set(b.gauges, b.gaugeDeltas, sumID, *p.Sum, p.TimeUnixNano, delta)
+	}
+}
+
+func (b *otlpBatch) set(
+	latest map[string]otlpPoint,
+	deltas map[string]float64,
+	id string,
+	value float64,
+	time uint64,
+	delta bool,
+) {
+	if delta {
+		deltas[id] += value
+		return
+	}
+	if p, ok := latest[id]; !ok || time >= p.time {
+		latest[id] = otlpPoint{value: value, time: time}
+	}
+}
+
+// stored returns the IDs of the series whose new values depend on the
+// stored ones: cumulative counters and gauges with delta points.
+func (b *otlpBatch) stored() []string {
+	ids := make([]string, 0, len(b.counters)+len(b.gaugeDeltas))
+	for id := range b.counters {
+		ids = append(ids, id)
+	}
+	for id := range b.gaugeDeltas {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// metrics resolves the collected points against the current values in st.
+func (b *otlpBatch) metrics(st serviceInterface.MetricsStorage) ([]f.Metric, error) {
+	counters := make(map[string]int64)
+	for id, p := range b.counters {
+		current, _, err := st.GetCounter(id)
+		if err != nil {
+			return nil, err
+		}
+		value := int64(math.Round(p.value))
+		if value >= current {
+			value -= current
+		}
+		counters[id] = value
+	}
+	for id, value := range b.counterDeltas {
+		counters[id] += int64(math.Round(value))
+	}
+
+	gauges := make(map[string]float64)
+	for id, p := range b.gauges {
+		gauges[id] = p.value
+	}
+	for id, value := range b.gaugeDeltas {
+		if _, ok := gauges[id]; !ok {
+			current, _, err := st.GetGauge(id)
+			if err != nil {
+				return nil, err
+			}
+			gauges[id] = current
+		}
+		gauges[id] += value
+	}
+
+	metrics := make([]f.Metric, 0, len(counters)+len(gauges))
+	for id, delta := range counters {
+		delta := delta
+		metrics = append(metrics, f.Metric{ID: id, MType: config.Counter, Delta: &delta})
+	}
+	for id, value := range gauges {
+		value := value
+		metrics = append(metrics, f.Metric{ID: id, MType: config.Gauge, Value: &value})
+	}
+	sort.Slice(
+		metrics, func(i, j int) bool {
+			return metrics[i].ID < metrics[j].ID
+		},
+	)
+	return metrics, nil
+}
+
+// attributeLabels converts OTLP attributes to labels. Arrays, maps and
+// empty values have no label representation and are skipped.
+func attributeLabels(attributes []*otlp.KeyValue) []f.Label {
+	labels := make([]f.Label, 0, len(attributes))
+	for _, kv := range attributes {
+		var value string
+		switch v := kv.GetValue().GetValue().(type) {
+		case *otlp.AnyValue_StringValue:
+			value = v.StringValue
+		case *otlp.AnyValue_BoolValue:
+			value = strconv.FormatBool(v.BoolValue)
+		case *otlp.AnyValue_IntValue:
+			value = strconv.FormatInt(v.IntValue, 10)
+		case *otlp.AnyValue_DoubleValue:
+			value = strconv.FormatFloat(v.DoubleValue, 'g', -1, 64)
+		case *otlp.AnyValue_BytesValue:
+			value = base64.StdEncoding.EncodeToString(v.BytesValue)
+		default:
+			continue
+		}
+		if kv.Key != "" {
+			labels = append(labels, f.Label{Name: kv.Key, Value: value})
+		}
+	}
+	return labels
+}
+
+// mergeLabels returns the union of both label sets, labels wins over base.
+func mergeLabels(base, labels []f.Label) []f.Label {
+	merged := append([]f.Label(nil), labels...)
+	for _, l := range base {
+		if !hasLabel(labels, l.Name) {
+			merged = append(merged, l)
+		}
+	}
+	return merged
+}
+
+func hasLabel(labels []f.Label, name string) bool {
+	for _, l := range labels {
+		if l.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package grpc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/api/otlp"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func stringAttr(key, value string) *otlp.KeyValue {
+	return &otlp.KeyValue{
+		Key:   key,
+		Value: &otlp.AnyValue{Value: &otlp.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+func sum(
+	temporality otlp.AggregationTemporality,
+	monotonic bool,
+	points ...*otlp.NumberDataPoint,
+) *otlp.Metric_Sum {
+	return &otlp.Metric_Sum{
+		Sum: &otlp.Sum{
+			DataPoints:             points,
+			AggregationTemporality: temporality,
+			IsMonotonic:            monotonic,
+		},
+	}
+}
+
+func intPoint(value int64, time uint64, attributes ...*otlp.KeyValue) *otlp.NumberDataPoint {
+	return &otlp.NumberDataPoint{
+		Attributes:   attributes,
+		TimeUnixNano: time,
+		Value:        &otlp.NumberDataPoint_AsInt{AsInt: value},
+	}
+}
+
+func exportRequest(metrics ...*otlp.Metric) *otlp.ExportMetricsServiceRequest {
+	return &otlp.ExportMetricsServiceRequest{
+		ResourceMetrics: []*otlp.ResourceMetrics{
+			{
+				Resource: &otlp.Resource{
+					Attributes: []*otlp.KeyValue{
+						stringAttr("service", "api"),
+						{Key: "nested", Value: &otlp.AnyValue{Value: &otlp.AnyValue_ArrayValue{}}},
+					},
+				},
+				ScopeMetrics: []*otlp.ScopeMetrics{{Metrics: metrics}},
+			},
+		},
+	}
+}
+
+func TestOTLPServerExport(t *testing.T) {
+	const (
+		cumulative = otlp.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+		delta      = otlp.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	)
+	st := filememory.NewMemStorage(false, nil)
+	s := &OTLPServer{Handler: NewHandler(st)}
+	histogramSum := 4.5
+
+	req := exportRequest(
+		&otlp.Metric{
+			Name: "requests",
+			Data: sum(
+				cumulative, true,
+				intPoint(5, 1, stringAttr("code", "200")),
+				intPoint(7, 2, stringAttr("code", "200")),
+			),
+		},
+		&otlp.Metric{Name: "errors", Data: sum(delta, true, intPoint(2, 1), intPoint(3, 2))},
+		&otlp.Metric{
+			Name: "queue",
+			Data: sum(cumulative, false, intPoint(4, 1, stringAttr("service", "worker"))),
+		},
+		&otlp.Metric{
+			Name: "temperature",
+			Data: &otlp.Metric_Gauge{
+				Gauge: &otlp.Gauge{
+					DataPoints: []*otlp.NumberDataPoint{
+						{Value: &otlp.NumberDataPoint_AsDouble{AsDouble: 21.5}},
+					},
+				},
+			},
+		},
+		&otlp.Metric{
+			Name: "latency",
+			Data: &otlp.Metric_Histogram{
+				Histogram: &otlp.Histogram{
+					AggregationTemporality: cumulative,
+					DataPoints: []*otlp.HistogramDataPoint{
+						{
+							Count:          3,
+							Sum:            &histogramSum,
+							BucketCounts:   []uint64{1, 2},
+							ExplicitBounds: []float64{0.5},
+						},
+					},
+				},
+			},
+		},
+		&otlp.Metric{
+			Name: "broken",
+			Data: &otlp.Metric_Gauge{Gauge: &otlp.Gauge{DataPoints: []*otlp.NumberDataPoint{{}}}},
+		},
+	)
+	resp, err := s.Export(context.Background(), req)
+	require.NoError(t, err)
+	require.NotNil(t, resp.PartialSuccess)
+	assert.Equal(t, int64(1), resp.PartialSuccess.RejectedDataPoints)
+
+	counters, gauges := st.GetMetrics()
+	assert.Equal(
+		t, map[string]int64{
+			`requests{code="200",service="api"}`:      7,
+			`errors{service="api"}`:                   5,
+			`latency_bucket{le="0.5",service="api"}`:  1,
+			`latency_bucket{le="+Inf",service="api"}`: 3,
+			`latency_count{service="api"}`:            3,
+		}, counters,
+	)
+	assert.Equal(
+		t, map[string]float64{
+			`queue{service="worker"}`:    4,
+			`temperature{service="api"}`: 21.5,
+			`latency_sum{service="api"}`: 4.5,
+		}, gauges,
+	)
+
+	// A cumulative counter replaces the stored value, a delta is added.
+	_, err = s.Export(
+		context.Background(), exportRequest(
+			&otlp.Metric{
+				Name: "requests",
+				Data: sum(cumulative, true, intPoint(10, 3, stringAttr("code", "200"))),
+			},
+			&otlp.Metric{Name: "errors", Data: sum(delta, true, intPoint(1, 3))},
+		),
+	)
+	require.NoError(t, err)
+	counters, _ = st.GetMetrics()
+	assert.Equal(t, int64(10), counters[`requests{code="200",service="api"}`])
+	assert.Equal(t, int64(6), counters[`errors{service="api"}`])
+}
+
+// slowStorage delays reading counters, so that concurrent exports read
+// them before any of them writes.
+type slowStorage struct {
+	*filememory.MemStorage
+}
+
+func (s slowStorage) GetCounter(name string) (int64, bool, error) {
+	time.Sleep(10 * time.Millisecond)
+	return s.MemStorage.GetCounter(name)
+}
+
+func TestOTLPServerExportConcurrentCumulative(t *testing.T) {
+	st := slowStorage{filememory.NewMemStorage(false, nil)}
+	s := &OTLPServer{Handler: NewHandler(st)}
+	req := exportRequest(
+		&otlp.Metric{
+			Name: "requests",
+			Data: sum(otlp.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE, true, intPoint(5, 1)),
+		},
+	)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Export(context.Background(), req)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	// Every export reports the same total, so it is stored once.
+	value, ok, err := st.GetCounter(`requests{service="api"}`)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(5), value)
+}
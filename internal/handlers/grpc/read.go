@@ -0,0 +1,200 @@
+package grpc
+
+import (
+	"context"
+	"encoding/base64"
+	"regexp"
+	"strings"
+	"time"
+
+	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 1000
+	defaultRange    = time.Hour
+)
+
+// metricType returns the storage type of a protobuf metric type.
+func metricType(t pb.MetricType) (string, error) {
+	switch t {
+	case pb.MetricType_COUNTER:
+		return config.Counter, nil
+	case pb.MetricType_GAUGE:
+		return config.Gauge, nil
+	default:
+		return "", status.Error(codes.InvalidArgument, "unsupported metric type")
+	}
+}
+
+// GetMetric returns the current value of a metric, like the /value/
+// handlers of the REST API.
+func (s *Server) GetMetric(ctx context.Context, req *pb.GetMetricRequest) (*pb.Metric, error) {
+	mType, err := metricType(req.Type)
+	if err != nil {
+		return nil, err
+	}
+	st, err := s.Handler.storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &pb.Metric{Id: req.Id, Type: req.Type}
+	var ok bool
+	if mType == config.Counter {
+		m.Delta, ok, err = st.GetCounter(req.Id)
+	} else {
+		m.Value, ok, err = st.GetGauge(req.Id)
+	}
+	if err != nil {
+		return nil, storageError(err)
+	}
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "metric %q not found", req.Id)
+	}
+	return m, nil
+}
+
+// ListMetrics returns a page of the stored metrics, see ListMetricsRequest.
+// The listing is served by storage.List, so backends that page metrics
+// themselves do not load all of them.
+func (s *Server) ListMetrics(
+	ctx context.Context,
+	req *pb.ListMetricsRequest,
+) (*pb.ListMetricsResponse, error) {
+	opts := storage.ListOptions{Prefix: req.Prefix}
+	if req.Type != pb.MetricType_UNKNOWN {
+		mType, err := metricType(req.Type)
+		if err != nil {
+			return nil, err
+		}
+		opts.Type = mType
+	}
+	pattern, err := compilePattern(req.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	opts.Pattern = pattern
+	if opts.After, err = decodePageToken(req.PageToken); err != nil {
+		return nil, err
+	}
+	size := int(req.PageSize)
+	switch {
+	case size < 0:
+		return nil, status.Error(codes.InvalidArgument, "negative page size")
+	case size == 0:
+		size = defaultPageSize
+	case size > maxPageSize:
+		size = maxPageSize
+	}
+	// One more metric tells whether there is a next page.
+	opts.Limit = size + 1
+	st, err := s.Handler.storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics, err := storage.List(st, opts)
+	if err != nil {
+		return nil, storageError(err)
+	}
+	resp := &pb.ListMetricsResponse{}
+	if len(metrics) > size {
+		metrics = metrics[:size]
+		last := metrics[size-1]
+		resp.NextPageToken = encodePageToken(storage.Key{ID: last.ID, MType: last.MType})
+	}
+	resp.Metrics = make([]*pb.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		metric := &pb.Metric{Id: m.ID}
+		if m.MType == config.Counter {
+			metric.Type, metric.Delta = pb.MetricType_COUNTER, *m.Delta
+		} else {
+			metric.Type, metric.Value = pb.MetricType_GAUGE, *m.Value
+		}
+		resp.Metrics = append(resp.Metrics, metric)
+	}
+	return resp, nil
+}
+
+// compilePattern compiles an RE2 pattern that has to match whole IDs, or
+// returns nil for an empty pattern.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return re, nil
+}
+
+// encodePageToken returns the page token of a listing continuing after k.
+func encodePageToken(k storage.Key) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(k.MType + ":" + k.ID))
+}
+
+// decodePageToken returns the key of a page token created by
+// encodePageToken, or nil for an empty token.
+func decodePageToken(token string) (*storage.Key, error) {
+	if token == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid page token")
+	}
+	mType, id, ok := strings.Cut(string(b), ":")
+	if !ok || (mType != config.Counter && mType != config.Gauge) {
+		return nil, status.Error(codes.InvalidArgument, "invalid page token")
+	}
+	return &storage.Key{ID: id, MType: mType}, nil
+}
+
+// QueryRange returns the history of a metric. It fails with
+// FailedPrecondition when the server keeps no history.
+func (s *Server) QueryRange(
+	ctx context.Context,
+	req *pb.QueryRangeRequest,
+) (*pb.QueryRangeResponse, error) {
+	mType, err := metricType(req.Type)
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now()
+	if req.End != 0 {
+		end = time.UnixMilli(req.End)
+	}
+	start := end.Add(-defaultRange)
+	if req.Start != 0 {
+		start = time.UnixMilli(req.Start)
+	}
+	if start.After(end) {
+		return nil, status.Error(codes.InvalidArgument, "start is after end")
+	}
+	st, err := s.Handler.storage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	h := history.Find(st)
+	if h == nil {
+		return nil, status.Error(codes.FailedPrecondition, "metric history is disabled")
+	}
+
+	samples := h.Range(mType, req.Id, start, end)
+	resp := &pb.QueryRangeResponse{Samples: make([]*pb.Sample, 0, len(samples))}
+	for _, sample := range samples {
+		resp.Samples = append(
+			resp.Samples,
+			&pb.Sample{Timestamp: sample.Time.UnixMilli(), Value: sample.Value},
+		)
+	}
+	return resp, nil
+}
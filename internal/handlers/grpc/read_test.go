@@ -0,0 +1,175 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
+	"github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServerGetMetric(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	require.NoError(t, st.UpdateGauge("Alloc", 1.5))
+	require.NoError(t, st.UpdateCounter("PollCount", 3, false))
+	s := &Server{Handler: NewHandler(st)}
+
+	tests := []struct {
+		name     string
+		req      *pb.GetMetricRequest
+		expected *pb.Metric
+		code     codes.Code
+	}{
+		{
+			name:     "Gauge",
+			req:      &pb.GetMetricRequest{Id: "Alloc", Type: pb.MetricType_GAUGE},
+			expected: &pb.Metric{Id: "Alloc", Type: pb.MetricType_GAUGE, Value: 1.5},
+		},
+		{
+			name:     "Counter",
+			req:      &pb.GetMetricRequest{Id: "PollCount", Type: pb.MetricType_COUNTER},
+			expected: &pb.Metric{Id: "PollCount", Type: pb.MetricType_COUNTER, Delta: 3},
+		},
+		{
+			name: "Not Found",
+			req:  &pb.GetMetricRequest{Id: "Alloc", Type: pb.MetricType_COUNTER},
+			code: codes.NotFound,
+		},
+		{name: "Unknown Type", req: &pb.GetMetricRequest{Id: "Alloc"}, code: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				m, err := s.GetMetric(context.Background(), tt.req)
+				assert.Equal(t, tt.code, status.Code(err))
+				if tt.expected != nil {
+					assert.Equal(t, tt.expected.String(), m.String())
+				}
+			},
+		)
+	}
+}
+
+func TestServerListMetrics(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	for _, id := range []string{"HeapAlloc", "HeapSys", "Alloc", "Sys"} {
+		require.NoError(t, st.UpdateGauge(id, 1))
+	}
+	require.NoError(t, st.UpdateCounter("Alloc", 1, false))
+	s := &Server{Handler: NewHandler(st)}
+
+	ids := func(req *pb.ListMetricsRequest) ([]string, string) {
+		resp, err := s.ListMetrics(context.Background(), req)
+		require.NoError(t, err)
+		var result []string
+		for _, m := range resp.Metrics {
+			result = append(result, m.Type.String()+":"+m.Id)
+		}
+		return result, resp.NextPageToken
+	}
+
+	all, token := ids(&pb.ListMetricsRequest{})
+	assert.Equal(
+		t,
+		[]string{"COUNTER:Alloc", "GAUGE:Alloc", "GAUGE:HeapAlloc", "GAUGE:HeapSys", "GAUGE:Sys"},
+		all,
+	)
+	assert.Empty(t, token)
+
+	page, token := ids(&pb.ListMetricsRequest{PageSize: 2})
+	assert.Equal(t, []string{"COUNTER:Alloc", "GAUGE:Alloc"}, page)
+	page, token = ids(&pb.ListMetricsRequest{PageSize: 2, PageToken: token})
+	assert.Equal(t, []string{"GAUGE:HeapAlloc", "GAUGE:HeapSys"}, page)
+	page, token = ids(&pb.ListMetricsRequest{PageSize: 2, PageToken: token})
+	assert.Equal(t, []string{"GAUGE:Sys"}, page)
+	assert.Empty(t, token)
+
+	filtered, _ := ids(&pb.ListMetricsRequest{Type: pb.MetricType_GAUGE, Prefix: "Heap", Pattern: ".*Sys"})
+	assert.Equal(t, []string{"GAUGE:HeapSys"}, filtered)
+
+	_, err := s.ListMetrics(context.Background(), &pb.ListMetricsRequest{Pattern: "("})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = s.ListMetrics(context.Background(), &pb.ListMetricsRequest{PageToken: "Alloc"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// lister is a storage listing its metrics itself, like the database.
+type lister struct {
+	*filememory.MemStorage
+	opts storage.ListOptions
+}
+
+func (l *lister) ListMetrics(opts storage.ListOptions) ([]formatter.Metric, error) {
+	l.opts = opts
+	value := 1.0
+	return []formatter.Metric{{ID: "Alloc", MType: "gauge", Value: &value}}, nil
+}
+
+func TestServerListMetricsLister(t *testing.T) {
+	l := &lister{MemStorage: filememory.NewMemStorage(false, nil)}
+	s := &Server{Handler: NewHandler(l)}
+
+	resp, err := s.ListMetrics(
+		context.Background(),
+		&pb.ListMetricsRequest{
+			Type:      pb.MetricType_GAUGE,
+			Prefix:    "A",
+			Pattern:   "A.*",
+			PageSize:  5,
+			PageToken: encodePageToken(storage.Key{ID: "A", MType: "counter"}),
+		},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []*pb.Metric{{Id: "Alloc", Type: pb.MetricType_GAUGE, Value: 1}}, resp.Metrics)
+	assert.Empty(t, resp.NextPageToken)
+
+	assert.Equal(t, "gauge", l.opts.Type)
+	assert.Equal(t, "A", l.opts.Prefix)
+	assert.Equal(t, "^(?:A.*)$", l.opts.Pattern.String())
+	assert.Equal(t, &storage.Key{ID: "A", MType: "counter"}, l.opts.After)
+	assert.Equal(t, 6, l.opts.Limit)
+}
+
+func TestServerQueryRange(t *testing.T) {
+	_, err := (&Server{Handler: NewHandler(filememory.NewMemStorage(false, nil))}).QueryRange(
+		context.Background(), &pb.QueryRangeRequest{Id: "Alloc", Type: pb.MetricType_GAUGE},
+	)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	st := history.New(filememory.NewMemStorage(false, nil), time.Hour)
+	require.NoError(t, st.UpdateGauge("Alloc", 1))
+	require.NoError(t, st.UpdateGauge("Alloc", 2))
+	s := &Server{Handler: NewHandler(st)}
+
+	resp, err := s.QueryRange(
+		context.Background(), &pb.QueryRangeRequest{Id: "Alloc", Type: pb.MetricType_GAUGE},
+	)
+	require.NoError(t, err)
+	require.Len(t, resp.Samples, 2)
+	assert.Equal(t, []float64{1, 2}, []float64{resp.Samples[0].Value, resp.Samples[1].Value})
+
+	resp, err = s.QueryRange(
+		context.Background(),
+		&pb.QueryRangeRequest{
+			Id:   "Alloc",
+			Type: pb.MetricType_GAUGE,
+			End:  time.Now().Add(-time.Hour).UnixMilli(),
+		},
+	)
+	require.NoError(t, err)
+	assert.Empty(t, resp.Samples)
+
+	_, err = s.QueryRange(
+		context.Background(),
+		&pb.QueryRangeRequest{Id: "Alloc", Type: pb.MetricType_GAUGE, Start: 2, End: 1},
+	)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
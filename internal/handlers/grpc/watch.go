@@ -0,0 +1,60 @@
+package grpc
+
+import (
+	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/hub"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Watch streams the metric updates selected by req until the client
+// cancels the call. Updates are dropped while the client cannot keep up.
+func (s *Server) Watch(req *pb.WatchRequest, stream pb.MetricsService_WatchServer) error {
+	var mType string
+	if req.Type != pb.MetricType_UNKNOWN {
+		var err error
+		if mType, err = metricType(req.Type); err != nil {
+			return err
+		}
+	}
+	pattern, err := compilePattern(req.Pattern)
+	if err != nil {
+		return err
+	}
+	st, err := s.Handler.storage(stream.Context())
+	if err != nil {
+		return err
+	}
+	h := hub.Find(st)
+	if h == nil {
+		return status.Error(codes.FailedPrecondition, "metric updates are not published")
+	}
+
+	sub := h.Subscribe(
+		func(u hub.Update) bool {
+			return (mType == "" || u.Type == mType) && (pattern == nil || pattern.MatchString(u.ID))
+		},
+	)
+	defer sub.Close()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case u := <-sub.C:
+			if err := stream.Send(toUpdate(u)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func toUpdate(u hub.Update) *pb.MetricUpdate {
+	m := &pb.Metric{Id: u.ID}
+	if u.Type == config.Counter {
+		m.Type, m.Delta = pb.MetricType_COUNTER, u.Delta
+	} else {
+		m.Type, m.Value = pb.MetricType_GAUGE, u.Value
+	}
+	return &pb.MetricUpdate{Metric: m, Value: u.Value, Timestamp: u.Time.UnixMilli()}
+}
@@ -0,0 +1,77 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/hub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func watchClient(t *testing.T, s *Server) pb.MetricsServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterMetricsServiceServer(srv, s)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(
+			func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			},
+		),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMetricsServiceClient(conn)
+}
+
+func TestServerWatch(t *testing.T) {
+	h := hub.New(filememory.NewMemStorage(false, nil))
+	client := watchClient(t, &Server{Handler: NewHandler(h)})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &pb.WatchRequest{Pattern: "Heap.*"})
+	require.NoError(t, err)
+	// The server subscribes asynchronously, so update until one arrives.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				_ = h.UpdateGauge("Alloc", 1)
+				_ = h.UpdateGauge("HeapAlloc", 2)
+			}
+		}
+	}()
+	update, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, "HeapAlloc", update.Metric.Id)
+	assert.Equal(t, pb.MetricType_GAUGE, update.Metric.Type)
+	assert.Equal(t, 2.0, update.Value)
+
+	plain := watchClient(t, &Server{Handler: NewHandler(filememory.NewMemStorage(false, nil))})
+	stream, err = plain.Watch(ctx, &pb.WatchRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
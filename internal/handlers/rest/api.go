@@ -0,0 +1,469 @@
+package rest
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/expr"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// APIPrefix is the prefix of the versioned REST API. All of its routes
+// respond with JSON errors in an APIErrorResponse.
+const APIPrefix = "/api/v1"
+
+var (
+	ErrMetricNotFound   = errors.New("metric not found")
+	ErrInvalidParameter = errors.New("invalid query parameter")
+	ErrRouteNotFound    = errors.New("route not found")
+	ErrHistoryDisabled  = errors.New("metric history is disabled")
+	ErrUpdatesDisabled  = errors.New("metric updates are not published")
+)
+
+// ErrorCode identifies the kind of an API error, so that clients do not
+// have to match error messages.
+type ErrorCode string
+
+const (
+	CodeInvalidRequest  ErrorCode = "invalid_request"
+	CodeUnsupportedType ErrorCode = "unsupported_type"
+	CodeMissingValue    ErrorCode = "missing_value"
+	CodeNotFound        ErrorCode = "not_found"
+	CodeConflict        ErrorCode = "conflict"
+	CodeLimitExceeded   ErrorCode = "limit_exceeded"
+	CodeNotEnabled      ErrorCode = "not_enabled"
+	CodeInternal        ErrorCode = "internal"
+)
+
+// APIError is an error of the versioned REST API.
+type APIError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// APIErrorResponse is the body of every error response of the versioned
+// REST API.
+type APIErrorResponse struct {
+	Error APIError `json:"error"`
+}
+
+// UpdateRequest is the body of a request updating metrics.
+type UpdateRequest struct {
+	Metrics []f.Metric `json:"metrics"`
+}
+
+// MetricsResponse is the body of a response listing metrics. NextCursor
+// continues a listing that has more metrics.
+type MetricsResponse struct {
+	Metrics    []f.Metric `json:"metrics"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+const (
+	// DefaultListLimit is the number of metrics listed when no limit is given.
+	DefaultListLimit = 100
+	// MaxListLimit is the maximum number of metrics listed at once.
+	MaxListLimit = 1000
+)
+
+// apiErrorStatus returns the HTTP status and the code of err.
+func apiErrorStatus(err error) (int, ErrorCode) {
+	switch {
+	case errors.Is(err, ErrUnsupportedMetric), errors.Is(err, metadata.ErrUnsupportedType),
+		errors.Is(err, storage.ErrUnsupportedMetric):
+		return http.StatusBadRequest, CodeUnsupportedType
+	case errors.Is(err, ErrDeltaNil), errors.Is(err, ErrValueNil):
+		return http.StatusBadRequest, CodeMissingValue
+	case errors.Is(err, ErrInvalidJSON), errors.Is(err, ErrInvalidBody), errors.Is(err, ErrMissingID),
+		errors.Is(err, ErrInvalidParameter), errors.Is(err, ErrInvalidTime), errors.Is(err, ErrInvalidValue),
+		errors.Is(err, metadata.ErrEmptyName), errors.Is(err, expr.ErrType),
+		errors.Is(err, expr.ErrNotFinite):
+		return http.StatusBadRequest, CodeInvalidRequest
+	case errors.Is(err, ErrMetricNotFound), errors.Is(err, ErrMetadataNotFound),
+		errors.Is(err, ErrRouteNotFound):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, metadata.ErrTypeMismatch), errors.Is(err, ErrCounterExists):
+		return http.StatusConflict, CodeConflict
+	case errors.Is(err, tenant.ErrSeriesLimit), errors.Is(err, tenant.ErrRateLimit):
+		return http.StatusTooManyRequests, CodeLimitExceeded
+	case errors.Is(err, ErrMetadataDisabled), errors.Is(err, ErrHistoryDisabled),
+		errors.Is(err, ErrUpdatesDisabled), errors.Is(err, expr.ErrNoHistory):
+		return http.StatusNotImplemented, CodeNotEnabled
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
+
+// abortWithError logs err and responds with it in an APIErrorResponse.
+func abortWithError(c *gin.Context, err error) {
+	status, code := apiErrorStatus(err)
+	logger.Error(err.Error(), zap.String("method", c.Request.Method))
+	c.AbortWithStatusJSON(status, APIErrorResponse{Error: APIError{Code: code, Message: err.Error()}})
+}
+
+// isAPIRequest reports whether c is a request to the versioned REST API.
+func isAPIRequest(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, APIPrefix+"/")
+}
+
+// NotFoundHandler creates the gin.HandlerFunc of unknown routes. It
+// responds with an APIErrorResponse below APIPrefix and in plain text
+// elsewhere.
+func NotFoundHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if isAPIRequest(c) {
+			abortWithError(c, fmt.Errorf("%w: %s %s", ErrRouteNotFound, c.Request.Method, c.Request.URL.Path))
+			return
+		}
+		c.String(http.StatusNotFound, "Page not found")
+	}
+}
+
+// getMetric returns the stored metric of type mType with its metadata.
+func getMetric(st serviceInterface.MetricsStorage, mType, id string) (f.Metric, error) {
+	m := f.Metric{ID: id, MType: mType}
+	var ok bool
+	var err error
+	switch mType {
+	case config.Counter:
+		var delta int64
+		delta, ok, err = st.GetCounter(id)
+		m.Delta = &delta
+	case config.Gauge:
+		var value float64
+		value, ok, err = st.GetGauge(id)
+		m.Value = &value
+	default:
+		return m, ErrUnsupportedMetric
+	}
+	if err != nil {
+		return m, err
+	}
+	if !ok {
+		return m, fmt.Errorf("%w: %s %s", ErrMetricNotFound, mType, id)
+	}
+	return withMetadata(st, m), nil
+}
+
+// updateMetrics stores metrics and returns them with their values after
+// the update. All metrics are validated before any of them is stored. The
+// unit and description of the metrics are registered as their metadata.
+func updateMetrics(st serviceInterface.MetricsStorage, metrics []f.Metric) ([]f.Metric, error) {
+	for i, m := range metrics {
+		if err := validateMetric(m); err != nil {
+			return nil, fmt.Errorf("metrics[%d]: %w", i, err)
+		}
+	}
+	if err := storage.InsertBatch(st, metrics); err != nil {
+		return nil, err
+	}
+	for i, m := range metrics {
+		if err := registerMetadata(st, m); err != nil {
+			return nil, fmt.Errorf("metrics[%d]: %w", i, err)
+		}
+	}
+	updated := make([]f.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		stored, err := getMetric(st, m.MType, m.ID)
+		if err != nil {
+			return nil, err
+		}
+		updated = append(updated, stored)
+	}
+	return updated, nil
+}
+
+// registerMetadata registers the unit and description of m, once it has
+// been stored, in the metadata registry of st.
+func registerMetadata(st serviceInterface.MetricsStorage, m f.Metric) error {
+	registry := metadata.From(st)
+	if registry == nil || m.Unit == "" && m.Description == "" {
+		return nil
+	}
+	meta := metadata.Metadata{Name: m.ID, Type: m.MType, Unit: m.Unit, Description: m.Description}
+	return registry.Set(meta)
+}
+
+// APIUpdateHandler creates a gin.HandlerFunc that stores the metrics of an
+// UpdateRequest and responds with their values after the update. Nothing
+// is stored when one of the metrics is invalid.
+//
+// @Summary      Update metrics
+// @Description  Adds the deltas to counters and sets the values of gauges. Nothing is stored
+// @Description  when one of the metrics is invalid. The unit and description of a metric are
+// @Description  registered as its metadata.
+// @Tags         api
+// @Accept       json
+// @Produce      json
+// @Param        request  body      UpdateRequest  true  "Metrics with delta or value"
+// @Success      200      {object}  MetricsResponse
+// @Failure      400      {object}  APIErrorResponse
+// @Failure      409      {object}  APIErrorResponse
+// @Failure      429      {object}  APIErrorResponse
+// @Failure      500      {object}  APIErrorResponse
+// @Router       /api/v1/metrics [post]
+func (h *Handler) APIUpdateHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		var req UpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			abortWithError(c, fmt.Errorf("%w: %v", ErrInvalidJSON, err))
+			return
+		}
+		metrics, err := updateMetrics(st, req.Metrics)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, MetricsResponse{Metrics: metrics})
+	}
+}
+
+// APIListHandler creates a gin.HandlerFunc that responds with a page of
+// the stored metrics, selected and sorted by the query parameters.
+//
+// @Summary      List metrics
+// @Description  Lists the metrics sorted by ID and type. When more metrics are selected than
+// @Description  the limit, the response contains a next_cursor to pass as the cursor of the
+// @Description  request of the next page with the same parameters.
+// @Tags         api
+// @Produce      json
+// @Param        type    query     string   false  "Metric type"  Enums(counter, gauge)
+// @Param        prefix  query     string   false  "Prefix of the metric IDs"
+// @Param        match   query     string   false  "Regular expression matching the metric IDs"
+// @Param        sort    query     string   false  "Sort order"  Enums(name, -name)  default(name)
+// @Param        limit   query     integer  false  "Page size"  default(100)  maximum(1000)
+// @Param        cursor  query     string   false  "next_cursor of the previous page"
+// @Success      200     {object}  MetricsResponse
+// @Failure      400     {object}  APIErrorResponse
+// @Failure      500     {object}  APIErrorResponse
+// @Router       /api/v1/metrics [get]
+func (h *Handler) APIListHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		opts, err := listOptions(c)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		limit := opts.Limit
+		// One more metric tells whether there is a next page.
+		opts.Limit++
+		metrics, err := storage.List(st, opts)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		var response MetricsResponse
+		if len(metrics) > limit {
+			metrics = metrics[:limit]
+			last := metrics[limit-1]
+			response.NextCursor = encodeCursor(storage.Key{ID: last.ID, MType: last.MType})
+		}
+		response.Metrics = make([]f.Metric, 0, len(metrics))
+		for _, m := range metrics {
+			response.Metrics = append(response.Metrics, withMetadata(st, m))
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// listOptions returns the storage.ListOptions of the query parameters of
+// APIListHandler.
+func listOptions(c *gin.Context) (storage.ListOptions, error) {
+	opts := storage.ListOptions{
+		Type:   c.Query("type"),
+		Prefix: c.Query("prefix"),
+		Limit:  DefaultListLimit,
+	}
+	if opts.Type != "" && opts.Type != config.Counter && opts.Type != config.Gauge {
+		return opts, fmt.Errorf("%w: %s", ErrUnsupportedMetric, opts.Type)
+	}
+	if match := c.Query("match"); match != "" {
+		pattern, err := regexp.Compile(match)
+		if err != nil {
+			return opts, fmt.Errorf("%w match: %v", ErrInvalidParameter, err)
+		}
+		opts.Pattern = pattern
+	}
+	switch c.DefaultQuery("sort", "name") {
+	case "name":
+	case "-name":
+		opts.Descending = true
+	default:
+		return opts, fmt.Errorf("%w: sort", ErrInvalidParameter)
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > MaxListLimit {
+			return opts, fmt.Errorf("%w: limit", ErrInvalidParameter)
+		}
+		opts.Limit = n
+	}
+	if v := c.Query("cursor"); v != "" {
+		after, err := decodeCursor(v)
+		if err != nil {
+			return opts, fmt.Errorf("%w: cursor", ErrInvalidParameter)
+		}
+		opts.After = &after
+	}
+	return opts, nil
+}
+
+// encodeCursor returns the cursor of a listing continuing after k.
+func encodeCursor(k storage.Key) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(k.MType + ":" + k.ID))
+}
+
+// decodeCursor returns the key of a cursor created by encodeCursor.
+func decodeCursor(cursor string) (storage.Key, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return storage.Key{}, err
+	}
+	mType, id, ok := strings.Cut(string(b), ":")
+	if !ok || (mType != config.Counter && mType != config.Gauge) {
+		return storage.Key{}, errors.New("malformed cursor")
+	}
+	return storage.Key{ID: id, MType: mType}, nil
+}
+
+// APIMetricHandler creates a gin.HandlerFunc that responds with a stored
+// metric and its metadata.
+//
+// @Summary      Get a metric
+// @Tags         api
+// @Produce      json
+// @Param        type  path      string  true  "Metric type"  Enums(counter, gauge)
+// @Param        id    path      string  true  "Metric ID, which may contain slashes"
+// @Success      200   {object}  formatter.Metric
+// @Failure      400   {object}  APIErrorResponse
+// @Failure      404   {object}  APIErrorResponse
+// @Failure      500   {object}  APIErrorResponse
+// @Router       /api/v1/metrics/{type}/{id} [get]
+func (h *Handler) APIMetricHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		m, err := getMetric(st, c.Param("type"), strings.TrimPrefix(c.Param("id"), "/"))
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// metadataRegistry returns the metadata registry of the storage serving
+// the request, or responds with ErrMetadataDisabled and returns nil.
+func (h *Handler) metadataRegistry(c *gin.Context) *metadata.Registry {
+	st := h.storage(c)
+	if st == nil {
+		return nil
+	}
+	registry := metadata.From(st)
+	if registry == nil {
+		abortWithError(c, ErrMetadataDisabled)
+	}
+	return registry
+}
+
+// APIListMetadataHandler creates a gin.HandlerFunc that responds with the
+// metadata of all registered metric names.
+//
+// @Summary      List metric metadata
+// @Tags         api
+// @Produce      json
+// @Success      200  {array}   metadata.Metadata
+// @Failure      501  {object}  APIErrorResponse
+// @Router       /api/v1/metadata [get]
+func (h *Handler) APIListMetadataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if registry := h.metadataRegistry(c); registry != nil {
+			c.JSON(http.StatusOK, registry.List())
+		}
+	}
+}
+
+// APIGetMetadataHandler creates a gin.HandlerFunc that responds with the
+// metadata of a metric name.
+//
+// @Summary      Get the metadata of a metric
+// @Tags         api
+// @Produce      json
+// @Param        name  path      string  true  "Metric name"
+// @Success      200   {object}  metadata.Metadata
+// @Failure      404   {object}  APIErrorResponse
+// @Failure      501   {object}  APIErrorResponse
+// @Router       /api/v1/metadata/{name} [get]
+func (h *Handler) APIGetMetadataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registry := h.metadataRegistry(c)
+		if registry == nil {
+			return
+		}
+		m, ok := registry.Get(c.Param("name"))
+		if !ok {
+			abortWithError(c, fmt.Errorf("%w: %s", ErrMetadataNotFound, c.Param("name")))
+			return
+		}
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// APISetMetadataHandler creates a gin.HandlerFunc that registers the unit,
+// description and type of a metric name.
+//
+// @Summary      Set the metadata of a metric
+// @Description  Changing a declared type is rejected with the conflict code.
+// @Tags         api
+// @Accept       json
+// @Produce      json
+// @Param        name      path      string             true  "Metric name"
+// @Param        metadata  body      metadata.Metadata  true  "Unit, description and type"
+// @Success      200       {object}  metadata.Metadata
+// @Failure      400       {object}  APIErrorResponse
+// @Failure      409       {object}  APIErrorResponse
+// @Failure      501       {object}  APIErrorResponse
+// @Router       /api/v1/metadata/{name} [put]
+func (h *Handler) APISetMetadataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		registry := h.metadataRegistry(c)
+		if registry == nil {
+			return
+		}
+		var m metadata.Metadata
+		if err := c.ShouldBindJSON(&m); err != nil {
+			abortWithError(c, fmt.Errorf("%w: %v", ErrInvalidJSON, err))
+			return
+		}
+		m.Name = c.Param("name")
+		if err := registry.Set(m); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		m, _ = registry.Get(m.Name)
+		c.JSON(http.StatusOK, m)
+	}
+}
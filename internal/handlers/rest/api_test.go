@@ -0,0 +1,303 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPIRouter(h *Handler) *gin.Engine {
+	router := gin.New()
+	api := router.Group(APIPrefix)
+	api.GET("/metrics", h.APIListHandler())
+	api.POST("/metrics", h.APIUpdateHandler())
+	api.GET("/metrics/:type/*id", h.APIMetricHandler())
+	api.GET("/metadata", h.APIListMetadataHandler())
+	api.GET("/metadata/:name", h.APIGetMetadataHandler())
+	api.PUT("/metadata/:name", h.APISetMetadataHandler())
+	api.GET("/render", h.RenderHandler())
+	router.NoRoute(NotFoundHandler())
+	return router
+}
+
+func TestAPIHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := metadata.NewStorage(filememory.NewMemStorage(false, nil), metadata.NewRegistry(""))
+	router := newAPIRouter(NewHandler(st))
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		expected     int
+		code         ErrorCode
+		expectedBody string
+	}{
+		{
+			name:   "Update",
+			method: http.MethodPost,
+			path:   "/api/v1/metrics",
+			body: `{"metrics":[{"id":"PollCount","type":"counter","delta":2},` +
+				`{"id":"PollCount","type":"counter","delta":3},` +
+				`{"id":"Alloc","type":"gauge","value":1.5,"unit":"bytes"}]}`,
+			expected:     http.StatusOK,
+			expectedBody: `"delta":5`,
+		},
+		{
+			name:         "Get Counter",
+			method:       http.MethodGet,
+			path:         "/api/v1/metrics/counter/PollCount",
+			expected:     http.StatusOK,
+			expectedBody: `{"id":"PollCount","type":"counter","delta":5}`,
+		},
+		{
+			name:         "Get Gauge With Metadata",
+			method:       http.MethodGet,
+			path:         "/api/v1/metrics/gauge/Alloc",
+			expected:     http.StatusOK,
+			expectedBody: `"unit":"bytes"`,
+		},
+		{
+			name:     "Unknown Metric",
+			method:   http.MethodGet,
+			path:     "/api/v1/metrics/gauge/Nope",
+			expected: http.StatusNotFound,
+			code:     CodeNotFound,
+		},
+		{
+			name:     "Unknown Type",
+			method:   http.MethodGet,
+			path:     "/api/v1/metrics/histogram/Alloc",
+			expected: http.StatusBadRequest,
+			code:     CodeUnsupportedType,
+		},
+		{
+			name:     "Invalid JSON",
+			method:   http.MethodPost,
+			path:     "/api/v1/metrics",
+			body:     `{"metrics":`,
+			expected: http.StatusBadRequest,
+			code:     CodeInvalidRequest,
+		},
+		{
+			name:   "Missing Value Stores Nothing",
+			method: http.MethodPost,
+			path:   "/api/v1/metrics",
+			body: `{"metrics":[{"id":"Stored","type":"gauge","value":1},` +
+				`{"id":"Missing","type":"gauge"}]}`,
+			expected:     http.StatusBadRequest,
+			code:         CodeMissingValue,
+			expectedBody: "metrics[1]",
+		},
+		{
+			name:     "Not Stored",
+			method:   http.MethodGet,
+			path:     "/api/v1/metrics/gauge/Stored",
+			expected: http.StatusNotFound,
+			code:     CodeNotFound,
+		},
+		{
+			name:     "Type Conflict",
+			method:   http.MethodPut,
+			path:     "/api/v1/metadata/Alloc",
+			body:     `{"type":"counter"}`,
+			expected: http.StatusConflict,
+			code:     CodeConflict,
+		},
+		{
+			name:         "Set Metadata",
+			method:       http.MethodPut,
+			path:         "/api/v1/metadata/PollCount",
+			body:         `{"type":"counter","description":"polls"}`,
+			expected:     http.StatusOK,
+			expectedBody: `"name":"PollCount"`,
+		},
+		{
+			name:         "List Metadata",
+			method:       http.MethodGet,
+			path:         "/api/v1/metadata",
+			expected:     http.StatusOK,
+			expectedBody: `"description":"polls"`,
+		},
+		{
+			name:     "Unknown Metadata",
+			method:   http.MethodGet,
+			path:     "/api/v1/metadata/Nope",
+			expected: http.StatusNotFound,
+			code:     CodeNotFound,
+		},
+		{
+			name:     "History Disabled",
+			method:   http.MethodGet,
+			path:     "/api/v1/render?id=Alloc",
+			expected: http.StatusNotImplemented,
+			code:     CodeNotEnabled,
+		},
+		{
+			name:     "Unknown Route",
+			method:   http.MethodGet,
+			path:     "/api/v1/nope",
+			expected: http.StatusNotFound,
+			code:     CodeNotFound,
+		},
+		{
+			name:         "Unknown Page",
+			method:       http.MethodGet,
+			path:         "/nope",
+			expected:     http.StatusNotFound,
+			expectedBody: "Page not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+				request.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, request)
+				require.Equal(t, tt.expected, w.Code)
+				if tt.code != "" {
+					var response APIErrorResponse
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+					assert.Equal(t, tt.code, response.Error.Code)
+					assert.NotEmpty(t, response.Error.Message)
+				}
+				if tt.expectedBody != "" {
+					assert.Contains(t, w.Body.String(), tt.expectedBody)
+				}
+			},
+		)
+	}
+}
+
+func TestAPIUpdateFailureKeepsMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limited := tenant.NewStorage(filememory.NewMemStorage(false, nil), tenant.Limits{MaxRate: 1})
+	st := metadata.NewStorage(limited, metadata.NewRegistry(""))
+	router := newAPIRouter(NewHandler(st))
+
+	body := `{"metrics":[{"id":"Alloc","type":"gauge","value":1.5,"unit":"bytes"},` +
+		`{"id":"Load","type":"gauge","value":1,"unit":"percent"}]}`
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/metrics", strings.NewReader(body)))
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	for _, name := range []string{"Alloc", "Load"} {
+		meta, _ := metadata.From(st).Get(name)
+		assert.Empty(t, meta.Unit, name)
+	}
+}
+
+func TestAPIMetadataDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newAPIRouter(NewHandler(filememory.NewMemStorage(false, nil)))
+
+	for _, path := range []string{"/api/v1/metadata", "/api/v1/metadata/Alloc"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		require.Equal(t, http.StatusNotImplemented, w.Code)
+
+		var response APIErrorResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, CodeNotEnabled, response.Error.Code)
+	}
+}
+
+func TestAPIMetricIDWithSlashes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := filememory.NewMemStorage(false, nil)
+	require.NoError(t, st.UpdateGauge("disk/root/used", 42))
+	router := newAPIRouter(NewHandler(st))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/gauge/disk/root/used", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"id":"disk/root/used","type":"gauge","value":42}`, w.Body.String())
+}
+
+func TestAPIListHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := filememory.NewMemStorage(false, nil)
+	for _, id := range []string{"Alloc", "HeapAlloc", "HeapIdle", "HeapInuse", "PollCount"} {
+		require.NoError(t, st.UpdateGauge(id, 1))
+	}
+	require.NoError(t, st.UpdateCounter("PollCount", 1, false))
+	router := newAPIRouter(NewHandler(st))
+
+	list := func(t *testing.T, query string) (int, MetricsResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/metrics?"+query, nil))
+		var response MetricsResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		}
+		return w.Code, response
+	}
+	ids := func(response MetricsResponse) []string {
+		var ids []string
+		for _, m := range response.Metrics {
+			ids = append(ids, m.ID+" "+m.MType)
+		}
+		return ids
+	}
+
+	t.Run(
+		"Pages", func(t *testing.T) {
+			var pages [][]string
+			query := "limit=2"
+			for {
+				code, response := list(t, query)
+				require.Equal(t, http.StatusOK, code)
+				pages = append(pages, ids(response))
+				if response.NextCursor == "" {
+					break
+				}
+				query = "limit=2&cursor=" + response.NextCursor
+			}
+			assert.Equal(
+				t, [][]string{
+					{"Alloc gauge", "HeapAlloc gauge"},
+					{"HeapIdle gauge", "HeapInuse gauge"},
+					{"PollCount counter", "PollCount gauge"},
+				}, pages,
+			)
+		},
+	)
+	t.Run(
+		"Filters", func(t *testing.T) {
+			code, response := list(t, "type=gauge&prefix=Heap&match=I.*e$&sort=-name")
+			require.Equal(t, http.StatusOK, code)
+			assert.Equal(t, []string{"HeapInuse gauge", "HeapIdle gauge"}, ids(response))
+			assert.Empty(t, response.NextCursor)
+		},
+	)
+	t.Run(
+		"Empty", func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/metrics?prefix=Nope", nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.JSONEq(t, `{"metrics":[]}`, w.Body.String())
+		},
+	)
+	invalid := []string{"type=histogram", "match=(", "sort=value", "limit=0", "limit=x", "cursor=!"}
+	for _, query := range invalid {
+		t.Run(
+			"Invalid "+query, func(t *testing.T) {
+				code, _ := list(t, query)
+				assert.Equal(t, http.StatusBadRequest, code)
+			},
+		)
+	}
+}
@@ -0,0 +1,88 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateBatchMetricsContentTypes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	delta, value := int64(2), 3.5
+	metrics := []f.Metric{
+		{ID: "PollCount", MType: "counter", Delta: &delta},
+		{ID: "Alloc", MType: "gauge", Value: &value},
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		gzip        bool
+		body        []byte
+		expected    int
+	}{
+		{name: "JSON", contentType: f.ContentTypeJSON, expected: http.StatusOK},
+		{name: "Protobuf", contentType: f.ContentTypeProtobuf, expected: http.StatusOK},
+		{name: "MessagePack", contentType: f.ContentTypeMsgPack, expected: http.StatusOK},
+		{
+			name:        "Gzip MessagePack",
+			contentType: f.ContentTypeMsgPack,
+			gzip:        true,
+			expected:    http.StatusOK,
+		},
+		{
+			name:        "Invalid Protobuf",
+			contentType: f.ContentTypeProtobuf,
+			body:        []byte{0xff, 0xff},
+			expected:    http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				st := batchStorage{filememory.NewMemStorage(false, nil)}
+				router := gin.New()
+				router.POST("/updates/", NewHandler(st).UpdateBatchMetrics("", ""))
+
+				body := tt.body
+				if body == nil {
+					var err error
+					body, err = f.EncodeBatch(metrics, tt.contentType)
+					require.NoError(t, err)
+				}
+				if tt.gzip {
+					var compressed bytes.Buffer
+					gz := gzip.NewWriter(&compressed)
+					_, err := gz.Write(body)
+					require.NoError(t, err)
+					require.NoError(t, gz.Close())
+					body = compressed.Bytes()
+				}
+				r := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(body))
+				r.Header.Set("Content-Type", tt.contentType)
+				if tt.gzip {
+					r.Header.Set("Content-Encoding", "gzip")
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+				require.Equal(t, tt.expected, w.Code)
+				if tt.expected != http.StatusOK {
+					return
+				}
+
+				counters, gauges := st.GetMetrics()
+				assert.Equal(t, map[string]int64{"PollCount": 2}, counters)
+				assert.Equal(t, map[string]float64{"Alloc": 3.5}, gauges)
+			},
+		)
+	}
+}
@@ -0,0 +1,213 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	gzip "github.com/klauspost/pgzip"
+	"go.uber.org/zap"
+)
+
+var ErrMissingID = errors.New("metric id is empty")
+
+const (
+	// bulkChunkSize is the number of metrics written to the storage at once.
+	bulkChunkSize = 1000
+	// maxBulkLine is the longest line accepted in a bulk request.
+	maxBulkLine = 1 << 20
+	// maxBulkBody limits the decompressed size of a bulk request.
+	maxBulkBody = 256 << 20
+	// maxBulkErrors limits the number of errors listed in a bulk report.
+	maxBulkErrors = 100
+)
+
+// LineError is the error of a single line of a bulk request.
+type LineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// BulkReport is the response to a bulk request. Errors lists at most
+// maxBulkErrors lines, Rejected counts all of them.
+type BulkReport struct {
+	Accepted int         `json:"accepted"`
+	Rejected int         `json:"rejected"`
+	Errors   []LineError `json:"errors"`
+}
+
+func (r *BulkReport) reject(line int, err error) {
+	r.Rejected++
+	if len(r.Errors) < maxBulkErrors {
+		r.Errors = append(r.Errors, LineError{Line: line, Error: err.Error()})
+	}
+}
+
+// BulkHandler creates a gin.HandlerFunc that ingests newline-delimited JSON,
+// one metric in the format of /update/ per line. The body is decoded while
+// it is read and written to the storage in chunks, so it is never held in
+// memory as a whole. Invalid lines are skipped and listed in the BulkReport
+// the handler responds with; empty lines are ignored. A body larger than
+// maxBulkBody is cut off, which is reported after its last line.
+//
+// @Summary      Ingest metrics as newline-delimited JSON
+// @Description  Stores one metric in the format of /update/ per line. Invalid lines are
+// @Description  skipped and reported. The body may be gzip-compressed.
+// @Tags         ingest
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        metrics  body      string  true  "One metric per line"
+// @Success      200      {object}  BulkReport
+// @Failure      400      {object}  APIErrorResponse
+// @Router       /api/v1/bulk [post]
+func (h *Handler) BulkHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		body, err := requestBody(c.Request)
+		if err != nil {
+			abortWithError(c, fmt.Errorf("%w: %v", ErrInvalidBody, err))
+			return
+		}
+		body = http.MaxBytesReader(c.Writer, body, maxBulkBody)
+		defer body.Close()
+
+		w := newBulkWriter(st, c)
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxBulkLine)
+		line := 0
+		for scanner.Scan() {
+			line++
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			var m f.Metric
+			if err := json.Unmarshal(data, &m); err != nil {
+				w.report.reject(line, ErrInvalidJSON)
+				continue
+			}
+			w.add(line, m)
+		}
+		w.flush()
+		if err := scanner.Err(); err != nil {
+			logger.Error(err.Error(), zap.String("method", c.Request.Method))
+			w.report.reject(line+1, err)
+		}
+		c.JSON(http.StatusOK, w.report)
+	}
+}
+
+// bulkWriter validates metrics and writes them to the storage in chunks of
+// bulkChunkSize, reporting the lines they were read from.
+type bulkWriter struct {
+	st     serviceInterface.MetricsStorage
+	c      *gin.Context
+	report BulkReport
+	chunk  []f.Metric
+	lines  []int
+}
+
+func newBulkWriter(st serviceInterface.MetricsStorage, c *gin.Context) *bulkWriter {
+	return &bulkWriter{
+		st:     st,
+		c:      c,
+		report: BulkReport{Errors: []LineError{}},
+		chunk:  make([]f.Metric, 0, bulkChunkSize),
+		lines:  make([]int, 0, bulkChunkSize),
+	}
+}
+
+// add writes m, read from line, with the next chunk, or rejects it when it
+// is invalid.
+func (w *bulkWriter) add(line int, m f.Metric) {
+	if err := validateMetric(m); err != nil {
+		w.report.reject(line, err)
+		return
+	}
+	w.chunk = append(w.chunk, m)
+	w.lines = append(w.lines, line)
+	if len(w.chunk) == bulkChunkSize {
+		w.flush()
+	}
+}
+
+// flush writes the pending chunk as one batch. Batch inserts are atomic, so
+// when the batch fails, or the storage cannot insert batches, the chunk is
+// written again one metric at a time and only the failing lines are
+// rejected.
+func (w *bulkWriter) flush() {
+	if len(w.chunk) == 0 {
+		return
+	}
+	err := w.st.InsertBatchMetrics(w.chunk)
+	if err == nil {
+		for _, m := range w.chunk {
+			w.accept(m)
+		}
+		w.chunk, w.lines = w.chunk[:0], w.lines[:0]
+		return
+	}
+	if !errors.Is(err, storage.ErrBatchUnsupported) {
+		logger.Error(err.Error(), zap.String("method", w.c.Request.Method))
+	}
+	for i, m := range w.chunk {
+		if err := storage.InsertBatch(w.st, []f.Metric{m}); err != nil {
+			logger.Error(err.Error(), zap.String("method", w.c.Request.Method))
+			w.report.reject(w.lines[i], err)
+			continue
+		}
+		w.accept(m)
+	}
+	w.chunk, w.lines = w.chunk[:0], w.lines[:0]
+}
+
+// accept counts the stored metric m and registers its metadata.
+func (w *bulkWriter) accept(m f.Metric) {
+	w.report.Accepted++
+	if err := registerMetadata(w.st, m); err != nil {
+		logger.Error(err.Error(), zap.String("method", w.c.Request.Method))
+	}
+}
+
+// validateMetric checks that m has an ID and the value its type requires.
+func validateMetric(m f.Metric) error {
+	switch {
+	case m.ID == "":
+		return ErrMissingID
+	case m.MType == config.Counter && m.Delta == nil:
+		return ErrDeltaNil
+	case m.MType == config.Gauge && m.Value == nil:
+		return ErrValueNil
+	case m.MType != config.Counter && m.MType != config.Gauge:
+		return ErrUnsupportedMetric
+	}
+	return nil
+}
+
+// requestBody returns the body of r, decompressing it when the client sent
+// it gzip-encoded and the compression middleware has not done so. The
+// caller closes the returned reader.
+func requestBody(r *http.Request) (io.ReadCloser, error) {
+	body := bufio.NewReader(r.Body)
+	if !strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+		return io.NopCloser(body), nil
+	}
+	if magic, _ := body.Peek(2); !bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return io.NopCloser(body), nil
+	}
+	return gzip.NewReader(body)
+}
@@ -0,0 +1,139 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var large strings.Builder
+	for i := 0; i < bulkChunkSize+5; i++ {
+		large.WriteString(`{"id":"PollCount","type":"counter","delta":1}` + "\n")
+	}
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte(`{"id":"Alloc","type":"gauge","value":1.5}`))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	tests := []struct {
+		name     string
+		body     []byte
+		gzip     bool
+		report   BulkReport
+		counters map[string]int64
+		gauges   map[string]float64
+	}{
+		{
+			name: "Per Line Errors",
+			body: []byte(strings.Join(
+				[]string{
+					`{"id":"Alloc","type":"gauge","value":1.5}`,
+					``,
+					`{"id":"PollCount","type":"counter","delta":2}`,
+					`{"id":"Broken",`,
+					`{"id":"NoValue","type":"gauge"}`,
+					`{"id":"NoDelta","type":"counter"}`,
+					`{"type":"gauge","value":1}`,
+					`{"id":"Hist","type":"histogram","value":1}`,
+				}, "\n",
+			)),
+			report: BulkReport{
+				Accepted: 2,
+				Rejected: 5,
+				Errors: []LineError{
+					{Line: 4, Error: ErrInvalidJSON.Error()},
+					{Line: 5, Error: ErrValueNil.Error()},
+					{Line: 6, Error: ErrDeltaNil.Error()},
+					{Line: 7, Error: ErrMissingID.Error()},
+					{Line: 8, Error: ErrUnsupportedMetric.Error()},
+				},
+			},
+			counters: map[string]int64{"PollCount": 2},
+			gauges:   map[string]float64{"Alloc": 1.5},
+		},
+		{
+			name:     "Several Chunks",
+			body:     []byte(large.String()),
+			report:   BulkReport{Accepted: bulkChunkSize + 5, Errors: []LineError{}},
+			counters: map[string]int64{"PollCount": bulkChunkSize + 5},
+			gauges:   map[string]float64{},
+		},
+		{
+			name:     "Gzip Body",
+			body:     compressed.Bytes(),
+			gzip:     true,
+			report:   BulkReport{Accepted: 1, Errors: []LineError{}},
+			counters: map[string]int64{},
+			gauges:   map[string]float64{"Alloc": 1.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				st := filememory.NewMemStorage(false, nil)
+				router := gin.New()
+				router.POST("/api/v1/bulk", NewHandler(st).BulkHandler())
+
+				req := httptest.NewRequest(http.MethodPost, "/api/v1/bulk", bytes.NewReader(tt.body))
+				req.Header.Set("Content-Type", "application/x-ndjson")
+				if tt.gzip {
+					req.Header.Set("Content-Encoding", "gzip")
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				require.Equal(t, http.StatusOK, w.Code)
+				var report BulkReport
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+				assert.Equal(t, tt.report, report)
+				counters, gauges := st.GetMetrics()
+				assert.Equal(t, tt.counters, counters)
+				assert.Equal(t, tt.gauges, gauges)
+			},
+		)
+	}
+}
+
+func TestBulkHandlerFailingMetric(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mem := filememory.NewMemStorage(false, nil)
+	st := metadata.NewStorage(mem, metadata.NewRegistry(""))
+	require.NoError(t, st.UpdateCounter("Alloc", 1, false))
+	router := gin.New()
+	router.POST("/api/v1/bulk", NewHandler(st).BulkHandler())
+
+	body := strings.Join(
+		[]string{
+			`{"id":"PollCount","type":"counter","delta":2}`,
+			`{"id":"Alloc","type":"gauge","value":1.5}`,
+			`{"id":"Load","type":"gauge","value":3}`,
+		}, "\n",
+	)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/bulk", strings.NewReader(body)))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var report BulkReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 2, report.Accepted)
+	assert.Equal(t, 1, report.Rejected)
+	require.Len(t, report.Errors, 1)
+	assert.Equal(t, 2, report.Errors[0].Line)
+	counters, gauges := mem.GetMetrics()
+	assert.Equal(t, map[string]int64{"Alloc": 1, "PollCount": 2}, counters)
+	assert.Equal(t, map[string]float64{"Load": 3}, gauges)
+}
@@ -0,0 +1,282 @@
+package rest
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/agents"
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// web holds the templates and static assets of the dashboard, so that it
+// works without access to any other server.
+//
+//go:embed web
+var web embed.FS
+
+var dashboardTemplates = template.Must(template.ParseFS(web, "web/templates/*.html"))
+
+// defaultChartRange is the history shown on metric pages by default.
+const defaultChartRange = time.Hour
+
+// chartRanges are the history ranges metric pages link to.
+var chartRanges = []time.Duration{15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour}
+
+// DashboardAssets returns the static files of the dashboard, which the
+// pages expect under /ui/static/.
+func DashboardAssets() http.FileSystem {
+	static, err := fs.Sub(web, "web/static")
+	if err != nil {
+		panic(err)
+	}
+	return http.FS(static)
+}
+
+// dashboardPage is the data every dashboard page is rendered with.
+type dashboardPage struct {
+	Title string
+	Page  string
+}
+
+type dashboardMetric struct {
+	ID          string
+	Type        string
+	Value       string
+	Unit        string
+	Description string
+	Link        string
+}
+
+func newDashboardMetric(
+	st serviceInterface.MetricsStorage,
+	mType, id, value string,
+) dashboardMetric {
+	m := dashboardMetric{
+		ID:    id,
+		Type:  mType,
+		Value: value,
+		Link:  "/ui/metric?" + url.Values{"type": {mType}, "id": {id}}.Encode(),
+	}
+	if registry := metadata.From(st); registry != nil {
+		name, _ := f.ParseSeriesID(id)
+		if meta, ok := registry.Get(name); ok {
+			m.Unit = meta.Unit
+			m.Description = meta.Description
+		}
+	}
+	return m
+}
+
+// render executes the dashboard template name and responds with the page.
+func render(c *gin.Context, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := dashboardTemplates.ExecuteTemplate(&buf, name, data); err != nil {
+		logger.Error(err.Error(), zap.String("method", c.Request.Method))
+		c.String(http.StatusInternalServerError, "Failed to render template")
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// MetricsListHandler creates a gin.HandlerFunc that serves the dashboard
+// page listing all stored metrics ordered by ID. The table can be sorted
+// and filtered in the browser and keeps its values up to date with the
+// events of StreamHandler.
+//
+// @Summary      Dashboard
+// @Description  Serves the dashboard page listing all stored metrics.
+// @Tags         dashboard
+// @Produce      html
+// @Success      200  {string}  string
+// @Router       / [get]
+func (h *Handler) MetricsListHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		counters, gauges := st.GetMetrics()
+		metrics := make([]dashboardMetric, 0, len(counters)+len(gauges))
+		for id, delta := range counters {
+			metrics = append(metrics, newDashboardMetric(st, config.Counter, id, strconv.FormatInt(delta, 10)))
+		}
+		for id, value := range gauges {
+			metrics = append(
+				metrics,
+				newDashboardMetric(st, config.Gauge, id, strconv.FormatFloat(value, 'f', -1, 64)),
+			)
+		}
+		sort.Slice(
+			metrics, func(i, j int) bool {
+				if metrics[i].ID != metrics[j].ID {
+					return metrics[i].ID < metrics[j].ID
+				}
+				return metrics[i].Type < metrics[j].Type
+			},
+		)
+		render(
+			c, "index.html", struct {
+				dashboardPage
+				Metrics []dashboardMetric
+			}{
+				dashboardPage: dashboardPage{Title: "Metrics", Page: "metrics"},
+				Metrics:       metrics,
+			},
+		)
+	}
+}
+
+type chartRange struct {
+	Name     string
+	Link     string
+	Selected bool
+}
+
+type chartSample struct {
+	Timestamp int64   `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+// MetricPageHandler creates a gin.HandlerFunc that serves the dashboard
+// page of the metric selected by the type and id query parameters. The
+// page charts the history of the metric over the range query parameter,
+// a duration that defaults to an hour.
+//
+// @Summary      Metric page
+// @Description  Serves the dashboard page of a metric with a chart of its history.
+// @Tags         dashboard
+// @Produce      html
+// @Param        type   query     string  true   "Metric type"  Enums(counter, gauge)
+// @Param        id     query     string  true   "Metric ID"
+// @Param        range  query     string  false  "History range, e.g. 15m or 6h"
+// @Success      200    {string}  string
+// @Failure      400    {string}  string
+// @Failure      404    {string}  string
+// @Router       /ui/metric [get]
+func (h *Handler) MetricPageHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		mType, id := c.Query("type"), c.Query("id")
+		if mType != config.Counter && mType != config.Gauge {
+			c.String(http.StatusBadRequest, ErrUnsupportedMetric.Error())
+			return
+		}
+		span := defaultChartRange
+		if r := c.Query("range"); r != "" {
+			var err error
+			if span, err = time.ParseDuration(r); err != nil || span <= 0 {
+				c.String(http.StatusBadRequest, "invalid range")
+				return
+			}
+		}
+
+		var (
+			value string
+			ok    bool
+			err   error
+		)
+		if mType == config.Counter {
+			var delta int64
+			delta, ok, err = st.GetCounter(id)
+			value = strconv.FormatInt(delta, 10)
+		} else {
+			var gauge float64
+			gauge, ok, err = st.GetGauge(id)
+			value = strconv.FormatFloat(gauge, 'f', -1, 64)
+		}
+		if err != nil {
+			logger.Error(err.Error(), zap.String("method", c.Request.Method))
+			c.String(storageErrorStatus(err), "Failed to get metric")
+			return
+		}
+		if !ok {
+			c.String(http.StatusNotFound, "Metric not found")
+			return
+		}
+
+		m := newDashboardMetric(st, mType, id, value)
+		ranges := make([]chartRange, 0, len(chartRanges))
+		for _, r := range chartRanges {
+			name := shortDuration(r)
+			ranges = append(ranges, chartRange{Name: name, Link: m.Link + "&range=" + name, Selected: r == span})
+		}
+		samples := []chartSample{}
+		hist := history.Find(st)
+		if hist != nil {
+			now := time.Now()
+			for _, s := range hist.Range(mType, id, now.Add(-span), now) {
+				samples = append(samples, chartSample{Timestamp: s.Time.UnixMilli(), Value: s.Value})
+			}
+		}
+		render(
+			c, "metric.html", struct {
+				dashboardPage
+				Metric         dashboardMetric
+				HistoryEnabled bool
+				Ranges         []chartRange
+				RangeMillis    int64
+				Samples        []chartSample
+			}{
+				dashboardPage:  dashboardPage{Title: id, Page: "metrics"},
+				Metric:         m,
+				HistoryEnabled: hist != nil,
+				Ranges:         ranges,
+				RangeMillis:    span.Milliseconds(),
+				Samples:        samples,
+			},
+		)
+	}
+}
+
+// shortDuration formats d without zero minutes and seconds, e.g. "1h"
+// instead of "1h0m0s".
+func shortDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return strconv.FormatInt(int64(d/time.Hour), 10) + "h"
+	case d%time.Minute == 0:
+		return strconv.FormatInt(int64(d/time.Minute), 10) + "m"
+	default:
+		return d.String()
+	}
+}
+
+// AgentsHandler creates a gin.HandlerFunc that serves the dashboard page
+// listing the agents recorded by registry.
+//
+// @Summary      Agents page
+// @Description  Serves the dashboard page listing the agents that sent metrics.
+// @Tags         dashboard
+// @Produce      html
+// @Success      200  {string}  string
+// @Router       /ui/agents [get]
+func AgentsHandler(registry *agents.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		render(
+			c, "agents.html", struct {
+				dashboardPage
+				Agents []agents.Agent
+			}{
+				dashboardPage: dashboardPage{Title: "Agents", Page: "agents"},
+				Agents:        registry.List(),
+			},
+		)
+	}
+}
@@ -0,0 +1,122 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/agents"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboard(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mem := filememory.NewMemStorage(false, nil)
+	st := history.New(mem, time.Hour)
+	require.NoError(t, st.UpdateGauge("Zeta", 1.5))
+	require.NoError(t, st.UpdateGauge("Alloc", 2.25))
+	require.NoError(t, st.UpdateCounter("PollCount", 3, true))
+	registry := agents.NewRegistry()
+	registry.Seen("10.0.0.2", "agent/1.0")
+
+	router := gin.New()
+	h := NewHandler(st)
+	router.GET("/", h.MetricsListHandler())
+	router.GET("/ui/metric", h.MetricPageHandler())
+	router.GET("/ui/agents", AgentsHandler(registry))
+	router.StaticFS("/ui/static", DashboardAssets())
+	noHistory := gin.New()
+	noHistory.GET("/ui/metric", NewHandler(mem).MetricPageHandler())
+
+	tests := []struct {
+		name     string
+		router   *gin.Engine
+		path     string
+		expected int
+		contains []string
+	}{
+		{
+			name:     "Metric List",
+			router:   router,
+			path:     "/",
+			expected: http.StatusOK,
+			contains: []string{
+				`<a href="/ui/metric?id=Alloc&amp;type=gauge">Alloc</a>`,
+				`data-value="3"`,
+				`<script src="/ui/static/dashboard.js">`,
+			},
+		},
+		{
+			name:     "Metric Page",
+			router:   router,
+			path:     "/ui/metric?type=gauge&id=Alloc&range=15m",
+			expected: http.StatusOK,
+			contains: []string{`<canvas id="chart"`, `data-range="900000"`, `"value":2.25`},
+		},
+		{
+			name:     "Metric Page Without History",
+			router:   noHistory,
+			path:     "/ui/metric?type=counter&id=PollCount",
+			expected: http.StatusOK,
+			contains: []string{"keeps no metric history"},
+		},
+		{
+			name:     "Unknown Metric",
+			router:   router,
+			path:     "/ui/metric?type=gauge&id=Nope",
+			expected: http.StatusNotFound,
+		},
+		{
+			name:     "Invalid Type",
+			router:   router,
+			path:     "/ui/metric?type=histogram&id=Alloc",
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "Invalid Range",
+			router:   router,
+			path:     "/ui/metric?type=gauge&id=Alloc&range=-1h",
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "Agents",
+			router:   router,
+			path:     "/ui/agents",
+			expected: http.StatusOK,
+			contains: []string{"<td>10.0.0.2</td>", "<td>agent/1.0</td>"},
+		},
+		{
+			name:     "Static Asset",
+			router:   router,
+			path:     "/ui/static/dashboard.css",
+			expected: http.StatusOK,
+			contains: []string{"table"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				tt.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				require.Equal(t, tt.expected, w.Code)
+				for _, s := range tt.contains {
+					assert.Contains(t, w.Body.String(), s)
+				}
+			},
+		)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	body := w.Body.String()
+	alloc := strings.Index(body, ">Alloc<")
+	poll := strings.Index(body, ">PollCount<")
+	zeta := strings.Index(body, ">Zeta<")
+	assert.True(t, alloc < poll && poll < zeta, "metrics are not ordered by ID")
+}
@@ -0,0 +1,609 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	"go.uber.org/zap"
+)
+
+// Export formats.
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+)
+
+// Import modes of counters.
+const (
+	// CountersRestore rejects counters that are already stored or occur
+	// twice, so an import restores their exported totals and a retried
+	// import does not count them twice.
+	CountersRestore = "restore"
+	// CountersAdd adds the imported counters to the stored ones.
+	CountersAdd = "add"
+)
+
+var (
+	ErrInvalidValue  = errors.New("invalid metric value")
+	ErrCounterExists = errors.New("counter is already stored")
+)
+
+// exportPageSize is the number of metrics read from the storage at once.
+const exportPageSize = 1000
+
+// csvHeader lists the columns of CSV exports. Imports find the columns by
+// their names, so their order does not matter.
+var csvHeader = []string{"id", "type", "value", "unit", "description", "time"}
+
+// ExportRecord is a metric in an export. Records with a Time are samples
+// of the metric history, where counters have their total as the Value.
+// The other records hold the current value of the metric.
+type ExportRecord struct {
+	ID          string     `json:"id"`
+	MType       string     `json:"type"`
+	Delta       *int64     `json:"delta,omitempty"`
+	Value       *float64   `json:"value,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Time        *time.Time `json:"time,omitempty"`
+}
+
+// ImportReport is the response to an import. Samples counts the restored
+// history samples.
+type ImportReport struct {
+	BulkReport
+	Samples int `json:"samples"`
+}
+
+// ExportHandler creates a gin.HandlerFunc that streams every stored metric
+// in CSV, JSON or newline-delimited JSON. With a time range, the history
+// samples of every metric precede its current value.
+//
+// @Summary      Export all metrics
+// @Description  Streams the current value of every metric, sorted by ID and type. With from,
+// @Description  the history samples between from and until precede the current value of each
+// @Description  metric; they have a time, and counter samples hold the total as value.
+// @Tags         api
+// @Produce      text/csv
+// @Produce      json
+// @Produce      application/x-ndjson
+// @Param        format  query     string  false  "Format"  Enums(csv, json, ndjson)  default(json)
+// @Param        from    query     string  false  "Start of the history, e.g. 1h, unix time or RFC 3339"
+// @Param        until   query     string  false  "End of the history, now by default"
+// @Success      200     {array}   ExportRecord
+// @Failure      400     {object}  APIErrorResponse
+// @Failure      501     {object}  APIErrorResponse
+// @Router       /api/v1/export [get]
+func (h *Handler) ExportHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		format := c.DefaultQuery("format", FormatJSON)
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			abortWithError(c, fmt.Errorf("%w: format", ErrInvalidParameter))
+			return
+		}
+		hist, from, until, err := exportRange(c, st)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", "attachment; filename=metrics."+format)
+		c.Status(http.StatusOK)
+		w := newExportWriter(c.Writer, format)
+		opts := storage.ListOptions{Limit: exportPageSize}
+		for {
+			metrics, err := storage.List(st, opts)
+			if err != nil {
+				// The status is sent, the export ends truncated.
+				logger.Error(err.Error(), zap.String("method", c.Request.Method))
+				return
+			}
+			for _, m := range metrics {
+				m = withMetadata(st, m)
+				if hist != nil {
+					for _, s := range hist.Range(m.MType, m.ID, from, until) {
+						value, t := s.Value, s.Time
+						err = w.Write(ExportRecord{ID: m.ID, MType: m.MType, Value: &value, Time: &t})
+						if err != nil {
+							return
+						}
+					}
+				}
+				if err = w.Write(exportRecord(m)); err != nil {
+					return
+				}
+			}
+			if len(metrics) < exportPageSize {
+				break
+			}
+			last := metrics[len(metrics)-1]
+			opts.After = &storage.Key{ID: last.ID, MType: last.MType}
+			if err = w.Flush(); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+		if err := w.Close(); err != nil {
+			logger.Error(err.Error(), zap.String("method", c.Request.Method))
+		}
+	}
+}
+
+var exportContentTypes = map[string]string{
+	FormatCSV:    "text/csv; charset=utf-8",
+	FormatJSON:   f.ContentTypeJSON,
+	FormatNDJSON: "application/x-ndjson",
+}
+
+// exportRange returns the history and the time range of its samples to
+// export, or a nil history when no range is requested.
+func exportRange(
+	c *gin.Context,
+	st serviceInterface.MetricsStorage,
+) (*history.Storage, time.Time, time.Time, error) {
+	now := time.Now()
+	from, until := c.Query("from"), c.Query("until")
+	if from == "" {
+		if until != "" {
+			return nil, now, now, fmt.Errorf("%w: until without from", ErrInvalidParameter)
+		}
+		return nil, now, now, nil
+	}
+	hist := history.Find(st)
+	if hist == nil {
+		return nil, now, now, ErrHistoryDisabled
+	}
+	start, err := parseTime(from, now)
+	if err != nil {
+		return nil, now, now, err
+	}
+	end := now
+	if until != "" {
+		if end, err = parseTime(until, now); err != nil {
+			return nil, now, now, err
+		}
+	}
+	return hist, start, end, nil
+}
+
+func exportRecord(m f.Metric) ExportRecord {
+	return ExportRecord{
+		ID:          m.ID,
+		MType:       m.MType,
+		Delta:       m.Delta,
+		Value:       m.Value,
+		Unit:        m.Unit,
+		Description: m.Description,
+	}
+}
+
+// exportWriter writes the records of an export in one format. Close ends
+// the export.
+type exportWriter interface {
+	Write(r ExportRecord) error
+	Flush() error
+	Close() error
+}
+
+func newExportWriter(w io.Writer, format string) exportWriter {
+	switch format {
+	case FormatCSV:
+		e := &csvExport{w: csv.NewWriter(w)}
+		// Errors of the buffered writer are returned by the next Flush.
+		_ = e.w.Write(csvHeader)
+		return e
+	case FormatNDJSON:
+		return &jsonExport{w: w}
+	default:
+		return &jsonExport{w: w, array: true}
+	}
+}
+
+// csvExport writes records as CSV rows below csvHeader.
+type csvExport struct {
+	w *csv.Writer
+}
+
+func (e *csvExport) Write(r ExportRecord) error {
+	var value, t string
+	switch {
+	case r.Delta != nil:
+		value = strconv.FormatInt(*r.Delta, 10)
+	case r.Value != nil:
+		value = strconv.FormatFloat(*r.Value, 'g', -1, 64)
+	}
+	if r.Time != nil {
+		t = r.Time.Format(time.RFC3339Nano)
+	}
+	return e.w.Write([]string{r.ID, r.MType, value, r.Unit, r.Description, t})
+}
+
+func (e *csvExport) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvExport) Close() error {
+	return e.Flush()
+}
+
+// jsonExport writes records as a JSON array or one per line.
+type jsonExport struct {
+	w      io.Writer
+	array  bool
+	opened bool
+}
+
+func (e *jsonExport) Write(r ExportRecord) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !e.array:
+		data = append(data, '\n')
+	case e.opened:
+		data = append([]byte{','}, data...)
+	default:
+		data = append([]byte{'['}, data...)
+	}
+	e.opened = true
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonExport) Flush() error {
+	return nil
+}
+
+func (e *jsonExport) Close() error {
+	if !e.array {
+		return nil
+	}
+	end := "]"
+	if !e.opened {
+		end = "[]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// ImportHandler creates a gin.HandlerFunc that stores the metrics of an
+// export and restores its history samples. Invalid records are skipped and
+// listed in the ImportReport the handler responds with.
+//
+// @Summary      Import metrics
+// @Description  Stores the current values of an export of /api/v1/export and restores its
+// @Description  history samples, which requires the history. By default counters that are
+// @Description  already stored are rejected, so the exported totals are restored and a retry
+// @Description  does not count them twice; with counters=add they are added to the stored ones.
+// @Description  Errors report the line of CSV and NDJSON records and the position of JSON
+// @Description  records. The format defaults to the one of the Content-Type. The body may be
+// @Description  gzip-compressed.
+// @Tags         api
+// @Accept       json
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+// @Param        format    query     string  false  "Format"  Enums(csv, json, ndjson)
+// @Param        counters  query     string  false  "Counter mode"  Enums(restore, add)  default(restore)
+// @Param        records   body      string  true   "Exported records"
+// @Success      200       {object}  ImportReport
+// @Failure      400       {object}  APIErrorResponse
+// @Router       /api/v1/import [post]
+func (h *Handler) ImportHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		format := c.Query("format")
+		if format == "" {
+			format = importFormat(c.GetHeader("Content-Type"))
+		}
+		if _, ok := exportContentTypes[format]; !ok {
+			abortWithError(c, fmt.Errorf("%w: format", ErrInvalidParameter))
+			return
+		}
+		mode := c.DefaultQuery("counters", CountersRestore)
+		if mode != CountersRestore && mode != CountersAdd {
+			abortWithError(c, fmt.Errorf("%w: counters", ErrInvalidParameter))
+			return
+		}
+		body, err := requestBody(c.Request)
+		if err != nil {
+			abortWithError(c, fmt.Errorf("%w: %v", ErrInvalidBody, err))
+			return
+		}
+		body = http.MaxBytesReader(c.Writer, body, maxBulkBody)
+		defer body.Close()
+
+		r, err := newImportReader(body, format)
+		if err != nil {
+			abortWithError(c, fmt.Errorf("%w: %v", ErrInvalidBody, err))
+			return
+		}
+		w := newBulkWriter(st, c)
+		samples := &sampleRestore{hist: history.Find(st)}
+		counters := make(map[string]bool)
+		for {
+			rec, line, err := r.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			var fatal *importError
+			if errors.As(err, &fatal) {
+				w.report.reject(line, fatal.err)
+				break
+			}
+			if err != nil {
+				w.report.reject(line, err)
+				continue
+			}
+			if rec.Time != nil {
+				if err := samples.add(rec); err != nil {
+					w.report.reject(line, err)
+				}
+				continue
+			}
+			m := f.Metric{
+				ID: rec.ID, MType: rec.MType, Delta: rec.Delta, Value: rec.Value,
+				Unit: rec.Unit, Description: rec.Description,
+			}
+			if m.MType == config.Counter && mode == CountersRestore {
+				if err := newCounter(st, counters, m.ID); err != nil {
+					w.report.reject(line, err)
+					continue
+				}
+			}
+			w.add(line, m)
+		}
+		samples.flush()
+		w.flush()
+		c.JSON(http.StatusOK, ImportReport{BulkReport: w.report, Samples: samples.restored})
+	}
+}
+
+// newCounter returns ErrCounterExists if the counter id is stored in st or
+// has been imported before, as recorded in seen.
+func newCounter(st serviceInterface.MetricsStorage, seen map[string]bool, id string) error {
+	_, ok, err := st.GetCounter(id)
+	if err != nil {
+		return err
+	}
+	if ok || seen[id] {
+		return fmt.Errorf("%w: %s", ErrCounterExists, id)
+	}
+	seen[id] = true
+	return nil
+}
+
+// sampleRestore restores the history samples of an import. Exports list
+// the samples of a metric together, so they are restored at once when the
+// samples of the next metric start.
+type sampleRestore struct {
+	hist     *history.Storage
+	key      storage.Key
+	pending  []history.Sample
+	restored int
+}
+
+// add queues the history sample rec.
+func (r *sampleRestore) add(rec ExportRecord) error {
+	switch {
+	case r.hist == nil:
+		return ErrHistoryDisabled
+	case rec.ID == "":
+		return ErrMissingID
+	case rec.MType != config.Counter && rec.MType != config.Gauge:
+		return ErrUnsupportedMetric
+	case rec.Value == nil:
+		return ErrValueNil
+	}
+	k := storage.Key{ID: rec.ID, MType: rec.MType}
+	if k != r.key {
+		r.flush()
+		r.key = k
+	}
+	r.pending = append(r.pending, history.Sample{Time: *rec.Time, Value: *rec.Value})
+	return nil
+}
+
+// flush restores the queued samples.
+func (r *sampleRestore) flush() {
+	if len(r.pending) == 0 {
+		return
+	}
+	r.hist.Restore(r.key.MType, r.key.ID, r.pending)
+	r.restored += len(r.pending)
+	r.pending = r.pending[:0]
+}
+
+// importFormat returns the format of a body of contentType, JSON unless
+// it is CSV or NDJSON.
+func importFormat(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV
+	case "application/x-ndjson":
+		return FormatNDJSON
+	default:
+		return FormatJSON
+	}
+}
+
+// importError is an error after which no more records can be read.
+type importError struct {
+	err error
+}
+
+func (e *importError) Error() string {
+	return e.err.Error()
+}
+
+// importReader reads the records of an export. Read returns the record
+// and the line or position it was read from, and io.EOF after the last.
+type importReader interface {
+	Read() (ExportRecord, int, error)
+}
+
+func newImportReader(body io.Reader, format string) (importReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVImport(body)
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxBulkLine)
+		return &ndjsonImport{scanner: scanner}, nil
+	default:
+		d := json.NewDecoder(body)
+		if tok, err := d.Token(); err != nil || tok != json.Delim('[') {
+			return nil, errors.New("expected a JSON array")
+		}
+		return &jsonImport{d: d}, nil
+	}
+}
+
+type ndjsonImport struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonImport) Read() (ExportRecord, int, error) {
+	var rec ExportRecord
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return rec, r.line, ErrInvalidJSON
+		}
+		return rec, r.line, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return rec, r.line + 1, &importError{err: err}
+	}
+	return rec, r.line, io.EOF
+}
+
+type jsonImport struct {
+	d        *json.Decoder
+	position int
+}
+
+func (r *jsonImport) Read() (ExportRecord, int, error) {
+	var rec ExportRecord
+	if !r.d.More() {
+		return rec, r.position, io.EOF
+	}
+	r.position++
+	// Records are decoded after reading them whole, so that a record of
+	// valid JSON with wrong types does not stop the import.
+	var raw json.RawMessage
+	if err := r.d.Decode(&raw); err != nil {
+		return rec, r.position, &importError{err: ErrInvalidJSON}
+	}
+	if err := json.Unmarshal(raw, &rec); err != nil {
+		return rec, r.position, ErrInvalidJSON
+	}
+	return rec, r.position, nil
+}
+
+type csvImport struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVImport(body io.Reader) (*csvImport, error) {
+	r := csv.NewReader(body)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"id", "type", "value"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+	return &csvImport{r: r, columns: columns}, nil
+}
+
+func (r *csvImport) Read() (ExportRecord, int, error) {
+	var rec ExportRecord
+	row, err := r.r.Read()
+	line, _ := r.r.FieldPos(0)
+	if errors.Is(err, io.EOF) {
+		return rec, line, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return rec, parseErr.StartLine, err
+	}
+	if err != nil {
+		return rec, line, &importError{err: err}
+	}
+	column := func(name string) string {
+		if i, ok := r.columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	rec.ID, rec.MType = column("id"), column("type")
+	rec.Unit, rec.Description = column("unit"), column("description")
+	if t := column("time"); t != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return rec, line, fmt.Errorf("%w: %s", ErrInvalidTime, t)
+		}
+		rec.Time = &parsed
+	}
+	if value := column("value"); value != "" {
+		// Counter samples hold the total as a value.
+		switch {
+		case rec.MType == config.Counter && rec.Time == nil:
+			delta, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return rec, line, fmt.Errorf("%w: %s", ErrInvalidValue, value)
+			}
+			rec.Delta = &delta
+		default:
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return rec, line, fmt.Errorf("%w: %s", ErrInvalidValue, value)
+			}
+			rec.Value = &v
+		}
+	}
+	return rec, line, nil
+}
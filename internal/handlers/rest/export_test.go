@@ -0,0 +1,245 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExportRouter(h *Handler) *gin.Engine {
+	router := gin.New()
+	router.GET("/api/v1/export", h.ExportHandler())
+	router.POST("/api/v1/import", h.ImportHandler())
+	return router
+}
+
+func TestExportImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mem := filememory.NewMemStorage(false, nil)
+	src := metadata.NewStorage(history.New(mem, time.Hour), metadata.NewRegistry(""))
+	require.NoError(t, src.UpdateGauge("Alloc", 1.5))
+	require.NoError(t, src.UpdateGauge("Alloc", 2.5))
+	require.NoError(t, src.UpdateCounter("PollCount", 3, false))
+	require.NoError(t, src.UpdateGauge("Load", -1))
+	alloc := metadata.Metadata{Name: "Alloc", Type: "gauge", Unit: "bytes"}
+	require.NoError(t, metadata.From(src).Set(alloc))
+	router := newExportRouter(NewHandler(src))
+
+	for _, format := range []string{FormatCSV, FormatJSON, FormatNDJSON} {
+		t.Run(
+			format, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				path := "/api/v1/export?from=1h&format=" + format
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				require.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, exportContentTypes[format], w.Header().Get("Content-Type"))
+				export := w.Body.String()
+
+				restored := history.New(filememory.NewMemStorage(false, nil), time.Hour)
+				dst := metadata.NewStorage(restored, metadata.NewRegistry(""))
+				w = httptest.NewRecorder()
+				request := httptest.NewRequest(
+					http.MethodPost, "/api/v1/import?format="+format, strings.NewReader(export),
+				)
+				newExportRouter(NewHandler(dst)).ServeHTTP(w, request)
+				require.Equal(t, http.StatusOK, w.Code)
+
+				var report ImportReport
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+				assert.Equal(t, 3, report.Accepted, export)
+				assert.Equal(t, 0, report.Rejected, report.Errors)
+				assert.Equal(t, 4, report.Samples)
+
+				counters, gauges := dst.GetMetrics()
+				assert.Equal(t, map[string]int64{"PollCount": 3}, counters)
+				assert.Equal(t, map[string]float64{"Alloc": 2.5, "Load": -1}, gauges)
+				meta, ok := metadata.From(dst).Get("Alloc")
+				require.True(t, ok)
+				assert.Equal(t, "bytes", meta.Unit)
+				// The restored samples precede the one recorded by the import.
+				samples := restored.Range("gauge", "Alloc", time.Time{}, time.Now())
+				values := make([]float64, 0, len(samples))
+				for _, s := range samples {
+					values = append(values, s.Value)
+				}
+				assert.Equal(t, []float64{1.5, 2.5, 2.5}, values)
+			},
+		)
+	}
+}
+
+func TestImportCounters(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := filememory.NewMemStorage(false, nil)
+	router := newExportRouter(NewHandler(st))
+	body := `{"id":"PollCount","type":"counter","delta":3}` + "\n" +
+		`{"id":"Alloc","type":"gauge","value":1}` + "\n" +
+		`{"id":"Alloc","type":"gauge","value":2,"time":"2024-01-01T00:00:00Z"}` + "\n"
+
+	tests := []struct {
+		name     string
+		query    string
+		accepted int
+		lines    []int
+		total    int64
+	}{
+		{name: "Restore", accepted: 2, lines: []int{3}, total: 3},
+		{name: "Retry", accepted: 1, lines: []int{1, 3}, total: 3},
+		{name: "Add", query: "&counters=add", accepted: 2, lines: []int{3}, total: 6},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				request := httptest.NewRequest(
+					http.MethodPost, "/api/v1/import?format=ndjson"+tt.query, strings.NewReader(body),
+				)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, request)
+				require.Equal(t, http.StatusOK, w.Code)
+
+				var report ImportReport
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+				assert.Equal(t, tt.accepted, report.Accepted)
+				lines := make([]int, 0, len(report.Errors))
+				for _, e := range report.Errors {
+					lines = append(lines, e.Line)
+				}
+				assert.Equal(t, tt.lines, lines, report.Errors)
+				total, _, _ := st.GetCounter("PollCount")
+				assert.Equal(t, tt.total, total)
+			},
+		)
+	}
+}
+
+func TestExportFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := filememory.NewMemStorage(false, nil)
+	router := newExportRouter(NewHandler(st))
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+		body     string
+	}{
+		{name: "Empty JSON", path: "/api/v1/export", expected: http.StatusOK, body: "[]"},
+		{
+			name:     "Empty CSV",
+			path:     "/api/v1/export?format=csv",
+			expected: http.StatusOK,
+			body:     "id,type,value,unit,description,time\n",
+		},
+		{name: "Empty NDJSON", path: "/api/v1/export?format=ndjson", expected: http.StatusOK},
+		{name: "Unknown Format", path: "/api/v1/export?format=xml", expected: http.StatusBadRequest},
+		{name: "No History", path: "/api/v1/export?from=1h", expected: http.StatusNotImplemented},
+		{name: "Until Without From", path: "/api/v1/export?until=1h", expected: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				require.Equal(t, tt.expected, w.Code)
+				if tt.expected == http.StatusOK {
+					assert.Equal(t, tt.body, w.Body.String())
+				}
+			},
+		)
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := filememory.NewMemStorage(false, nil)
+	router := newExportRouter(NewHandler(st))
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		expected    int
+		accepted    int
+		lines       []int
+	}{
+		{
+			name:        "CSV",
+			contentType: "text/csv",
+			body:        "type,id,value\ngauge,A,1\ncounter,B,1.5\nhistogram,C,1\ncounter,D,2\n",
+			expected:    http.StatusOK,
+			accepted:    2,
+			lines:       []int{3, 4},
+		},
+		{
+			name:        "CSV Missing Column",
+			contentType: "text/csv",
+			body:        "id,type\nA,gauge\n",
+			expected:    http.StatusBadRequest,
+		},
+		{
+			name:        "NDJSON",
+			contentType: "application/x-ndjson",
+			body: `{"id":"A","type":"gauge","value":1}` + "\n\n" +
+				`{"id":` + "\n" + `{"id":"B","type":"gauge"}` + "\n",
+			expected: http.StatusOK,
+			accepted: 1,
+			lines:    []int{3, 4},
+		},
+		{
+			name:        "JSON",
+			contentType: "application/json",
+			body: `[{"id":"A","type":"gauge","value":1},{"id":1},` +
+				`{"id":"B","type":"counter","delta":1}]`,
+			expected: http.StatusOK,
+			accepted: 2,
+			lines:    []int{2},
+		},
+		{
+			name:        "JSON Syntax Error",
+			contentType: "application/json",
+			body:        `[{"id":"A","type":"gauge","value":1},{"id":`,
+			expected:    http.StatusOK,
+			accepted:    1,
+			lines:       []int{2},
+		},
+		{
+			name:        "JSON Object",
+			contentType: "application/json",
+			body:        `{"id":"A","type":"gauge","value":1}`,
+			expected:    http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				request := httptest.NewRequest(http.MethodPost, "/api/v1/import", strings.NewReader(tt.body))
+				request.Header.Set("Content-Type", tt.contentType)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, request)
+				require.Equal(t, tt.expected, w.Code, w.Body.String())
+				if tt.expected != http.StatusOK {
+					return
+				}
+
+				var report ImportReport
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+				assert.Equal(t, tt.accepted, report.Accepted)
+				lines := make([]int, 0, len(report.Errors))
+				for _, e := range report.Errors {
+					lines = append(lines, e.Line)
+				}
+				assert.Equal(t, tt.lines, lines)
+			},
+		)
+	}
+}
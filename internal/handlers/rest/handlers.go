@@ -4,7 +4,6 @@ package rest
 
 import (
 	"errors"
-	"html/template"
 	"io"
 	"net/http"
 	"strconv"
@@ -13,8 +12,10 @@ import (
 	"github.com/elina-chertova/metrics-alerting.git/internal/config"
 	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
 	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/security"
+	"github.com/elina-chertova/metrics-alerting.git/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/goccy/go-json"
 	"go.uber.org/zap"
@@ -27,8 +28,14 @@ var (
 	ErrValueNil           = errors.New("value is nil, skipping update")
 	ErrFailedJSONCreating = errors.New("failed JSON creation")
 	ErrReadReqBody        = errors.New("error reading request body")
+	ErrInvalidBody        = errors.New("invalid request body")
 )
 
+// ErrorResponse is the body of the errors the handlers report as JSON.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
 // database defines an interface for interacting with a database.
 type database interface {
 	PingDB() gin.HandlerFunc
@@ -37,6 +44,7 @@ type database interface {
 // Handler encapsulates handling logic for metric-related HTTP endpoints.
 type Handler struct {
 	memStorage serviceInterface.MetricsStorage
+	tenants    *tenant.Registry
 }
 
 type HandlerDB struct {
@@ -45,7 +53,67 @@ type HandlerDB struct {
 
 // NewHandler creates a new Handler with the given metrics storage.
 func NewHandler(st serviceInterface.MetricsStorage) *Handler {
-	return &Handler{st}
+	return &Handler{memStorage: st}
+}
+
+// NewTenantHandler creates a new Handler that serves every request from the
+// storage of the tenant resolved by tenant.Middleware.
+func NewTenantHandler(r *tenant.Registry) *Handler {
+	return &Handler{tenants: r}
+}
+
+// Middleware returns the handlers to run before the routes serving metrics.
+// For a Handler created by NewTenantHandler it resolves the tenant.
+func (h *Handler) Middleware() gin.HandlersChain {
+	if h.tenants == nil {
+		return nil
+	}
+	return gin.HandlersChain{tenant.Middleware(h.tenants)}
+}
+
+// storage returns the metrics storage serving the request. If the storage
+// of the tenant can not be opened it responds with 500 and returns nil.
+func (h *Handler) storage(c *gin.Context) serviceInterface.MetricsStorage {
+	if h.tenants == nil {
+		return h.memStorage
+	}
+	st, err := h.tenants.Storage(tenant.FromContext(c))
+	if err != nil && isAPIRequest(c) {
+		abortWithError(c, err)
+		return nil
+	}
+	if err != nil {
+		logger.Error(err.Error(), zap.String("method", c.Request.Method))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil
+	}
+	return st
+}
+
+// storageErrorStatus returns the HTTP status for a storage error.
+func storageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, tenant.ErrSeriesLimit), errors.Is(err, tenant.ErrRateLimit):
+		return http.StatusTooManyRequests
+	case errors.Is(err, metadata.ErrTypeMismatch):
+		return http.StatusConflict
+	case errors.Is(err, metadata.ErrUnsupportedType), errors.Is(err, metadata.ErrEmptyName):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// withMetadata fills the unit and description of m from the metadata
+// registry of st, if it has one.
+func withMetadata(st serviceInterface.MetricsStorage, m f.Metric) f.Metric {
+	if registry := metadata.From(st); registry != nil {
+		if meta, ok := registry.Get(m.ID); ok {
+			m.Unit = meta.Unit
+			m.Description = meta.Description
+		}
+	}
+	return m
 }
 
 // NewHandlerDB creates a new HandlerDB with the given database interface.
@@ -53,18 +121,51 @@ func NewHandlerDB(d database) *HandlerDB {
 	return &HandlerDB{db: d}
 }
 
+// PingDB creates the gin.HandlerFunc of the database health check.
+//
+// @Summary      Check the database
+// @Description  Only registered when metrics are stored in PostgreSQL.
+// @Tags         health
+// @Success      200
+// @Failure      500
+// @Router       /ping [get]
 func (db *HandlerDB) PingDB() gin.HandlerFunc {
 	return db.db.PingDB()
 }
 
 // UpdateBatchMetrics creates a gin.HandlerFunc that handles batch updates
-// of metric data. It processes JSON requests containing multiple metrics
+// of metric data. It decodes the metrics from JSON, protobuf or MessagePack
+// depending on the Content-Type of the request, see formatter.DecodeBatch,
 // and updates them in the storage.
+//
+// @Summary      Update metrics in a batch
+// @Description  Stores a batch of metrics. The body is a JSON array of metrics, an
+// @Description  UpdateBatchMetricsRequest protobuf message or a MessagePack array, depending on
+// @Description  the Content-Type. The body may be gzip-compressed and encrypted with the
+// @Description  public key of the server.
+// @Tags         metrics
+// @Accept       json,application/x-protobuf,application/msgpack
+// @Produce      json
+// @Param        HashSHA256  header    string             false  "HMAC-SHA256 of the body"
+// @Param        metrics     body      []formatter.Metric  true   "Metrics with delta or value"
+// @Success      200         {object}  map[string]string
+// @Failure      400         {string}  string
+// @Failure      429         {string}  string
+// @Failure      500         {string}  string
+// @Router       /updates/ [post]
 func (h *Handler) UpdateBatchMetrics(secretKey string, privateKeyPath string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var m []f.Metric
-
-		var reader io.Reader = c.Request.Body
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		reader, err := requestBody(c.Request)
+		if err != nil {
+			logger.Error(err.Error(), zap.String("method", c.Request.Method))
+			http.Error(c.Writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer reader.Close()
 		body, err := io.ReadAll(reader)
 		if err != nil {
 			logger.Error(ErrReadReqBody.Error(), zap.String("method", c.Request.Method))
@@ -82,16 +183,17 @@ func (h *Handler) UpdateBatchMetrics(secretKey string, privateKeyPath string) gi
 			body = decryptedBody
 		}
 
-		if err = json.Unmarshal(body, &m); err != nil {
-			logger.Error(ErrInvalidJSON.Error(), zap.String("method", c.Request.Method))
-			http.Error(c.Writer, ErrInvalidJSON.Error(), http.StatusBadRequest)
+		m, err := f.DecodeBatch(body, c.GetHeader("Content-Type"))
+		if err != nil {
+			logger.Error(ErrInvalidBody.Error(), zap.String("method", c.Request.Method))
+			http.Error(c.Writer, ErrInvalidBody.Error(), http.StatusBadRequest)
 			return
 		}
 
-		err = h.memStorage.InsertBatchMetrics(m)
+		err = st.InsertBatchMetrics(m)
 		if err != nil {
 			logger.Error(err.Error(), zap.String("method", c.Request.Method))
-			c.String(http.StatusInternalServerError, "Failed data insert")
+			c.String(storageErrorStatus(err), "Failed data insert")
 			return
 		}
 
@@ -112,39 +214,28 @@ func (h *Handler) UpdateBatchMetrics(secretKey string, privateKeyPath string) gi
 	}
 }
 
-// MetricsListHandler creates a gin.HandlerFunc that serves a webpage displaying
-// a list of all stored metrics. It renders the metrics data in an HTML template.
-func (h *Handler) MetricsListHandler() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		tmpl, err := template.New("data").Parse("<!DOCTYPE html>\n<html>\n\n<head>\n    <title>Metric List</title>\n</head>\n\n<body>\n<ul>\n    {{ range $key, $value := .MetricsC }}\n    <p>{{$key}}: {{$value}}</p>\n    {{ end }}\n    {{ range $key, $value := .MetricsG }}\n    <p>{{$key}}: {{$value}}</p>\n    {{ end }}\n</ul>\n</body>\n\n</html>")
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Failed to load template")
-			return
-		}
-		counter, gauge := h.memStorage.GetMetrics()
-		err = tmpl.Execute(
-			c.Writer, struct {
-				MetricsC map[string]int64
-				MetricsG map[string]float64
-			}{
-				MetricsC: counter,
-				MetricsG: gauge,
-			},
-		)
-
-		if err != nil {
-			c.String(http.StatusInternalServerError, "Failed to render template")
-			return
-		}
-		c.Writer.Header().Set("Content-Type", "text/html")
-	}
-}
-
 // GetMetricsJSONHandler creates a gin.HandlerFunc for retrieving a specific metric
 // in JSON format. The handler reads a metric ID and type from the request
 // and returns it as JSON.
+//
+// @Summary      Get a metric
+// @Description  Returns the current value of the metric with the ID and type of the body.
+// @Description  Unknown metrics are returned with a zero value.
+// @Tags         metrics
+// @Accept       json
+// @Produce      json
+// @Param        metric  body      formatter.Metric  true  "Metric ID and type"
+// @Success      200     {object}  formatter.Metric
+// @Header       200     {string}  HashSHA256  "HMAC-SHA256 of the body"
+// @Failure      400     {object}  ErrorResponse
+// @Failure      500     {string}  string
+// @Router       /value/ [post]
 func (h *Handler) GetMetricsJSONHandler(secretKey string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
 		var m f.Metric
 		var err error
 		if err = c.ShouldBindJSON(&m); err != nil {
@@ -157,7 +248,7 @@ func (h *Handler) GetMetricsJSONHandler(secretKey string) gin.HandlerFunc {
 
 		switch m.MType {
 		case config.Counter:
-			val1, _, err = h.memStorage.GetCounter(m.ID)
+			val1, _, err = st.GetCounter(m.ID)
 			metric = f.Metric{ID: m.ID, MType: config.Counter, Delta: &val1}
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
@@ -165,7 +256,7 @@ func (h *Handler) GetMetricsJSONHandler(secretKey string) gin.HandlerFunc {
 				return
 			}
 		case config.Gauge:
-			val2, _, err = h.memStorage.GetGauge(m.ID)
+			val2, _, err = st.GetGauge(m.ID)
 			metric = f.Metric{ID: m.ID, MType: config.Gauge, Value: &val2}
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
@@ -178,7 +269,7 @@ func (h *Handler) GetMetricsJSONHandler(secretKey string) gin.HandlerFunc {
 			return
 		}
 
-		out, err := json.Marshal(metric)
+		out, err := json.Marshal(withMetadata(st, metric))
 		if err != nil {
 			c.String(http.StatusInternalServerError, ErrFailedJSONCreating.Error())
 			return
@@ -197,8 +288,25 @@ func (h *Handler) GetMetricsJSONHandler(secretKey string) gin.HandlerFunc {
 // a specific metric in plain text format. The handler reads metric details from
 // the request URL, performs necessary operations (like updating or retrieving),
 // and responds with the metric value in plain text.
+//
+// @Summary      Get a metric value
+// @Description  Returns the current value of a metric as a JSON number.
+// @Tags         metrics
+// @Produce      plain
+// @Param        metricType  path      string  true  "Metric type"  Enums(counter, gauge)
+// @Param        metricName  path      string  true  "Metric ID"
+// @Success      200         {number}  number
+// @Header       200         {string}  HashSHA256  "HMAC-SHA256 of the body"
+// @Failure      400         {object}  ErrorResponse
+// @Failure      404         {string}  string
+// @Failure      500         {string}  string
+// @Router       /value/{metricType}/{metricName} [get]
 func (h *Handler) GetMetricsTextPlainHandler(secretKey string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
 		var (
 			value any
 			err   error
@@ -209,7 +317,7 @@ func (h *Handler) GetMetricsTextPlainHandler(secretKey string) gin.HandlerFunc {
 
 		switch metricType {
 		case config.Gauge:
-			_, ok, err = h.memStorage.GetGauge(metricName)
+			_, ok, err = st.GetGauge(metricName)
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
 				c.String(http.StatusInternalServerError, err.Error())
@@ -219,14 +327,14 @@ func (h *Handler) GetMetricsTextPlainHandler(secretKey string) gin.HandlerFunc {
 				c.Status(http.StatusNotFound)
 				return
 			}
-			value, _, err = h.memStorage.GetGauge(metricName)
+			value, _, err = st.GetGauge(metricName)
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
 				c.String(http.StatusInternalServerError, err.Error())
 				return
 			}
 		case config.Counter:
-			_, ok, err = h.memStorage.GetCounter(metricName)
+			_, ok, err = st.GetCounter(metricName)
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
 				c.String(http.StatusInternalServerError, err.Error())
@@ -236,7 +344,7 @@ func (h *Handler) GetMetricsTextPlainHandler(secretKey string) gin.HandlerFunc {
 				c.Status(http.StatusNotFound)
 				return
 			}
-			value, _, err = h.memStorage.GetCounter(metricName)
+			value, _, err = st.GetCounter(metricName)
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
 				c.String(http.StatusInternalServerError, err.Error())
@@ -265,8 +373,28 @@ func (h *Handler) GetMetricsTextPlainHandler(secretKey string) gin.HandlerFunc {
 // MetricsJSONHandler creates a gin.HandlerFunc for processing incoming metric data
 // in JSON format. The handler reads JSON formatted metric data from the request body,
 // updates or retrieves the metric in storage, and responds with the updated metric data.
+//
+// @Summary      Update a metric
+// @Description  Adds the delta to a counter or sets the value of a gauge and returns the
+// @Description  metric after the update. The body may be encrypted with the public key of
+// @Description  the server.
+// @Tags         metrics
+// @Accept       json
+// @Produce      json
+// @Param        HashSHA256  header    string            false  "HMAC-SHA256 of the body"
+// @Param        metric      body      formatter.Metric  true   "Metric with delta or value"
+// @Success      200         {object}  formatter.Metric
+// @Failure      400         {object}  ErrorResponse
+// @Failure      409         {object}  ErrorResponse
+// @Failure      429         {string}  string
+// @Failure      500         {string}  string
+// @Router       /update/ [post]
 func (h *Handler) MetricsJSONHandler(secretKey string, privateKeyPath string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
 		var (
 			m   f.Metric
 			err error
@@ -306,20 +434,20 @@ func (h *Handler) MetricsJSONHandler(secretKey string, privateKeyPath string) gi
 				c.JSON(http.StatusBadRequest, gin.H{"error": ErrDeltaNil.Error()})
 				return
 			}
-			_, ok, err = h.memStorage.GetCounter(m.ID)
+			_, ok, err = st.GetCounter(m.ID)
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
 				c.String(http.StatusInternalServerError, err.Error())
 				return
 			}
 			var v1 = *m.Delta
-			err = h.memStorage.UpdateCounter(m.ID, v1, ok)
+			err = st.UpdateCounter(m.ID, v1, ok)
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
-				c.String(http.StatusInternalServerError, err.Error())
+				c.String(storageErrorStatus(err), err.Error())
 				return
 			}
-			v1, _, err = h.memStorage.GetCounter(m.ID)
+			v1, _, err = st.GetCounter(m.ID)
 			returnedMetric = f.Metric{ID: m.ID, MType: config.Counter, Delta: &v1}
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
@@ -333,14 +461,14 @@ func (h *Handler) MetricsJSONHandler(secretKey string, privateKeyPath string) gi
 				return
 			}
 			var v2 = *m.Value
-			err = h.memStorage.UpdateGauge(m.ID, v2)
+			err = st.UpdateGauge(m.ID, v2)
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
-				c.String(http.StatusInternalServerError, err.Error())
+				c.String(storageErrorStatus(err), err.Error())
 				return
 			}
 
-			v2, _, err = h.memStorage.GetGauge(m.ID)
+			v2, _, err = st.GetGauge(m.ID)
 			returnedMetric = f.Metric{ID: m.ID, MType: config.Gauge, Value: &v2}
 			if err != nil {
 				logger.Error(err.Error(), zap.String("method", c.Request.Method))
@@ -353,7 +481,13 @@ func (h *Handler) MetricsJSONHandler(secretKey string, privateKeyPath string) gi
 			return
 		}
 
-		out, err := json.Marshal(returnedMetric)
+		if err = registerMetadata(st, m); err != nil {
+			logger.Error(err.Error(), zap.String("method", c.Request.Method))
+			c.JSON(storageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+
+		out, err := json.Marshal(withMetadata(st, returnedMetric))
 		if err != nil {
 			logger.Error(ErrFailedJSONCreating.Error(), zap.String("method", c.Request.Method))
 			c.String(http.StatusInternalServerError, ErrFailedJSONCreating.Error())
@@ -372,8 +506,26 @@ func (h *Handler) MetricsJSONHandler(secretKey string, privateKeyPath string) gi
 // submitted in plain text format. The handler parses the metric type, name,
 // and value from the request, updates or retrieves the metric in storage,
 // and sends back a plain text response.
+//
+// @Summary      Update a metric from the URL
+// @Description  Adds the value to a counter or sets the value of a gauge.
+// @Tags         metrics
+// @Produce      plain
+// @Param        metricType   path  string  true  "Metric type"  Enums(counter, gauge)
+// @Param        metricName   path  string  true  "Metric ID"
+// @Param        metricValue  path  string  true  "Integer delta of a counter or value of a gauge"
+// @Success      200
+// @Failure      400
+// @Failure      404
+// @Failure      429  {string}  string
+// @Failure      500  {string}  string
+// @Router       /update/{metricType}/{metricName}/{metricValue} [post]
 func (h *Handler) MetricsTextPlainHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
 		var ok bool
 		if err := c.Request.ParseForm(); err != nil {
 			c.Status(http.StatusBadRequest)
@@ -390,10 +542,10 @@ func (h *Handler) MetricsTextPlainHandler() gin.HandlerFunc {
 		switch metricType {
 		case config.Gauge:
 			if convertedMetricValueFloat, err := strconv.ParseFloat(metricValue, 64); err == nil {
-				err = h.memStorage.UpdateGauge(metricName, convertedMetricValueFloat)
+				err = st.UpdateGauge(metricName, convertedMetricValueFloat)
 				if err != nil {
 					logger.Error(err.Error(), zap.String("method", c.Request.Method))
-					c.String(http.StatusInternalServerError, err.Error())
+					c.String(storageErrorStatus(err), err.Error())
 					return
 				}
 			} else {
@@ -403,16 +555,16 @@ func (h *Handler) MetricsTextPlainHandler() gin.HandlerFunc {
 			}
 		case config.Counter:
 			if convertedMetricValueInt, err := strconv.Atoi(metricValue); err == nil {
-				_, ok, err = h.memStorage.GetCounter(metricName)
+				_, ok, err = st.GetCounter(metricName)
 				if err != nil {
 					logger.Error(err.Error(), zap.String("method", c.Request.Method))
 					c.String(http.StatusInternalServerError, err.Error())
 					return
 				}
-				err = h.memStorage.UpdateCounter(metricName, int64(convertedMetricValueInt), ok)
+				err = st.UpdateCounter(metricName, int64(convertedMetricValueInt), ok)
 				if err != nil {
 					logger.Error(err.Error(), zap.String("method", c.Request.Method))
-					c.String(http.StatusInternalServerError, err.Error())
+					c.String(storageErrorStatus(err), err.Error())
 					return
 				}
 			} else {
@@ -0,0 +1,164 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/influx"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxInfluxBody limits the decompressed size of a line protocol request.
+const maxInfluxBody = 32 << 20
+
+// ErrCounterOverflow is returned for integer fields whose value can not be
+// stored in a counter.
+var ErrCounterOverflow = errors.New("integer value out of the counter range")
+
+// InfluxError is an error in the format of the InfluxDB v2 API.
+type InfluxError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// influxError writes an error in the format of the InfluxDB v2 API.
+func influxError(c *gin.Context, status int, code string, err error) {
+	logger.Error(err.Error(), zap.String("method", c.Request.Method))
+	c.JSON(status, InfluxError{Code: code, Message: err.Error()})
+}
+
+// InfluxWriteHandler creates a gin.HandlerFunc that accepts InfluxDB line
+// protocol as sent to the /api/v2/write endpoint of InfluxDB. Every field
+// becomes a metric named measurement_field with the tags as labels.
+// Integer fields are stored as counters, adding their values, when
+// integerCounters is set and as gauges otherwise; values and sums outside
+// the int64 range are rejected with 400; float and boolean fields
+// are gauges and string fields are ignored. Within one request the value
+// with the latest timestamp wins for gauges.
+//
+// @Summary      Write InfluxDB line protocol
+// @Description  Stores every field of every point as a metric named measurement_field with
+// @Description  the tags as labels. The body may be gzip-compressed and is limited to 32 MiB
+// @Description  after decompression.
+// @Tags         ingest
+// @Accept       plain
+// @Produce      json
+// @Param        body  body  string  true  "Points in line protocol"
+// @Success      204
+// @Failure      400  {object}  InfluxError
+// @Failure      413  {object}  InfluxError
+// @Failure      500  {object}  InfluxError
+// @Router       /api/v2/write [post]
+func (h *Handler) InfluxWriteHandler(integerCounters bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		unit, err := influx.Precision(c.Query("precision"))
+		if err != nil {
+			influxError(c, http.StatusBadRequest, "invalid", err)
+			return
+		}
+		body, err := readInfluxBody(c.Writer, c.Request)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			influxError(c, http.StatusRequestEntityTooLarge, "request too large", err)
+			return
+		}
+		if err != nil {
+			influxError(c, http.StatusBadRequest, "invalid", err)
+			return
+		}
+		points, err := influx.Parse(body, unit, time.Now())
+		if err != nil {
+			influxError(c, http.StatusBadRequest, "invalid", err)
+			return
+		}
+
+		metrics, err := influxMetrics(points, integerCounters)
+		if err != nil {
+			influxError(c, http.StatusBadRequest, "invalid", err)
+			return
+		}
+		if len(metrics) > 0 {
+			if err = storage.InsertBatch(st, metrics); err != nil {
+				influxError(c, storageErrorStatus(err), "internal error", err)
+				return
+			}
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// readInfluxBody reads the whole request body, see requestBody. It fails
+// with an *http.MaxBytesError when the body exceeds maxInfluxBody.
+func readInfluxBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	body, err := requestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	body = http.MaxBytesReader(w, body, maxInfluxBody)
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// influxMetrics converts points to metrics, see InfluxWriteHandler. With
+// integerCounters set, integer values are added exactly and it fails with
+// ErrCounterOverflow when a value or a sum does not fit in an int64.
+func influxMetrics(points []influx.Point, integerCounters bool) ([]f.Metric, error) {
+	counters := make(map[string]int64)
+	gauges := make(map[string]float64)
+	times := make(map[string]time.Time)
+	for _, p := range points {
+		for _, field := range p.Fields {
+			id := f.SeriesID(p.Measurement+"_"+field.Key, p.Tags)
+			switch {
+			case field.Kind == influx.String:
+			case integerCounters && (field.Kind == influx.Integer || field.Kind == influx.Unsigned):
+				delta := field.Int
+				if field.Kind == influx.Unsigned {
+					if field.Uint > math.MaxInt64 {
+						return nil, fmt.Errorf("%w: %s=%du", ErrCounterOverflow, id, field.Uint)
+					}
+					delta = int64(field.Uint)
+				}
+				sum := counters[id] + delta
+				if (delta > 0 && sum < counters[id]) || (delta < 0 && sum > counters[id]) {
+					return nil, fmt.Errorf("%w: sum of %s", ErrCounterOverflow, id)
+				}
+				counters[id] = sum
+			default:
+				if t, ok := times[id]; !ok || !p.Time.Before(t) {
+					gauges[id], times[id] = field.Value, p.Time
+				}
+			}
+		}
+	}
+
+	metrics := make([]f.Metric, 0, len(counters)+len(gauges))
+	for id, delta := range counters {
+		delta := delta
+		metrics = append(metrics, f.Metric{ID: id, MType: config.Counter, Delta: &delta})
+	}
+	for id, value := range gauges {
+		value := value
+		metrics = append(metrics, f.Metric{ID: id, MType: config.Gauge, Value: &value})
+	}
+	sort.Slice(
+		metrics, func(i, j int) bool {
+			return metrics[i].ID < metrics[j].ID
+		},
+	)
+	return metrics, nil
+}
@@ -0,0 +1,126 @@
+package rest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfluxWriteHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte("load,host=a value=0.7\n"))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+
+	tests := []struct {
+		name            string
+		query           string
+		body            []byte
+		gzip            bool
+		integerCounters bool
+		expected        int
+		code            string
+		counters        map[string]int64
+		gauges          map[string]float64
+	}{
+		{
+			name:     "Latest Timestamp Wins",
+			query:    "?precision=s",
+			body:     []byte("cpu,host=a usage=0.9 20\ncpu,host=a usage=0.5 10\ncpu,host=a note=\"x\" 30"),
+			expected: http.StatusNoContent,
+			gauges:   map[string]float64{`cpu_usage{host="a"}`: 0.9},
+		},
+		{
+			name:     "Integers As Gauges",
+			body:     []byte("http,code=200 requests=3i\nhttp,code=200 requests=4i"),
+			expected: http.StatusNoContent,
+			gauges:   map[string]float64{`http_requests{code="200"}`: 4},
+		},
+		{
+			name:            "Integers As Counters",
+			body:            []byte("http,code=200 requests=3i\nhttp,code=200 requests=4i,up=t"),
+			integerCounters: true,
+			expected:        http.StatusNoContent,
+			counters:        map[string]int64{`http_requests{code="200"}`: 7},
+			gauges:          map[string]float64{`http_up{code="200"}`: 1},
+		},
+		{
+			name:            "Large Integers Added Exactly",
+			body:            []byte("big v=9007199254740993i\nbig v=2u"),
+			integerCounters: true,
+			expected:        http.StatusNoContent,
+			counters:        map[string]int64{"big_v": 9007199254740995},
+		},
+		{
+			name:            "Unsigned Out Of Range",
+			body:            []byte("big v=9223372036854775808u"),
+			integerCounters: true,
+			expected:        http.StatusBadRequest,
+		},
+		{
+			name:            "Sum Out Of Range",
+			body:            []byte("big v=9223372036854775807i\nbig v=1i"),
+			integerCounters: true,
+			expected:        http.StatusBadRequest,
+		},
+		{
+			name:     "Gzip Body",
+			body:     compressed.Bytes(),
+			gzip:     true,
+			expected: http.StatusNoContent,
+			gauges:   map[string]float64{`load_value{host="a"}`: 0.7},
+		},
+		{
+			name:     "Body Too Large",
+			body:     bytes.Repeat([]byte("cpu v=1\n"), maxInfluxBody/8+1),
+			expected: http.StatusRequestEntityTooLarge,
+			code:     "request too large",
+		},
+		{name: "Invalid Line", body: []byte("cpu"), expected: http.StatusBadRequest},
+		{name: "Invalid Precision", query: "?precision=h", body: []byte("cpu v=1"), expected: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				st := filememory.NewMemStorage(false, nil)
+				router := gin.New()
+				router.POST("/api/v2/write", NewHandler(st).InfluxWriteHandler(tt.integerCounters))
+
+				req := httptest.NewRequest(http.MethodPost, "/api/v2/write"+tt.query, bytes.NewReader(tt.body))
+				if tt.gzip {
+					req.Header.Set("Content-Encoding", "gzip")
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, tt.expected, w.Code)
+				if tt.expected != http.StatusNoContent {
+					if tt.code == "" {
+						tt.code = "invalid"
+					}
+					assert.Contains(t, w.Body.String(), `"code":"`+tt.code+`"`)
+					return
+				}
+				counters, gauges := st.GetMetrics()
+				if tt.counters == nil {
+					tt.counters = map[string]int64{}
+				}
+				if tt.gauges == nil {
+					tt.gauges = map[string]float64{}
+				}
+				assert.Equal(t, tt.counters, counters)
+				assert.Equal(t, tt.gauges, gauges)
+			},
+		)
+	}
+}
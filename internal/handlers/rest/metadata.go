@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrMetadataDisabled = errors.New("metadata registry is not enabled")
+	ErrMetadataNotFound = errors.New("metadata not found")
+)
+
+// ListMetadataHandler creates a gin.HandlerFunc that returns the metadata
+// of all registered metric names as JSON.
+//
+// @Summary      List metric metadata
+// @Tags         metadata
+// @Produce      json
+// @Success      200  {array}   metadata.Metadata
+// @Failure      501  {object}  ErrorResponse
+// @Router       /metadata/ [get]
+func (h *Handler) ListMetadataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		registry := metadata.From(st)
+		if registry == nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": ErrMetadataDisabled.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, registry.List())
+	}
+}
+
+// GetMetadataHandler creates a gin.HandlerFunc that returns the metadata
+// of a single metric name as JSON.
+//
+// @Summary      Get the metadata of a metric
+// @Tags         metadata
+// @Produce      json
+// @Param        metricName  path      string  true  "Metric name"
+// @Success      200         {object}  metadata.Metadata
+// @Failure      404         {object}  ErrorResponse
+// @Failure      501         {object}  ErrorResponse
+// @Router       /metadata/{metricName} [get]
+func (h *Handler) GetMetadataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		registry := metadata.From(st)
+		if registry == nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": ErrMetadataDisabled.Error()})
+			return
+		}
+		m, ok := registry.Get(c.Param("metricName"))
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": ErrMetadataNotFound.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, m)
+	}
+}
+
+// SetMetadataHandler creates a gin.HandlerFunc that registers the unit,
+// description and type of a metric name. Changing a declared type is
+// rejected with 409 Conflict.
+//
+// @Summary      Set the metadata of a metric
+// @Tags         metadata
+// @Accept       json
+// @Produce      json
+// @Param        metricName  path      string             true   "Metric name"
+// @Param        HashSHA256  header    string             false  "HMAC-SHA256 of the body"
+// @Param        metadata    body      metadata.Metadata  true   "Unit, description and type"
+// @Success      200         {object}  metadata.Metadata
+// @Failure      400         {object}  ErrorResponse
+// @Failure      409         {object}  ErrorResponse
+// @Failure      501         {object}  ErrorResponse
+// @Router       /metadata/{metricName} [put]
+func (h *Handler) SetMetadataHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		registry := metadata.From(st)
+		if registry == nil {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": ErrMetadataDisabled.Error()})
+			return
+		}
+		var m metadata.Metadata
+		if err := c.ShouldBindJSON(&m); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": ErrInvalidJSON.Error()})
+			return
+		}
+		m.Name = c.Param("metricName")
+		if err := registry.Set(m); err != nil {
+			logger.Error(err.Error(), zap.String("method", c.Request.Method))
+			c.JSON(storageErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		m, _ = registry.Get(m.Name)
+		c.JSON(http.StatusOK, m)
+	}
+}
@@ -0,0 +1,102 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetadataHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	st := metadata.NewStorage(filememory.NewMemStorage(false, nil), metadata.NewRegistry(""))
+	h := NewHandler(st)
+	router.GET("/metadata/", h.ListMetadataHandler())
+	router.GET("/metadata/:metricName", h.GetMetadataHandler())
+	router.PUT("/metadata/:metricName", h.SetMetadataHandler())
+	router.POST("/update/", h.MetricsJSONHandler("", ""))
+	router.POST("/value/", h.GetMetricsJSONHandler(""))
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		body         string
+		expected     int
+		expectedBody string
+	}{
+		{
+			name:     "Unknown Metadata",
+			method:   http.MethodGet,
+			path:     "/metadata/Alloc",
+			expected: http.StatusNotFound,
+		},
+		{
+			name:         "Register Metadata",
+			method:       http.MethodPut,
+			path:         "/metadata/Alloc",
+			body:         `{"type":"gauge","unit":"bytes","description":"heap"}`,
+			expected:     http.StatusOK,
+			expectedBody: `"unit":"bytes"`,
+		},
+		{
+			name:     "Counter Update Of Gauge",
+			method:   http.MethodPost,
+			path:     "/update/",
+			body:     `{"id":"Alloc","type":"counter","delta":1}`,
+			expected: http.StatusConflict,
+		},
+		{
+			name:         "Gauge Update",
+			method:       http.MethodPost,
+			path:         "/update/",
+			body:         `{"id":"Alloc","type":"gauge","value":1}`,
+			expected:     http.StatusOK,
+			expectedBody: `"description":"heap"`,
+		},
+		{
+			name:         "Value With Metadata",
+			method:       http.MethodPost,
+			path:         "/value/",
+			body:         `{"id":"Alloc","type":"gauge"}`,
+			expected:     http.StatusOK,
+			expectedBody: `"unit":"bytes"`,
+		},
+		{
+			name:     "Change Declared Type",
+			method:   http.MethodPut,
+			path:     "/metadata/Alloc",
+			body:     `{"type":"counter"}`,
+			expected: http.StatusConflict,
+		},
+		{
+			name:         "List Metadata",
+			method:       http.MethodGet,
+			path:         "/metadata/",
+			expected:     http.StatusOK,
+			expectedBody: `"name":"Alloc"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				request := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+				request.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+
+				router.ServeHTTP(w, request)
+				assert.Equal(t, tt.expected, w.Code)
+				if tt.expectedBody != "" {
+					assert.Contains(t, w.Body.String(), tt.expectedBody)
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/exposition"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// PrometheusHandler creates a gin.HandlerFunc that exposes all stored
+// metrics for scraping by Prometheus. The response is in OpenMetrics when
+// the Accept header prefers it and in the Prometheus text format otherwise.
+//
+// @Summary      Prometheus exposition
+// @Description  Exposes all stored metrics in the Prometheus text format, or in OpenMetrics
+// @Description  when the Accept header prefers it.
+// @Tags         query
+// @Produce      plain,application/openmetrics-text
+// @Success      200  {string}  string
+// @Router       /metrics [get]
+func (h *Handler) PrometheusHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		counter, gauge := st.GetMetrics()
+		families := exposition.Families(counter, gauge, metadata.From(st))
+
+		format := exposition.Negotiate(c.GetHeader("Accept"))
+		c.Header("Content-Type", format)
+		c.Status(http.StatusOK)
+		if err := exposition.Write(c.Writer, families, format); err != nil {
+			logger.Error(err.Error(), zap.String("method", c.Request.Method))
+		}
+	}
+}
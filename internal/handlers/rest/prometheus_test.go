@@ -0,0 +1,56 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/exposition"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := filememory.NewMemStorage(false, nil)
+	_ = st.UpdateCounter("PollCount", 3, false)
+	_ = st.UpdateGauge("Alloc", 2.5)
+	router := gin.New()
+	router.GET("/metrics", NewHandler(st).PrometheusHandler())
+
+	tests := []struct {
+		name         string
+		accept       string
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "Text",
+			expectedType: exposition.FormatText,
+			expectedBody: "# TYPE Alloc gauge\nAlloc 2.5\n# TYPE PollCount counter\nPollCount 3\n",
+		},
+		{
+			name:         "OpenMetrics",
+			accept:       "application/openmetrics-text; version=1.0.0",
+			expectedType: exposition.FormatOpenMetrics,
+			expectedBody: "# TYPE Alloc gauge\nAlloc 2.5\n# TYPE PollCount counter\nPollCount_total 3\n# EOF\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+				if tt.accept != "" {
+					req.Header.Set("Accept", tt.accept)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, tt.expectedType, w.Header().Get("Content-Type"))
+				assert.Equal(t, tt.expectedBody, w.Body.String())
+			},
+		)
+	}
+}
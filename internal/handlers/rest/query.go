@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/expr"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/gin-gonic/gin"
+)
+
+// Result types of a QueryResponse.
+const (
+	ResultScalar = "scalar"
+	ResultVector = "vector"
+)
+
+// QueryResponse is the result of an expression: Value for a scalar and
+// Series for a vector.
+type QueryResponse struct {
+	Type   string        `json:"type" enums:"scalar,vector"`
+	Value  *float64      `json:"value,omitempty"`
+	Series []QuerySeries `json:"series,omitempty"`
+}
+
+// QuerySeries is a series of a vector result. Name is empty for series
+// computed by operators, functions and aggregations.
+type QuerySeries struct {
+	ID     string    `json:"id"`
+	Name   string    `json:"name,omitempty"`
+	Labels []f.Label `json:"labels,omitempty"`
+	Value  float64   `json:"value"`
+}
+
+// QueryHandler creates a gin.HandlerFunc that evaluates the expression in
+// the expr query parameter over the stored metrics, see package expr for
+// the language.
+//
+// @Summary      Evaluate an expression
+// @Description  Supports arithmetic between series, abs, rate, avg_over_time,
+// @Description  min_over_time, max_over_time and sum/avg/min/max/count by (labels).
+// @Tags         api
+// @Produce      json
+// @Param        expr  query     string  true  "Expression, e.g. sum by (code) (rate(requests[5m]))"
+// @Success      200   {object}  QueryResponse
+// @Failure      400   {object}  APIErrorResponse
+// @Failure      500   {object}  APIErrorResponse
+// @Failure      501   {object}  APIErrorResponse
+// @Router       /api/v1/query [get]
+func (h *Handler) QueryHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		e, err := expr.Parse(c.Query("expr"))
+		if err != nil {
+			abortWithError(c, fmt.Errorf("%w: expr: %v", ErrInvalidParameter, err))
+			return
+		}
+		v, err := expr.Eval(e, st, time.Now())
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		switch v := v.(type) {
+		case expr.Scalar:
+			value := float64(v)
+			c.JSON(http.StatusOK, QueryResponse{Type: ResultScalar, Value: &value})
+		case expr.Vector:
+			series := make([]QuerySeries, len(v))
+			for i, s := range v {
+				series[i] = QuerySeries{ID: s.ID(), Name: s.Name, Labels: s.Labels, Value: s.Value}
+			}
+			c.JSON(http.StatusOK, QueryResponse{Type: ResultVector, Series: series})
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := filememory.NewMemStorage(false, nil)
+	require.NoError(t, st.UpdateGauge("HeapInuse", 30))
+	require.NoError(t, st.UpdateGauge("HeapSys", 120))
+	require.NoError(t, st.UpdateCounter(`requests{code="200"}`, 7, false))
+	require.NoError(t, st.UpdateCounter(`requests{code="500"}`, 1, false))
+	router := gin.New()
+	router.GET("/api/v1/query", NewHandler(st).QueryHandler())
+
+	half := 0.5
+	tests := []struct {
+		name     string
+		expr     string
+		expected int
+		code     ErrorCode
+		response QueryResponse
+	}{
+		{
+			name:     "Scalar",
+			expr:     "1 / 2",
+			expected: http.StatusOK,
+			response: QueryResponse{Type: ResultScalar, Value: &half},
+		},
+		{
+			name:     "Selector",
+			expr:     `requests{code="500"}`,
+			expected: http.StatusOK,
+			response: QueryResponse{
+				Type: ResultVector,
+				Series: []QuerySeries{
+					{
+						ID:     `requests{code="500"}`,
+						Name:   "requests",
+						Labels: []f.Label{{Name: "code", Value: "500"}},
+						Value:  1,
+					},
+				},
+			},
+		},
+		{
+			name:     "Arithmetic",
+			expr:     "HeapInuse / HeapSys",
+			expected: http.StatusOK,
+			response: QueryResponse{Type: ResultVector, Series: []QuerySeries{{Value: 0.25}}},
+		},
+		{
+			name:     "Aggregation",
+			expr:     "sum(requests)",
+			expected: http.StatusOK,
+			response: QueryResponse{Type: ResultVector, Series: []QuerySeries{{Value: 8}}},
+		},
+		{name: "Empty", expr: "", expected: http.StatusBadRequest, code: CodeInvalidRequest},
+		{name: "Syntax Error", expr: "sum(", expected: http.StatusBadRequest, code: CodeInvalidRequest},
+		{name: "Type Error", expr: "sum(1)", expected: http.StatusBadRequest, code: CodeInvalidRequest},
+		{name: "Not Finite", expr: "1 / 0", expected: http.StatusBadRequest, code: CodeInvalidRequest},
+		{
+			name:     "No History",
+			expr:     "rate(requests[5m])",
+			expected: http.StatusNotImplemented,
+			code:     CodeNotEnabled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				path := "/api/v1/query?expr=" + url.QueryEscape(tt.expr)
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				require.Equal(t, tt.expected, w.Code, w.Body.String())
+				if tt.expected != http.StatusOK {
+					var response APIErrorResponse
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+					assert.Equal(t, tt.code, response.Error.Code)
+					return
+				}
+				var response QueryResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Equal(t, tt.response, response)
+			},
+		)
+	}
+}
@@ -0,0 +1,157 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/elina-chertova/metrics-alerting.git/api/prompb"
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/snappy"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding, expected snappy")
+	ErrInvalidSnappy       = errors.New("invalid snappy-compressed body")
+	ErrInvalidWriteReq     = errors.New("invalid remote write request")
+	ErrWriteRequestTooBig  = errors.New("remote write request too large")
+)
+
+// maxRemoteWriteSize limits the decompressed size of a remote write request.
+const maxRemoteWriteSize = 32 << 20
+
+// maxRemoteWriteBody limits the compressed size of a remote write request
+// to the largest snappy encoding of maxRemoteWriteSize bytes.
+var maxRemoteWriteBody = int64(snappy.MaxEncodedLen(maxRemoteWriteSize))
+
+// staleNaN is the bit pattern Prometheus uses to mark a series as stale.
+const staleNaN = 0x7ff0000000000002
+
+// RemoteWriteHandler creates a gin.HandlerFunc that accepts Prometheus
+// remote_write requests: snappy-compressed protobuf WriteRequest bodies.
+// Every series is stored as a gauge holding its most recent sample, under
+// an ID combining the metric name and the labels, e.g. up{job="node"}.
+//
+// @Summary      Prometheus remote write
+// @Description  Stores every series of a snappy-compressed protobuf WriteRequest as a gauge
+// @Description  holding its most recent sample.
+// @Tags         ingest
+// @Accept       application/x-protobuf
+// @Produce      plain
+// @Param        Content-Encoding  header  string  true  "Must be snappy"
+// @Param        body              body    string  true  "Snappy-compressed WriteRequest"
+// @Success      204
+// @Failure      400  {string}  string
+// @Failure      413  {string}  string
+// @Failure      415  {string}  string
+// @Failure      500  {string}  string
+// @Router       /api/v1/write [post]
+func (h *Handler) RemoteWriteHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		encoding := c.GetHeader("Content-Encoding")
+		if !strings.EqualFold(strings.TrimSpace(encoding), "snappy") {
+			logger.Error(ErrUnsupportedEncoding.Error(), zap.String("method", c.Request.Method))
+			c.String(http.StatusUnsupportedMediaType, ErrUnsupportedEncoding.Error())
+			return
+		}
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, maxRemoteWriteBody)
+		compressed, err := io.ReadAll(body)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.String(http.StatusRequestEntityTooLarge, ErrWriteRequestTooBig.Error())
+			return
+		}
+		if err != nil {
+			logger.Error(ErrReadReqBody.Error(), zap.String("method", c.Request.Method))
+			c.String(http.StatusInternalServerError, ErrReadReqBody.Error())
+			return
+		}
+		size, err := snappy.DecodedLen(compressed)
+		if err != nil {
+			logger.Error(ErrInvalidSnappy.Error(), zap.String("method", c.Request.Method))
+			c.String(http.StatusBadRequest, ErrInvalidSnappy.Error())
+			return
+		}
+		if size > maxRemoteWriteSize {
+			c.String(http.StatusRequestEntityTooLarge, ErrWriteRequestTooBig.Error())
+			return
+		}
+		data, err := snappy.Decode(nil, compressed)
+		if err != nil {
+			logger.Error(ErrInvalidSnappy.Error(), zap.String("method", c.Request.Method))
+			c.String(http.StatusBadRequest, ErrInvalidSnappy.Error())
+			return
+		}
+
+		var req prompb.WriteRequest
+		if err = proto.Unmarshal(data, &req); err != nil {
+			logger.Error(ErrInvalidWriteReq.Error(), zap.String("method", c.Request.Method))
+			c.String(http.StatusBadRequest, ErrInvalidWriteReq.Error())
+			return
+		}
+
+		metrics := remoteWriteMetrics(&req)
+		if len(metrics) > 0 {
+			if err = storage.InsertBatch(st, metrics); err != nil {
+				logger.Error(err.Error(), zap.String("method", c.Request.Method))
+				c.String(storageErrorStatus(err), err.Error())
+				return
+			}
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// remoteWriteMetrics converts the series of a remote write request into
+// gauges. Series without a name and samples marking a series as stale are
+// skipped.
+func remoteWriteMetrics(req *prompb.WriteRequest) []f.Metric {
+	metrics := make([]f.Metric, 0, len(req.Timeseries))
+	for _, ts := range req.Timeseries {
+		var (
+			name   string
+			labels []f.Label
+		)
+		for _, l := range ts.Labels {
+			if l.Name == "__name__" {
+				name = l.Value
+				continue
+			}
+			labels = append(labels, f.Label{Name: l.Name, Value: l.Value})
+		}
+		if name == "" {
+			continue
+		}
+
+		var latest *prompb.Sample
+		for _, s := range ts.Samples {
+			if math.Float64bits(s.Value) == staleNaN {
+				continue
+			}
+			if latest == nil || s.Timestamp >= latest.Timestamp {
+				latest = s
+			}
+		}
+		if latest == nil {
+			continue
+		}
+		value := latest.Value
+		metrics = append(
+			metrics,
+			f.Metric{ID: f.SeriesID(name, labels), MType: config.Gauge, Value: &value},
+		)
+	}
+	return metrics
+}
@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/api/prompb"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/snappy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// batchStorage is a MemStorage that accepts batch inserts.
+type batchStorage struct {
+	*filememory.MemStorage
+}
+
+func (s batchStorage) InsertBatchMetrics(metrics []f.Metric) error {
+	for _, m := range metrics {
+		if m.Delta != nil {
+			_ = s.UpdateCounter(m.ID, *m.Delta, true)
+		} else {
+			_ = s.UpdateGauge(m.ID, *m.Value)
+		}
+	}
+	return nil
+}
+
+func TestRemoteWriteHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := batchStorage{filememory.NewMemStorage(false, nil)}
+	router := gin.New()
+	router.POST("/api/v1/write", NewHandler(st).RemoteWriteHandler())
+	router.POST("/memory/write", NewHandler(filememory.NewMemStorage(false, nil)).RemoteWriteHandler())
+
+	req := &prompb.WriteRequest{
+		Timeseries: []*prompb.TimeSeries{
+			{
+				Labels: []*prompb.Label{
+					{Name: "__name__", Value: "up"},
+					{Name: "job", Value: "node"},
+					{Name: "instance", Value: "a:9100"},
+				},
+				Samples: []*prompb.Sample{{Value: 0, Timestamp: 1}, {Value: 1, Timestamp: 2}},
+			},
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "go_goroutines"}},
+				Samples: []*prompb.Sample{{Value: 12, Timestamp: 2}},
+			},
+			{
+				Labels:  []*prompb.Label{{Name: "__name__", Value: "gone"}},
+				Samples: []*prompb.Sample{{Value: math.Float64frombits(staleNaN), Timestamp: 3}},
+			},
+			{
+				Labels:  []*prompb.Label{{Name: "job", Value: "nameless"}},
+				Samples: []*prompb.Sample{{Value: 1, Timestamp: 3}},
+			},
+		},
+	}
+	data, err := proto.Marshal(req)
+	require.NoError(t, err)
+	body := snappy.Encode(nil, data)
+
+	tests := []struct {
+		name     string
+		path     string
+		body     []byte
+		encoding string
+		expected int
+	}{
+		{name: "Valid Request", path: "/api/v1/write", body: body, expected: http.StatusNoContent},
+		{name: "Invalid Snappy", path: "/api/v1/write", body: []byte("plain"), expected: http.StatusBadRequest},
+		{
+			name:     "Invalid Protobuf",
+			path:     "/api/v1/write",
+			body:     snappy.Encode(nil, []byte{0xff, 0xff}),
+			expected: http.StatusBadRequest,
+		},
+		{name: "No Batch Support", path: "/memory/write", body: body, expected: http.StatusNoContent},
+		{
+			name:     "Gzip Encoding",
+			path:     "/api/v1/write",
+			body:     body,
+			encoding: "gzip",
+			expected: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:     "Body Too Large",
+			path:     "/api/v1/write",
+			body:     make([]byte, maxRemoteWriteBody+1),
+			expected: http.StatusRequestEntityTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				r := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewReader(tt.body))
+				if tt.encoding == "" {
+					tt.encoding = "snappy"
+				}
+				r.Header.Set("Content-Encoding", tt.encoding)
+				r.Header.Set("Content-Type", "application/x-protobuf")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, r)
+				assert.Equal(t, tt.expected, w.Code)
+			},
+		)
+	}
+
+	_, gauges := st.GetMetrics()
+	assert.Equal(
+		t,
+		map[string]float64{`up{instance="a:9100",job="node"}`: 1, "go_goroutines": 12},
+		gauges,
+	)
+}
@@ -0,0 +1,154 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/chart"
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var ErrInvalidTime = errors.New("invalid time, expected a duration like -1h, unix seconds or RFC 3339")
+
+// maxChartSize limits the width and height of rendered charts in pixels.
+const maxChartSize = 4000
+
+// RenderHandler creates a gin.HandlerFunc that renders the history of a
+// metric as an SVG line chart. The query parameters are:
+//   - id: the metric ID, required;
+//   - type: counter or gauge, by default the type the metric is stored as;
+//   - from and until: the time range, see parseTime, by default the hour
+//     before now;
+//   - width and height: the size in pixels;
+//   - title: the title, by default the metric ID;
+//   - sparkline: true to render the line only.
+//
+// @Summary      Render a metric chart
+// @Description  Renders the history of a metric as an SVG line chart.
+// @Tags         query
+// @Produce      image/svg+xml
+// @Param        id         query     string   true   "Metric ID"
+// @Param        type       query     string   false  "Metric type"  Enums(counter, gauge)
+// @Param        from       query     string   false  "Start, e.g. -1h, unix seconds or RFC 3339"
+// @Param        until      query     string   false  "End, e.g. -5m, unix seconds or RFC 3339"
+// @Param        width      query     int      false  "Width in pixels"
+// @Param        height     query     int      false  "Height in pixels"
+// @Param        title      query     string   false  "Title, the metric ID by default"
+// @Param        sparkline  query     boolean  false  "Render the line only"
+// @Success      200        {string}  string
+// @Failure      400        {object}  APIErrorResponse
+// @Failure      404        {object}  APIErrorResponse
+// @Failure      501        {object}  APIErrorResponse
+// @Router       /api/v1/render [get]
+func (h *Handler) RenderHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		hist := history.Find(st)
+		if hist == nil {
+			abortWithError(c, ErrHistoryDisabled)
+			return
+		}
+		opts, err := chartOptions(c)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		id, mType := c.Query("id"), c.Query("type")
+		if id == "" {
+			abortWithError(c, ErrMissingID)
+			return
+		}
+		if mType == "" {
+			// Charts of metrics stored as gauge and counter show the gauge.
+			mType = config.Gauge
+			if _, err = getMetric(st, mType, id); errors.Is(err, ErrMetricNotFound) {
+				mType = config.Counter
+			}
+		}
+		if _, err = getMetric(st, mType, id); err != nil {
+			abortWithError(c, err)
+			return
+		}
+		if opts.Title == "" {
+			opts.Title = id
+		}
+
+		c.Header("Content-Type", "image/svg+xml")
+		c.Header("Cache-Control", "no-cache")
+		c.Status(http.StatusOK)
+		if err := chart.SVG(c.Writer, hist.Range(mType, id, opts.From, opts.To), opts); err != nil {
+			logger.Error(err.Error(), zap.String("method", c.Request.Method))
+		}
+	}
+}
+
+// chartOptions reads the chart options from the query of a render request.
+func chartOptions(c *gin.Context) (chart.Options, error) {
+	now := time.Now()
+	opts := chart.Options{
+		Title:  c.Query("title"),
+		Width:  chart.DefaultWidth,
+		Height: chart.DefaultHeight,
+		From:   now.Add(-time.Hour),
+		To:     now,
+	}
+	var err error
+	if from := c.Query("from"); from != "" {
+		if opts.From, err = parseTime(from, now); err != nil {
+			return opts, err
+		}
+	}
+	if until := c.Query("until"); until != "" {
+		if opts.To, err = parseTime(until, now); err != nil {
+			return opts, err
+		}
+	}
+	if !opts.From.Before(opts.To) {
+		return opts, fmt.Errorf("%w: from is not before until", ErrInvalidParameter)
+	}
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{{"width", &opts.Width}, {"height", &opts.Height}} {
+		if v := c.Query(p.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 16 || n > maxChartSize {
+				return opts, fmt.Errorf("%w: %s", ErrInvalidParameter, p.name)
+			}
+			*p.value = n
+		}
+	}
+	if v := c.Query("sparkline"); v != "" {
+		if opts.Sparkline, err = strconv.ParseBool(v); err != nil {
+			return opts, fmt.Errorf("%w: sparkline", ErrInvalidParameter)
+		}
+	}
+	return opts, nil
+}
+
+// parseTime parses a point in time given as a duration before now, such
+// as -1h or 1h, as unix seconds, or in RFC 3339.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(strings.TrimPrefix(s, "-")); err == nil {
+		return now.Add(-d), nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, ErrInvalidTime
+}
@@ -0,0 +1,92 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	mem := filememory.NewMemStorage(false, nil)
+	st := history.New(mem, time.Hour)
+	require.NoError(t, st.UpdateGauge("Alloc", 1))
+	require.NoError(t, st.UpdateGauge("Alloc", 2))
+	require.NoError(t, st.UpdateCounter("PollCount", 1, true))
+
+	router := gin.New()
+	router.GET("/api/v1/render", NewHandler(st).RenderHandler())
+	router.GET("/memory/render", NewHandler(mem).RenderHandler())
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+		contains string
+	}{
+		{name: "Gauge", path: "/api/v1/render?id=Alloc", expected: http.StatusOK, contains: "<polyline"},
+		{
+			name:     "Counter By Lookup",
+			path:     "/api/v1/render?id=PollCount&from=-15m&title=Polls",
+			expected: http.StatusOK,
+			contains: ">Polls<",
+		},
+		{
+			name:     "Sparkline",
+			path:     "/api/v1/render?id=Alloc&type=gauge&width=120&height=30&sparkline=true",
+			expected: http.StatusOK,
+			contains: `width="120"`,
+		},
+		{name: "Missing ID", path: "/api/v1/render", expected: http.StatusBadRequest},
+		{name: "Unknown Metric", path: "/api/v1/render?id=Nope", expected: http.StatusNotFound},
+		{name: "Invalid Type", path: "/api/v1/render?id=Alloc&type=x", expected: http.StatusBadRequest},
+		{
+			name:     "Invalid From",
+			path:     "/api/v1/render?id=Alloc&from=yesterday",
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "From After Until",
+			path:     "/api/v1/render?id=Alloc&from=-1h&until=-2h",
+			expected: http.StatusBadRequest,
+		},
+		{name: "Invalid Width", path: "/api/v1/render?id=Alloc&width=1", expected: http.StatusBadRequest},
+		{name: "No History", path: "/memory/render?id=Alloc", expected: http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				require.Equal(t, tt.expected, w.Code)
+				if tt.expected == http.StatusOK {
+					assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+					assert.Contains(t, w.Body.String(), tt.contains)
+				}
+			},
+		)
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for s, expected := range map[string]time.Time{
+		"-1h":                  now.Add(-time.Hour),
+		"30m":                  now.Add(-30 * time.Minute),
+		"1699990000":           time.Unix(1699990000, 0),
+		"2023-11-14T22:13:20Z": time.Unix(1700000000, 0),
+	} {
+		parsed, err := parseTime(s, now)
+		require.NoError(t, err)
+		assert.True(t, expected.Equal(parsed), s)
+	}
+	_, err := parseTime("soon", now)
+	assert.ErrorIs(t, err, ErrInvalidTime)
+}
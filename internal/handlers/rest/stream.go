@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/hub"
+	"github.com/gin-gonic/gin"
+)
+
+// streamKeepAlive is the interval of the comments sent on idle streams, so
+// that proxies do not close them.
+const streamKeepAlive = 15 * time.Second
+
+// streamEvent is the data of a "metric" event. For counters Delta is the
+// increment and Value the total after the update.
+type streamEvent struct {
+	ID        string  `json:"id"`
+	MType     string  `json:"type"`
+	Delta     *int64  `json:"delta,omitempty"`
+	Value     float64 `json:"value"`
+	Timestamp int64   `json:"timestamp"`
+}
+
+// StreamHandler creates a gin.HandlerFunc that pushes every stored metric
+// update to the client as a Server-Sent Event named "metric". The type
+// query parameter selects counters or gauges and pattern an RE2 expression
+// the whole metric ID has to match. Updates are dropped while the client
+// cannot keep up. The stream ends when the client disconnects or done is
+// closed, which the server does on shutdown.
+//
+// @Summary      Stream metric updates
+// @Description  Pushes every stored metric update as a Server-Sent Event named "metric".
+// @Tags         query
+// @Produce      text/event-stream
+// @Param        type     query     string  false  "Metric type"  Enums(counter, gauge)
+// @Param        pattern  query     string  false  "RE2 expression the whole metric ID has to match"
+// @Success      200      {object}  streamEvent
+// @Failure      400      {object}  APIErrorResponse
+// @Failure      501      {object}  APIErrorResponse
+// @Router       /api/v1/stream [get]
+func (h *Handler) StreamHandler(done <-chan struct{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		st := h.storage(c)
+		if st == nil {
+			return
+		}
+		updates := hub.Find(st)
+		if updates == nil {
+			abortWithError(c, ErrUpdatesDisabled)
+			return
+		}
+		mType := c.Query("type")
+		if mType != "" && mType != config.Counter && mType != config.Gauge {
+			abortWithError(c, ErrUnsupportedMetric)
+			return
+		}
+		var pattern *regexp.Regexp
+		if p := c.Query("pattern"); p != "" {
+			var err error
+			if pattern, err = regexp.Compile("^(?:" + p + ")$"); err != nil {
+				abortWithError(c, fmt.Errorf("%w pattern: %v", ErrInvalidParameter, err))
+				return
+			}
+		}
+
+		sub := updates.Subscribe(
+			func(u hub.Update) bool {
+				return (mType == "" || u.Type == mType) && (pattern == nil || pattern.MatchString(u.ID))
+			},
+		)
+		defer sub.Close()
+		ticker := time.NewTicker(streamKeepAlive)
+		defer ticker.Stop()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+		c.Stream(
+			func(w io.Writer) bool {
+				select {
+				case <-c.Request.Context().Done():
+					return false
+				case <-done:
+					return false
+				case <-ticker.C:
+					_, err := io.WriteString(w, ": keep-alive\n\n")
+					return err == nil
+				case u := <-sub.C:
+					event := streamEvent{
+						ID:        u.ID,
+						MType:     u.Type,
+						Value:     u.Value,
+						Timestamp: u.Time.UnixMilli(),
+					}
+					if u.Type == config.Counter {
+						event.Delta = &u.Delta
+					}
+					c.SSEvent("metric", event)
+					return true
+				}
+			},
+		)
+	}
+}
@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/hub"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	st := hub.New(filememory.NewMemStorage(false, nil))
+	done := make(chan struct{})
+	router := gin.New()
+	router.GET("/api/v1/stream", NewHandler(st).StreamHandler(done))
+	router.GET("/plain/stream", NewHandler(filememory.NewMemStorage(false, nil)).StreamHandler(done))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected int
+	}{
+		{name: "Invalid Type", path: "/api/v1/stream?type=histogram", expected: http.StatusBadRequest},
+		{name: "Invalid Pattern", path: "/api/v1/stream?pattern=(", expected: http.StatusBadRequest},
+		{name: "No Hub", path: "/plain/stream", expected: http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				resp, err := http.Get(ts.URL + tt.path)
+				require.NoError(t, err)
+				defer resp.Body.Close()
+				assert.Equal(t, tt.expected, resp.StatusCode)
+			},
+		)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodGet, ts.URL+"/api/v1/stream?type=counter", nil,
+	)
+	require.NoError(t, err)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The handler subscribed before sending the headers.
+	require.NoError(t, st.UpdateGauge("Alloc", 1))
+	require.NoError(t, st.UpdateCounter("PollCount", 2, false))
+	require.NoError(t, st.UpdateCounter("PollCount", 3, true))
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 6 {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+	assert.Equal(t, "event:metric", lines[0])
+	assert.Regexp(
+		t, `^data:\{"id":"PollCount","type":"counter","delta":2,"value":2,"timestamp":\d+\}$`, lines[1],
+	)
+	assert.Equal(t, "", lines[2])
+	assert.Regexp(t, `"delta":3,"value":5,`, lines[4])
+
+	// Closing done ends the stream.
+	close(done)
+	_, err = reader.ReadString('\n')
+	assert.Error(t, err)
+}
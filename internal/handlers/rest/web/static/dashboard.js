@@ -0,0 +1,227 @@
+(function () {
+    "use strict";
+
+    function cellValue(row, index, numeric) {
+        var cell = row.cells[index];
+        var raw = cell.dataset.value !== undefined ? cell.dataset.value : cell.textContent.trim();
+        if (!numeric) {
+            return raw.toLowerCase();
+        }
+        var n = parseFloat(raw);
+        return isNaN(n) ? -Infinity : n;
+    }
+
+    // Sort the rows of .sortable tables when a header is clicked.
+    Array.prototype.forEach.call(document.querySelectorAll("table.sortable"), function (table) {
+        var headers = table.tHead.rows[0].cells;
+        Array.prototype.forEach.call(headers, function (th, index) {
+            if (!th.dataset.sort) {
+                return;
+            }
+            th.addEventListener("click", function () {
+                var asc = !th.classList.contains("sorted-asc");
+                Array.prototype.forEach.call(headers, function (h) {
+                    h.classList.remove("sorted-asc", "sorted-desc");
+                });
+                th.classList.add(asc ? "sorted-asc" : "sorted-desc");
+                var numeric = th.dataset.sort === "number";
+                var body = table.tBodies[0];
+                var rows = Array.prototype.filter.call(body.rows, function (r) {
+                    return !r.classList.contains("empty");
+                });
+                rows.sort(function (a, b) {
+                    var x = cellValue(a, index, numeric), y = cellValue(b, index, numeric);
+                    return (x < y ? -1 : x > y ? 1 : 0) * (asc ? 1 : -1);
+                });
+                rows.forEach(function (r) {
+                    body.appendChild(r);
+                });
+            });
+        });
+    });
+
+    var metrics = document.getElementById("metrics");
+    var filter = document.getElementById("filter");
+    var typeFilter = document.getElementById("type-filter");
+    var count = document.getElementById("count");
+
+    // Hide the rows of the metric table that do not match the filters. A
+    // filter in slashes is a regular expression, anything else a substring.
+    function applyFilter() {
+        if (!metrics) {
+            return;
+        }
+        var text = filter.value.trim(), match;
+        filter.classList.remove("invalid");
+        if (text.length > 1 && text[0] === "/" && text[text.length - 1] === "/") {
+            try {
+                var re = new RegExp(text.slice(1, -1));
+                match = function (id) {
+                    return re.test(id);
+                };
+            } catch (e) {
+                filter.classList.add("invalid");
+                return;
+            }
+        } else {
+            text = text.toLowerCase();
+            match = function (id) {
+                return id.toLowerCase().indexOf(text) !== -1;
+            };
+        }
+        var shown = 0, total = 0;
+        Array.prototype.forEach.call(metrics.tBodies[0].rows, function (row) {
+            if (row.classList.contains("empty")) {
+                return;
+            }
+            total++;
+            var visible = (!typeFilter.value || row.dataset.type === typeFilter.value) && match(row.dataset.id);
+            row.hidden = !visible;
+            if (visible) {
+                shown++;
+            }
+        });
+        count.textContent = shown === total ? total + " metrics" : shown + " of " + total + " metrics";
+    }
+
+    if (metrics) {
+        filter.addEventListener("input", applyFilter);
+        typeFilter.addEventListener("change", applyFilter);
+        applyFilter();
+    }
+
+    function findRow(m) {
+        return Array.prototype.find.call(metrics.tBodies[0].rows, function (r) {
+            return r.dataset.type === m.type && r.dataset.id === m.id;
+        });
+    }
+
+    function addRow(m) {
+        var empty = metrics.querySelector("tr.empty");
+        if (empty) {
+            empty.remove();
+        }
+        var row = metrics.tBodies[0].insertRow();
+        row.dataset.type = m.type;
+        row.dataset.id = m.id;
+        var link = document.createElement("a");
+        link.href = "/ui/metric?type=" + encodeURIComponent(m.type) + "&id=" + encodeURIComponent(m.id);
+        link.textContent = m.id;
+        row.insertCell().appendChild(link);
+        row.insertCell().textContent = m.type;
+        row.insertCell().className = "value";
+        row.insertCell();
+        row.insertCell();
+        return row;
+    }
+
+    function setValue(cell, value) {
+        cell.dataset.value = value;
+        cell.textContent = value;
+    }
+
+    var chart = document.getElementById("chart");
+    var samples = [];
+    if (chart) {
+        samples = JSON.parse(document.getElementById("samples").textContent) || [];
+    }
+
+    // Draw the samples on the chart canvas as a line with the value range
+    // on the left and the time range at the bottom.
+    function drawChart() {
+        var ctx = chart.getContext("2d"), w = chart.width, h = chart.height;
+        var left = 72, right = 16, top = 16, bottom = 32;
+        ctx.clearRect(0, 0, w, h);
+        document.getElementById("chart-empty").hidden = samples.length > 0;
+        if (samples.length === 0) {
+            return;
+        }
+        var end = Date.now(), start = end - Number(chart.dataset.range);
+        var min = Infinity, max = -Infinity;
+        samples.forEach(function (s) {
+            min = Math.min(min, s.value);
+            max = Math.max(max, s.value);
+        });
+        if (min === max) {
+            min -= 1;
+            max += 1;
+        }
+        function x(t) {
+            return left + (t - start) / (end - start) * (w - left - right);
+        }
+        function y(v) {
+            return top + (max - v) / (max - min) * (h - top - bottom);
+        }
+
+        ctx.strokeStyle = "#d0d7de";
+        ctx.fillStyle = "#656d76";
+        ctx.font = "12px sans-serif";
+        ctx.beginPath();
+        ctx.moveTo(left, top);
+        ctx.lineTo(left, h - bottom);
+        ctx.lineTo(w - right, h - bottom);
+        ctx.stroke();
+        ctx.textAlign = "right";
+        ctx.fillText(String(+max.toPrecision(6)), left - 6, top + 4);
+        ctx.fillText(String(+min.toPrecision(6)), left - 6, h - bottom);
+        ctx.textAlign = "left";
+        ctx.fillText(new Date(start).toLocaleTimeString(), left, h - bottom + 18);
+        ctx.textAlign = "right";
+        ctx.fillText(new Date(end).toLocaleTimeString(), w - right, h - bottom + 18);
+
+        ctx.strokeStyle = "#0969da";
+        ctx.lineWidth = 2;
+        ctx.beginPath();
+        samples.forEach(function (s, i) {
+            if (i === 0) {
+                ctx.moveTo(x(s.timestamp), y(s.value));
+            } else {
+                ctx.lineTo(x(s.timestamp), y(s.value));
+            }
+        });
+        ctx.stroke();
+        if (samples.length === 1) {
+            ctx.fillStyle = "#0969da";
+            ctx.fillRect(x(samples[0].timestamp) - 2, y(samples[0].value) - 2, 4, 4);
+        }
+    }
+
+    if (chart) {
+        drawChart();
+        setInterval(drawChart, 15000);
+    }
+
+    var detail = document.getElementById("metric");
+    if (!window.EventSource || (!metrics && !detail)) {
+        return;
+    }
+    var url = "/api/v1/stream";
+    if (detail) {
+        url += "?type=" + encodeURIComponent(detail.dataset.type);
+    }
+    new EventSource(url).addEventListener("metric", function (e) {
+        var m = JSON.parse(e.data);
+        if (detail) {
+            if (m.type !== detail.dataset.type || m.id !== detail.dataset.id) {
+                return;
+            }
+            detail.querySelector(".value").textContent = m.value;
+            if (chart) {
+                var from = Date.now() - Number(chart.dataset.range);
+                samples.push({timestamp: m.timestamp, value: m.value});
+                samples = samples.filter(function (s) {
+                    return s.timestamp >= from;
+                });
+                drawChart();
+            }
+            return;
+        }
+        var row = findRow(m) || addRow(m);
+        var cell = row.querySelector("td.value");
+        setValue(cell, m.value);
+        row.classList.remove("updated");
+        void row.offsetWidth;
+        row.classList.add("updated");
+        applyFilter();
+    });
+})();
@@ -0,0 +1,208 @@
+// Package influx parses the InfluxDB line protocol:
+//
+//	measurement[,tag=value...] field=value[,field=value...] [timestamp]
+//
+// See https://docs.influxdata.com/influxdb/v2/reference/syntax/line-protocol/.
+package influx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+)
+
+var (
+	ErrInvalidLine      = errors.New("invalid line protocol")
+	ErrInvalidPrecision = errors.New("invalid precision")
+)
+
+// Kind is the type of a field value.
+type Kind int
+
+const (
+	Float Kind = iota
+	Integer
+	Unsigned
+	Boolean
+	String
+)
+
+// Field is a single field of a point. Value holds the numeric value of
+// float, integer, unsigned and boolean fields; Int and Uint the exact value
+// of integer and unsigned fields; Text the value of strings.
+type Field struct {
+	Key   string
+	Kind  Kind
+	Value float64
+	Int   int64
+	Uint  uint64
+	Text  string
+}
+
+// Point is a parsed line.
+type Point struct {
+	Measurement string
+	Tags        []f.Label
+	Fields      []Field
+	Time        time.Time
+}
+
+// Precision returns the duration of one timestamp unit for the precision
+// query parameter of a write request. An empty precision means nanoseconds.
+func Precision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "ns":
+		return time.Nanosecond, nil
+	case "us":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	default:
+		return 0, fmt.Errorf("%w: %q", ErrInvalidPrecision, precision)
+	}
+}
+
+// Parse parses all lines of data. Empty lines and comments are skipped;
+// points without a timestamp get now. The error names the first invalid line.
+func Parse(data []byte, unit time.Duration, now time.Time) ([]Point, error) {
+	var points []Point
+	for i, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		p, err := parseLine(string(line), unit, now)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+func parseLine(line string, unit time.Duration, now time.Time) (Point, error) {
+	var p Point
+	key, rest := cut(line, ' ', false)
+	fields, timestamp := cut(rest, ' ', true)
+	if key == "" || fields == "" {
+		return p, fmt.Errorf("%w: missing fields", ErrInvalidLine)
+	}
+
+	parts := split(key, ',', false)
+	p.Measurement = unescape(parts[0])
+	if p.Measurement == "" {
+		return p, fmt.Errorf("%w: missing measurement", ErrInvalidLine)
+	}
+	for _, tag := range parts[1:] {
+		k, v := cut(tag, '=', false)
+		if k == "" || v == "" {
+			return p, fmt.Errorf("%w: tag %q", ErrInvalidLine, tag)
+		}
+		p.Tags = append(p.Tags, f.Label{Name: unescape(k), Value: unescape(v)})
+	}
+
+	for _, field := range split(fields, ',', true) {
+		k, v := cut(field, '=', false)
+		if k == "" || v == "" {
+			return p, fmt.Errorf("%w: field %q", ErrInvalidLine, field)
+		}
+		parsed, err := parseValue(v)
+		if err != nil {
+			return p, fmt.Errorf("%w: field %q: %v", ErrInvalidLine, unescape(k), err)
+		}
+		parsed.Key = unescape(k)
+		p.Fields = append(p.Fields, parsed)
+	}
+
+	p.Time = now
+	if timestamp = strings.TrimSpace(timestamp); timestamp != "" {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("%w: timestamp %q", ErrInvalidLine, timestamp)
+		}
+		p.Time = time.Unix(0, ts*int64(unit))
+	}
+	return p, nil
+}
+
+func parseValue(v string) (Field, error) {
+	switch {
+	case v[0] == '"':
+		if len(v) < 2 || v[len(v)-1] != '"' {
+			return Field{}, errors.New("unterminated string")
+		}
+		text := strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(v[1 : len(v)-1])
+		return Field{Kind: String, Text: text}, nil
+	case strings.HasSuffix(v, "i"):
+		n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+		return Field{Kind: Integer, Value: float64(n), Int: n}, err
+	case strings.HasSuffix(v, "u"):
+		n, err := strconv.ParseUint(v[:len(v)-1], 10, 64)
+		return Field{Kind: Unsigned, Value: float64(n), Uint: n}, err
+	}
+	switch v {
+	case "t", "T", "true", "True", "TRUE":
+		return Field{Kind: Boolean, Value: 1}, nil
+	case "f", "F", "false", "False", "FALSE":
+		return Field{Kind: Boolean, Value: 0}, nil
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err == nil && (math.IsNaN(n) || math.IsInf(n, 0)) {
+		err = errors.New("NaN and infinity are not supported")
+	}
+	return Field{Kind: Float, Value: n}, err
+}
+
+// cut splits s at the first unescaped sep. With quotes set, separators in
+// double-quoted strings are skipped; quotes only delimit field values.
+func cut(s string, sep byte, quotes bool) (string, string) {
+	if i := index(s, sep, quotes); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// split splits s at every unescaped sep, see cut.
+func split(s string, sep byte, quotes bool) []string {
+	var parts []string
+	for {
+		i := index(s, sep, quotes)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+1:]
+	}
+}
+
+func index(s string, sep byte, quotes bool) int {
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = quotes && !quoted
+		case sep:
+			if !quoted {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ", `\\`, `\`).Replace(s)
+}
@@ -0,0 +1,122 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	now := time.Unix(100, 0)
+	tests := []struct {
+		name     string
+		data     string
+		unit     time.Duration
+		expected []Point
+	}{
+		{
+			name: "Tags And Timestamp",
+			data: "cpu,host=a,region=eu usage=0.5 1000000000",
+			unit: time.Nanosecond,
+			expected: []Point{
+				{
+					Measurement: "cpu",
+					Tags:        []f.Label{{Name: "host", Value: "a"}, {Name: "region", Value: "eu"}},
+					Fields:      []Field{{Key: "usage", Kind: Float, Value: 0.5}},
+					Time:        time.Unix(1, 0),
+				},
+			},
+		},
+		{
+			name: "Field Kinds",
+			data: `mem used=10i,free=3u,ok=t,note="a, b c=\"d\"",ratio=1e2`,
+			unit: time.Nanosecond,
+			expected: []Point{
+				{
+					Measurement: "mem",
+					Fields: []Field{
+						{Key: "used", Kind: Integer, Value: 10, Int: 10},
+						{Key: "free", Kind: Unsigned, Value: 3, Uint: 3},
+						{Key: "ok", Kind: Boolean, Value: 1},
+						{Key: "note", Kind: String, Text: `a, b c="d"`},
+						{Key: "ratio", Kind: Float, Value: 100},
+					},
+					Time: now,
+				},
+			},
+		},
+		{
+			name: "Escapes",
+			data: `disk\ io,dev=sd\,a r\=w=1 5`,
+			unit: time.Second,
+			expected: []Point{
+				{
+					Measurement: "disk io",
+					Tags:        []f.Label{{Name: "dev", Value: "sd,a"}},
+					Fields:      []Field{{Key: "r=w", Kind: Float, Value: 1}},
+					Time:        time.Unix(5, 0),
+				},
+			},
+		},
+		{
+			name: "Comments And Empty Lines",
+			data: "# comment\n\nup value=1\n",
+			unit: time.Nanosecond,
+			expected: []Point{
+				{Measurement: "up", Fields: []Field{{Key: "value", Kind: Float, Value: 1}}, Time: now},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				points, err := Parse([]byte(tt.data), tt.unit, now)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expected, points)
+			},
+		)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "No Fields", data: "cpu"},
+		{name: "Empty Tag", data: "cpu,host= usage=1"},
+		{name: "Bad Field", data: "cpu usage"},
+		{name: "Bad Integer", data: "cpu usage=1.5i"},
+		{name: "Unterminated String", data: `cpu note="abc`},
+		{name: "NaN", data: "cpu usage=NaN"},
+		{name: "Bad Timestamp", data: "cpu usage=1 soon"},
+		{name: "Second Line", data: "cpu usage=1\n,host=a usage=1"},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				_, err := Parse([]byte(tt.data), time.Nanosecond, time.Now())
+				assert.ErrorIs(t, err, ErrInvalidLine)
+			},
+		)
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	for precision, expected := range map[string]time.Duration{
+		"":   time.Nanosecond,
+		"ns": time.Nanosecond,
+		"us": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+	} {
+		unit, err := Precision(precision)
+		require.NoError(t, err)
+		assert.Equal(t, expected, unit)
+	}
+	_, err := Precision("h")
+	assert.ErrorIs(t, err, ErrInvalidPrecision)
+}
@@ -0,0 +1,290 @@
+// Package metadata keeps descriptive information about metric names:
+// their unit, help text and declared type. The declared type is locked,
+// so a name registered as a gauge can not be updated as a counter.
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/goccy/go-json"
+)
+
+var (
+	ErrTypeMismatch    = storage.Rejection("metric is registered with another type")
+	ErrUnsupportedType = storage.Rejection("unsupported metric type")
+	ErrEmptyName       = storage.Rejection("metric name is empty")
+)
+
+// Metadata describes a single metric name.
+type Metadata struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Unit        string `json:"unit,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// saveDelay is how long the types declared by Lock wait to be saved, so
+// that a burst of new metric names rewrites the file once.
+const saveDelay = time.Second
+
+// Registry stores Metadata by metric name and optionally persists it to a file.
+type Registry struct {
+	mu      sync.RWMutex
+	items   map[string]Metadata
+	path    string
+	pending *time.Timer
+	// claims counts the writes in flight that declared the type of a name
+	// through Claim and have not settled it yet.
+	claims map[string]int
+}
+
+// NewRegistry creates a Registry. When path is not empty the registry is
+// loaded from it and saved back on every change; types declared by Lock
+// are saved at most once per saveDelay.
+func NewRegistry(path string) *Registry {
+	r := &Registry{
+		items:  make(map[string]Metadata),
+		path:   path,
+		claims: make(map[string]int),
+	}
+	if path != "" {
+		r.load()
+	}
+	return r
+}
+
+// Get returns the metadata registered for name.
+func (r *Registry) Get(name string) (Metadata, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	m, ok := r.items[name]
+	return m, ok
+}
+
+// List returns all registered metadata sorted by name.
+func (r *Registry) List() []Metadata {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	list := make([]Metadata, 0, len(r.items))
+	for _, m := range r.items {
+		list = append(list, m)
+	}
+	sort.Slice(
+		list, func(i, j int) bool {
+			return list[i].Name < list[j].Name
+		},
+	)
+	return list
+}
+
+// Set registers or updates the metadata of m.Name. Empty fields of m keep
+// their previous values. Changing an already declared type is rejected.
+func (r *Registry) Set(m Metadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.set(m); err != nil {
+		return err
+	}
+	if m.Type != "" {
+		delete(r.claims, m.Name)
+	}
+	r.save()
+	return nil
+}
+
+// Check returns ErrTypeMismatch if name is declared with a type other than
+// metricType, without declaring it.
+func (r *Registry) Check(name string, metricType string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if current := r.items[name]; current.Type != "" && current.Type != metricType {
+		return fmt.Errorf("%w: %s is a %s", ErrTypeMismatch, name, current.Type)
+	}
+	return nil
+}
+
+// Lock declares metricType for name if it has no type yet, and returns
+// ErrTypeMismatch if name is already declared with a different type.
+// New declarations are saved after saveDelay, see Flush.
+func (r *Registry) Lock(name string, metricType string) error {
+	r.mu.RLock()
+	current, ok := r.items[name]
+	r.mu.RUnlock()
+	if ok && current.Type == metricType {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.set(Metadata{Name: name, Type: metricType}); err != nil {
+		return err
+	}
+	r.saveLater()
+	return nil
+}
+
+// Claim declares metricType for name ahead of a write of the metric, so
+// that no write of another type can pass Check until it is settled. It
+// returns ErrTypeMismatch if name is declared with a different type, and
+// whether the declaration is new and must be settled with Settle.
+func (r *Registry) Claim(name string, metricType string) (bool, error) {
+	r.mu.RLock()
+	current := r.items[name]
+	claimed := r.claims[name] > 0
+	r.mu.RUnlock()
+	if current.Type == metricType && !claimed {
+		return false, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.items[name].Type == metricType && r.claims[name] == 0 {
+		return false, nil
+	}
+	if err := r.set(Metadata{Name: name, Type: metricType}); err != nil {
+		return false, err
+	}
+	r.claims[name]++
+	return true, nil
+}
+
+// Settle ends a write that declared the type of name through Claim. The
+// declaration is kept once a write succeeds and withdrawn when every write
+// that claimed it failed.
+func (r *Registry) Settle(name string, written bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.claims[name] == 0 {
+		return
+	}
+	if written {
+		delete(r.claims, name)
+		r.saveLater()
+		return
+	}
+	if r.claims[name]--; r.claims[name] > 0 {
+		return
+	}
+	delete(r.claims, name)
+	current := r.items[name]
+	current.Type = ""
+	if current.Unit == "" && current.Description == "" {
+		delete(r.items, name)
+	} else {
+		r.items[name] = current
+	}
+}
+
+// Flush saves the declarations of Lock that are still waiting for
+// saveDelay right away.
+func (r *Registry) Flush() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending != nil {
+		r.save()
+	}
+}
+
+// lockAll declares the types of all names that have no type yet, ignoring
+// conflicts, and saves the registry once.
+func (r *Registry) lockAll(types map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, metricType := range types {
+		_ = r.set(Metadata{Name: name, Type: metricType})
+	}
+	r.save()
+}
+
+// set merges m into the registry. The caller must hold r.mu.
+func (r *Registry) set(m Metadata) error {
+	if m.Name == "" {
+		return ErrEmptyName
+	}
+	if m.Type != "" && m.Type != config.Gauge && m.Type != config.Counter {
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, m.Type)
+	}
+	current := r.items[m.Name]
+	if current.Type != "" && m.Type != "" && current.Type != m.Type {
+		return fmt.Errorf("%w: %s is a %s", ErrTypeMismatch, m.Name, current.Type)
+	}
+	current.Name = m.Name
+	if m.Type != "" {
+		current.Type = m.Type
+	}
+	if m.Unit != "" {
+		current.Unit = m.Unit
+	}
+	if m.Description != "" {
+		current.Description = m.Description
+	}
+	r.items[m.Name] = current
+	return nil
+}
+
+// load reads the registry from its file.
+func (r *Registry) load() {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			logger.Log.Error(fmt.Sprintf("failed to read metadata: %v", err))
+		}
+		return
+	}
+	var list []Metadata
+	if err := json.Unmarshal(data, &list); err != nil {
+		logger.Log.Error(fmt.Sprintf("failed to unmarshal metadata: %v", err))
+		return
+	}
+	for _, m := range list {
+		r.items[m.Name] = m
+	}
+}
+
+// saveLater saves the registry after saveDelay unless a save is already
+// pending. The caller must hold r.mu.
+func (r *Registry) saveLater() {
+	if r.path == "" || r.pending != nil {
+		return
+	}
+	r.pending = time.AfterFunc(saveDelay, r.Flush)
+}
+
+// save writes the registry to its file and cancels a pending save. The
+// caller must hold r.mu.
+func (r *Registry) save() {
+	if r.path == "" {
+		return
+	}
+	if r.pending != nil {
+		r.pending.Stop()
+		r.pending = nil
+	}
+	list := make([]Metadata, 0, len(r.items))
+	for name, m := range r.items {
+		if r.claims[name] > 0 {
+			// The type is claimed by writes that have not been stored yet.
+			m.Type = ""
+		}
+		list = append(list, m)
+	}
+	data, err := json.MarshalIndent(list, "", "   ")
+	if err != nil {
+		logger.Log.Error(fmt.Sprintf("failed to marshal metadata: %v", err))
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0777); err != nil {
+		logger.Log.Error(fmt.Sprintf("failed to create metadata directory: %v", err))
+		return
+	}
+	if err := os.WriteFile(r.path, data, 0666); err != nil {
+		logger.Log.Error(fmt.Sprintf("failed to write metadata: %v", err))
+	}
+}
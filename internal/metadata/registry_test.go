@@ -0,0 +1,156 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistrySet(t *testing.T) {
+	r := NewRegistry("")
+
+	assert.NoError(t, r.Set(Metadata{Name: "Alloc", Type: config.Gauge, Unit: "bytes"}))
+	assert.NoError(t, r.Set(Metadata{Name: "Alloc", Description: "allocated heap"}))
+
+	m, ok := r.Get("Alloc")
+	assert.True(t, ok)
+	assert.Equal(t, Metadata{
+		Name:        "Alloc",
+		Type:        config.Gauge,
+		Unit:        "bytes",
+		Description: "allocated heap",
+	}, m)
+
+	assert.ErrorIs(t, r.Set(Metadata{Name: "Alloc", Type: config.Counter}), ErrTypeMismatch)
+	assert.ErrorIs(t, r.Set(Metadata{Name: "Alloc", Type: "histogram"}), ErrUnsupportedType)
+	assert.ErrorIs(t, r.Set(Metadata{Type: config.Gauge}), ErrEmptyName)
+}
+
+func TestRegistryPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.json")
+
+	r := NewRegistry(path)
+	assert.NoError(t, r.Set(Metadata{Name: "PollCount", Type: config.Counter, Unit: "polls"}))
+
+	loaded := NewRegistry(path)
+	assert.Equal(t, r.List(), loaded.List())
+}
+
+func TestRegistryLockSavesLater(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.json")
+
+	r := NewRegistry(path)
+	assert.NoError(t, r.Lock("Alloc", config.Gauge))
+	assert.NoError(t, r.Lock("PollCount", config.Counter))
+	assert.Empty(t, NewRegistry(path).List())
+
+	r.Flush()
+	assert.Equal(t, r.List(), NewRegistry(path).List())
+	assert.ErrorIs(t, r.Check("Alloc", config.Counter), ErrTypeMismatch)
+	assert.NoError(t, r.Check("Alloc", config.Gauge))
+	assert.NoError(t, r.Check("Sys", config.Counter))
+}
+
+// failingStorage fails every update of a gauge.
+type failingStorage struct {
+	*filememory.MemStorage
+}
+
+func (s failingStorage) UpdateGauge(string, float64) error {
+	return errors.New("storage is down")
+}
+
+func TestStorageLocksAfterWrite(t *testing.T) {
+	s := NewStorage(failingStorage{filememory.NewMemStorage(false, nil)}, NewRegistry(""))
+
+	assert.Error(t, s.UpdateGauge("Alloc", 1))
+	_, ok := s.Registry().Get("Alloc")
+	assert.False(t, ok)
+	assert.NoError(t, s.UpdateCounter("Alloc", 1, false))
+	m, _ := s.Registry().Get("Alloc")
+	assert.Equal(t, config.Counter, m.Type)
+}
+
+// slowStorage widens the window between the type check and the write.
+type slowStorage struct {
+	*filememory.MemStorage
+}
+
+func (s slowStorage) UpdateGauge(name string, value float64) error {
+	time.Sleep(time.Millisecond)
+	return s.MemStorage.UpdateGauge(name, value)
+}
+
+func (s slowStorage) UpdateCounter(name string, value int64, ok bool) error {
+	time.Sleep(time.Millisecond)
+	return s.MemStorage.UpdateCounter(name, value, ok)
+}
+
+func TestStorageConcurrentTypes(t *testing.T) {
+	mem := filememory.NewMemStorage(false, nil)
+	s := NewStorage(slowStorage{mem}, NewRegistry(""))
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("metric%d", i)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			_ = s.UpdateGauge(name, 1)
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			_ = s.UpdateCounter(name, 1, false)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	counters, gauges := mem.GetMetrics()
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("metric%d", i)
+		_, counter := counters[name]
+		_, gauge := gauges[name]
+		assert.True(t, counter != gauge, name)
+		m, _ := s.Registry().Get(name)
+		if counter {
+			assert.Equal(t, config.Counter, m.Type, name)
+		} else {
+			assert.Equal(t, config.Gauge, m.Type, name)
+		}
+	}
+}
+
+func TestStorageLocksTypes(t *testing.T) {
+	mem := filememory.NewMemStorage(false, nil)
+	mem.Gauge["Alloc"] = 1
+	s := NewStorage(mem, NewRegistry(""))
+
+	assert.ErrorIs(t, s.UpdateCounter("Alloc", 1, false), ErrTypeMismatch)
+	assert.NoError(t, s.UpdateGauge("Alloc", 2))
+	assert.NoError(t, s.UpdateCounter("PollCount", 1, false))
+	assert.ErrorIs(t, s.UpdateGauge("PollCount", 1), ErrTypeMismatch)
+
+	value := 1.0
+	err := s.InsertBatchMetrics([]f.Metric{{ID: "PollCount", MType: config.Gauge, Value: &value}})
+	assert.ErrorIs(t, err, ErrTypeMismatch)
+}
+
+func TestFrom(t *testing.T) {
+	mem := filememory.NewMemStorage(false, nil)
+	r := NewRegistry("")
+
+	assert.Nil(t, From(mem))
+	assert.Same(t, r, From(NewStorage(mem, r)))
+}
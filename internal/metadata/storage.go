@@ -0,0 +1,123 @@
+package metadata
+
+import (
+	"sync"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+)
+
+// Storage wraps a MetricsStorage and rejects updates that do not match
+// the type declared in its Registry.
+type Storage struct {
+	serviceInterface.MetricsStorage
+	registry *Registry
+	seed     sync.Once
+}
+
+// NewStorage wraps st with type locking backed by registry.
+func NewStorage(st serviceInterface.MetricsStorage, registry *Registry) *Storage {
+	return &Storage{MetricsStorage: st, registry: registry}
+}
+
+// Registry returns the metadata registry of the storage.
+func (s *Storage) Registry() *Registry {
+	return s.registry
+}
+
+// Unwrap returns the wrapped storage.
+func (s *Storage) Unwrap() serviceInterface.MetricsStorage {
+	return s.MetricsStorage
+}
+
+// UpdateCounter updates a counter unless the name is declared as another
+// type, and declares it as a counter once the update is stored.
+func (s *Storage) UpdateCounter(name string, value int64, ok bool) error {
+	return s.write(
+		[]f.Metric{{ID: name, MType: config.Counter}}, func() error {
+			return s.MetricsStorage.UpdateCounter(name, value, ok)
+		},
+	)
+}
+
+// UpdateGauge updates a gauge unless the name is declared as another type,
+// and declares it as a gauge once the update is stored.
+func (s *Storage) UpdateGauge(name string, value float64) error {
+	return s.write(
+		[]f.Metric{{ID: name, MType: config.Gauge}}, func() error {
+			return s.MetricsStorage.UpdateGauge(name, value)
+		},
+	)
+}
+
+// InsertBatchMetrics inserts the batch unless one of its names is declared
+// as another type, and declares the types of its names once it is stored.
+func (s *Storage) InsertBatchMetrics(metrics []f.Metric) error {
+	return s.write(
+		metrics, func() error {
+			return s.MetricsStorage.InsertBatchMetrics(metrics)
+		},
+	)
+}
+
+// write claims the types of metrics in the registry, runs fn and settles
+// the claims with its outcome, so that concurrent writes of a name with
+// different types can not both pass the check. The types of all metrics
+// that already exist in the wrapped storage are declared first.
+func (s *Storage) write(metrics []f.Metric, fn func() error) error {
+	s.seed.Do(
+		func() {
+			counter, gauge := s.MetricsStorage.GetMetrics()
+			types := make(map[string]string, len(counter)+len(gauge))
+			for n := range gauge {
+				types[n] = config.Gauge
+			}
+			for n := range counter {
+				types[n] = config.Counter
+			}
+			s.registry.lockAll(types)
+		},
+	)
+
+	claimed := make([]string, 0, len(metrics))
+	settle := func(written bool) {
+		for _, name := range claimed {
+			s.registry.Settle(name, written)
+		}
+	}
+	for _, m := range metrics {
+		fresh, err := s.registry.Claim(m.ID, m.MType)
+		if err != nil {
+			settle(false)
+			return err
+		}
+		if fresh {
+			claimed = append(claimed, m.ID)
+		}
+	}
+	err := fn()
+	settle(err == nil)
+	return err
+}
+
+// unwrapper is implemented by storage decorators.
+type unwrapper interface {
+	Unwrap() serviceInterface.MetricsStorage
+}
+
+// From returns the Registry of the first metadata Storage found in the
+// decorator chain of st, or nil if there is none.
+func From(st serviceInterface.MetricsStorage) *Registry {
+	for st != nil {
+		if s, ok := st.(*Storage); ok {
+			return s.registry
+		}
+		u, ok := st.(unwrapper)
+		if !ok {
+			return nil
+		}
+		st = u.Unwrap()
+	}
+	return nil
+}
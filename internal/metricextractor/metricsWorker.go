@@ -25,6 +25,8 @@ func ExtractMetricsWorker(
 		err := ExtractMetrics(storage)
 		if err != nil {
 			logger.Log.Error(err.Error(), zap.String("method", "ExtractMetrics"))
+		} else {
+			worker.Notify()
 		}
 		time.Sleep(time.Duration(worker.Config.PollInterval) * time.Second)
 	}
@@ -47,6 +49,8 @@ func ExtractOSMetricsWorker(
 		err := ExtractOSMetrics(storage)
 		if err != nil {
 			logger.Log.Error(err.Error(), zap.String("method", "ExtractOSMetrics"))
+		} else {
+			worker.Notify()
 		}
 		time.Sleep(time.Duration(worker.Config.PollInterval) * time.Second)
 	}
@@ -2,22 +2,52 @@ package grpc
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
 	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
 	"github.com/elina-chertova/metrics-alerting.git/internal/config"
 	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
 	"go.uber.org/zap"
-	"time"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+// ackTimeout is how long the sender waits for a batch to be acknowledged.
+const ackTimeout = 5 * time.Second
+
+// SenderGRPC sends the collected metrics over one long-lived StreamMetrics
+// stream, opened on the first send and reopened after an error. Servers
+// without StreamMetrics are sent to with unary UpdateBatchMetrics calls.
+//
+// The agent sends after every collection, see metricsender.PushMetricsWorker.
+// Counters are sent as the increments since the last successful send, so
+// the server, which adds every delta it receives, is not inflated by the
+// more frequent sends.
 type SenderGRPC struct {
 	Client pb.MetricsServiceClient
 	Config *config.Agent
+
+	mu     sync.Mutex
+	stream pb.MetricsService_StreamMetricsClient
+	cancel context.CancelFunc
+	seq    uint64
+	unary  bool
+	// sent holds the counter totals of the last successful send.
+	sent map[string]int64
 }
 
 func (s *SenderGRPC) SendMetrics(storage *filememory.MemStorage) error {
-	var metrics []*pb.Metric
+	counters, gauges := storage.GetMetrics()
 
-	for metricName, metricValue := range storage.Gauge {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var metrics []*pb.Metric
+	for metricName, metricValue := range gauges {
 		metric := &pb.Metric{
 			Id:    metricName,
 			Type:  pb.MetricType_GAUGE,
@@ -26,15 +56,63 @@ func (s *SenderGRPC) SendMetrics(storage *filememory.MemStorage) error {
 		metrics = append(metrics, metric)
 	}
 
-	for metricName, metricValue := range storage.Counter {
+	for metricName, metricValue := range counters {
+		delta := metricValue - s.sent[metricName]
+		if delta == 0 {
+			continue
+		}
 		metric := &pb.Metric{
 			Id:    metricName,
 			Type:  pb.MetricType_COUNTER,
-			Delta: metricValue,
+			Delta: delta,
 		}
 		metrics = append(metrics, metric)
 	}
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	if err := s.send(metrics); err != nil {
+		return err
+	}
+	s.sent = counters
+	return nil
+}
+
+func (s *SenderGRPC) send(metrics []*pb.Metric) error {
+	if s.unary {
+		return s.sendUnary(metrics)
+	}
+	err := s.sendStream(metrics)
+	if status.Code(err) == codes.Unimplemented {
+		s.unary = true
+		return s.sendUnary(metrics)
+	}
+	if err != nil {
+		zap.L().Error("could not stream metrics", zap.Error(err))
+		return err
+	}
+	return nil
+}
 
+// Close ends the stream, waiting for the server to acknowledge it.
+func (s *SenderGRPC) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stream == nil {
+		return nil
+	}
+	defer s.reset()
+	if err := s.stream.CloseSend(); err != nil {
+		return err
+	}
+	if _, err := s.stream.Recv(); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+func (s *SenderGRPC) sendUnary(metrics []*pb.Metric) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -45,3 +123,46 @@ func (s *SenderGRPC) SendMetrics(storage *filememory.MemStorage) error {
 	}
 	return nil
 }
+
+func (s *SenderGRPC) sendStream(metrics []*pb.Metric) error {
+	if s.stream == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream, err := s.Client.StreamMetrics(ctx)
+		if err != nil {
+			cancel()
+			return err
+		}
+		s.stream, s.cancel = stream, cancel
+	}
+
+	s.seq++
+	// A stream that does not acknowledge in time is cancelled, which
+	// unblocks Recv and makes the next send open a new stream.
+	timer := time.AfterFunc(ackTimeout, s.cancel)
+	defer timer.Stop()
+
+	err := s.stream.Send(&pb.MetricsBatch{Seq: s.seq, Metrics: metrics})
+	if err != nil && !errors.Is(err, io.EOF) {
+		s.reset()
+		return err
+	}
+	// On io.EOF the server has ended the stream and Recv returns the reason.
+	ack, err := s.stream.Recv()
+	if err != nil {
+		s.reset()
+		return err
+	}
+	if ack.Seq != s.seq {
+		s.reset()
+		return fmt.Errorf("acknowledgement for batch %d, expected %d", ack.Seq, s.seq)
+	}
+	if ack.Status != "Success" {
+		return fmt.Errorf("batch %d rejected: %s", ack.Seq, ack.Error)
+	}
+	return nil
+}
+
+func (s *SenderGRPC) reset() {
+	s.cancel()
+	s.stream, s.cancel = nil, nil
+}
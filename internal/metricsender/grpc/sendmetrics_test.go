@@ -0,0 +1,134 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	pb "github.com/elina-chertova/metrics-alerting.git/api/proto"
+	handler "github.com/elina-chertova/metrics-alerting.git/internal/handlers/grpc"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// unaryServer only implements UpdateBatchMetrics, like servers released
+// before StreamMetrics.
+type unaryServer struct {
+	pb.UnimplementedMetricsServiceServer
+	server *handler.Server
+}
+
+func (s unaryServer) UpdateBatchMetrics(
+	ctx context.Context,
+	req *pb.UpdateBatchMetricsRequest,
+) (*pb.UpdateBatchMetricsResponse, error) {
+	return s.server.UpdateBatchMetrics(ctx, req)
+}
+
+func dial(t *testing.T, register func(*grpc.Server)) pb.MetricsServiceClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	register(srv)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(
+			func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			},
+		),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewMetricsServiceClient(conn)
+}
+
+func TestSenderGRPC(t *testing.T) {
+	tests := []struct {
+		name     string
+		register func(*grpc.Server, *handler.Server)
+	}{
+		{
+			name: "Stream",
+			register: func(srv *grpc.Server, h *handler.Server) {
+				pb.RegisterMetricsServiceServer(srv, h)
+			},
+		},
+		{
+			name: "Unary Fallback",
+			register: func(srv *grpc.Server, h *handler.Server) {
+				pb.RegisterMetricsServiceServer(srv, unaryServer{server: h})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				st := filememory.NewMemStorage(false, nil)
+				h := &handler.Server{Handler: handler.NewHandler(st)}
+				client := dial(t, func(srv *grpc.Server) { tt.register(srv, h) })
+				sender := &SenderGRPC{Client: client}
+
+				agent := filememory.NewMemStorage(false, nil)
+				require.NoError(t, agent.UpdateGauge("Alloc", 1.5))
+				for i := 0; i < 3; i++ {
+					require.NoError(t, agent.UpdateCounter("PollCount", 2, false))
+					require.NoError(t, sender.SendMetrics(agent))
+				}
+				// Nothing was collected since the last send.
+				require.NoError(t, sender.SendMetrics(agent))
+				require.NoError(t, sender.Close())
+
+				counters, gauges := st.GetMetrics()
+				assert.Equal(t, map[string]int64{"PollCount": 6}, counters)
+				assert.Equal(t, map[string]float64{"Alloc": 1.5}, gauges)
+			},
+		)
+	}
+}
+
+// failingServer fails UpdateBatchMetrics while fail is set.
+type failingServer struct {
+	unaryServer
+	fail bool
+}
+
+func (s *failingServer) UpdateBatchMetrics(
+	ctx context.Context,
+	req *pb.UpdateBatchMetricsRequest,
+) (*pb.UpdateBatchMetricsResponse, error) {
+	if s.fail {
+		return nil, status.Error(codes.Unavailable, "storage is down")
+	}
+	return s.unaryServer.UpdateBatchMetrics(ctx, req)
+}
+
+func TestSenderGRPCCarriesUnsentIncrements(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	h := &handler.Server{Handler: handler.NewHandler(st)}
+	srv := &failingServer{unaryServer: unaryServer{server: h}}
+	client := dial(t, func(s *grpc.Server) { pb.RegisterMetricsServiceServer(s, srv) })
+	sender := &SenderGRPC{Client: client}
+
+	agent := filememory.NewMemStorage(false, nil)
+	require.NoError(t, agent.UpdateCounter("PollCount", 2, false))
+	srv.fail = true
+	require.Error(t, sender.SendMetrics(agent))
+
+	require.NoError(t, agent.UpdateCounter("PollCount", 3, false))
+	srv.fail = false
+	require.NoError(t, sender.SendMetrics(agent))
+	require.NoError(t, sender.SendMetrics(agent))
+
+	counters, _ := st.GetMetrics()
+	assert.Equal(t, map[string]int64{"PollCount": 5}, counters)
+}
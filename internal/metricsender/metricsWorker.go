@@ -13,7 +13,22 @@ import (
 type Worker struct {
 	Settings *config.Settings
 	Config   *config.Agent
-	once     sync.Once
+	// Collected, when set, receives a value after every collection of
+	// metrics, see PushMetricsWorker.
+	Collected chan struct{}
+	once      sync.Once
+}
+
+// Notify reports a collection of metrics on Collected. Collections made
+// while the previous one is still waiting to be sent are sent together.
+func (w *Worker) Notify() {
+	if w.Collected == nil {
+		return
+	}
+	select {
+	case w.Collected <- struct{}{}:
+	default:
+	}
 }
 
 type MetricsSender interface {
@@ -68,3 +83,25 @@ func SendMetricsWorker(
 		}
 	}()
 }
+
+// PushMetricsWorker sends the metrics every time they are collected, as
+// reported by Worker.Notify, until stopChan is closed.
+func PushMetricsWorker(
+	storage *filememory.MemStorage,
+	worker *Worker,
+	sender MetricsSender,
+	stopChan <-chan struct{},
+	wg *sync.WaitGroup,
+) {
+	defer wg.Done()
+	for {
+		select {
+		case <-stopChan:
+			return
+		case <-worker.Collected:
+		}
+		if err := sender.SendMetrics(storage); err != nil {
+			logger.Log.Error(err.Error(), zap.String("method", "MetricsToServer"))
+		}
+	}
+}
@@ -16,7 +16,8 @@ import (
 
 // MetricsToServer sends metrics stored in memory to a specified server.
 // It supports sending metrics in batch or individually, with optional compression,
-// and handles different content types (JSON, TextPlain).
+// and handles different content types (JSON, TextPlain). Batches are encoded as
+// JSON, protobuf or MessagePack depending on contentType.
 //
 // Parameters:
 // - s: The in-memory storage containing the metrics to be sent.
@@ -39,7 +40,7 @@ func MetricsToServer(
 	ip net.IP,
 ) error {
 	if isSendBatch {
-		return metricsToServerBatch(s, url, isCompress, secretKey, cryptoKey, ip)
+		return metricsToServerBatch(s, contentType, url, isCompress, secretKey, cryptoKey, ip)
 	}
 
 	switch contentType {
@@ -104,18 +105,21 @@ func BackoffSendRequest(
 }
 
 // metricsToServerBatch sends a batch of all collected metrics to the specified server URL.
-// It converts the metrics stored in memory into a JSON format and sends them in a single request.
+// It encodes the metrics stored in memory in the format of contentType, see
+// formatter.EncodeBatch, and sends them in a single request.
 //
 // Parameters:
 // - s: An instance of in-memory storage containing the metrics to be sent.
+// - contentType: The MIME type the batch is encoded in.
 // - url: The server URL to which the metrics are to be sent.
 // - isCompress: Indicates whether the data should be compressed before sending.
 // - secretKey: A secret key used for secure communication (optional).
 //
 // Returns:
-// - An error if there is an issue in creating JSON or sending the request.
+// - An error if there is an issue in encoding the batch or sending the request.
 func metricsToServerBatch(
 	s *filememory.MemStorage,
+	contentType string,
 	url string,
 	isCompress bool,
 	secretKey string,
@@ -134,13 +138,13 @@ func metricsToServerBatch(
 		metric, _ = formJSON(metricName, metricValue, config.Counter)
 		metrics = append(metrics, metric)
 	}
-	out, err := json.Marshal(metrics)
+	out, err := formatter.EncodeBatch(metrics, contentType)
 	if err != nil {
-		return fmt.Errorf("error creating JSON: %v", err)
+		return fmt.Errorf("error encoding batch: %v", err)
 	}
 
 	err = BackoffSendRequest(
-		formatter.ContentTypeJSON,
+		contentType,
 		url,
 		isCompress,
 		out,
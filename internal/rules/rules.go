@@ -0,0 +1,125 @@
+// Package rules evaluates recording rules: expressions over the stored
+// metrics whose results are periodically stored as gauges, so that derived
+// metrics such as HeapUtilization = HeapInuse / HeapSys do not have to be
+// computed by every client.
+//
+// A scalar result is stored as the gauge named by the rule. Each series of
+// a vector result is stored as a gauge with that name and the labels of
+// the series, so sum by (code) (rate(requests[5m])) recorded as
+// requests:rate5m stores requests:rate5m{code="200"} and so on.
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/expr"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/goccy/go-json"
+)
+
+var ErrInvalidRule = errors.New("invalid recording rule")
+
+// Rule stores the result of Expr as the gauge Record, or as one gauge per
+// series named Record with the labels of the series.
+type Rule struct {
+	Record string `json:"record"`
+	Expr   string `json:"expr"`
+}
+
+// rule is a Rule with its parsed expression.
+type rule struct {
+	Rule
+	expr expr.Expr
+}
+
+// Load reads a JSON array of rules.
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// Engine evaluates recording rules over a storage.
+type Engine struct {
+	st    serviceInterface.MetricsStorage
+	rules []rule
+}
+
+// New parses rules evaluated over st. Rules are evaluated in order, so a
+// rule may use the results of the rules before it.
+func New(st serviceInterface.MetricsStorage, rules []Rule) (*Engine, error) {
+	e := &Engine{st: st}
+	for i, r := range rules {
+		if r.Record == "" {
+			return nil, fmt.Errorf("%w %d: record is empty", ErrInvalidRule, i)
+		}
+		parsed, err := expr.Parse(r.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %v", ErrInvalidRule, r.Record, err)
+		}
+		e.rules = append(e.rules, rule{Rule: r, expr: parsed})
+	}
+	return e, nil
+}
+
+// Evaluate evaluates every rule and stores the results. Rules that cannot
+// be evaluated, for example because a metric has not been reported yet,
+// keep their previous result; the joined errors of such rules are returned.
+func (e *Engine) Evaluate() error {
+	var errs []error
+	for _, r := range e.rules {
+		if err := e.record(r); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Record, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// record evaluates r and stores its result.
+func (e *Engine) record(r rule) error {
+	v, err := expr.Eval(r.expr, e.st, time.Now())
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case expr.Scalar:
+		return e.st.UpdateGauge(r.Record, float64(v))
+	case expr.Vector:
+		if len(v) == 0 {
+			return fmt.Errorf("%w: %s", expr.ErrNoData, r.expr)
+		}
+		for _, s := range v {
+			if err := e.st.UpdateGauge(f.SeriesID(r.Record, s.Labels), s.Value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Run evaluates the rules every interval until stop is closed.
+func (e *Engine) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		if err := e.Evaluate(); err != nil {
+			logger.Log.Warn(fmt.Sprintf("recording rules: %v", err))
+		}
+	}
+}
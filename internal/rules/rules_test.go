@@ -0,0 +1,81 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/expr"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEngine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.NoError(
+		t, os.WriteFile(
+			path, []byte(`[
+				{"record": "HeapUtilization", "expr": "HeapInuse / HeapSys"},
+				{"record": "HeapUtilizationPct", "expr": "HeapUtilization * 100"},
+				{"record": "MemoryUsedPct", "expr": "(TotalMemory - FreeMemory) / TotalMemory * 100"}
+			]`), 0o600,
+		),
+	)
+	loaded, err := Load(path)
+	require.NoError(t, err)
+
+	st := filememory.NewMemStorage(false, nil)
+	require.NoError(t, st.UpdateGauge("HeapInuse", 30))
+	require.NoError(t, st.UpdateGauge("HeapSys", 120))
+	e, err := New(st, loaded)
+	require.NoError(t, err)
+
+	err = e.Evaluate()
+	assert.ErrorIs(t, err, expr.ErrNoData)
+	assert.ErrorContains(t, err, "MemoryUsedPct")
+	value, ok, _ := st.GetGauge("HeapUtilizationPct")
+	require.True(t, ok)
+	assert.InDelta(t, 25, value, 1e-9)
+	_, ok, _ = st.GetGauge("MemoryUsedPct")
+	assert.False(t, ok)
+
+	require.NoError(t, st.UpdateGauge("TotalMemory", 8))
+	require.NoError(t, st.UpdateGauge("FreeMemory", 2))
+	require.NoError(t, e.Evaluate())
+	value, _, _ = st.GetGauge("MemoryUsedPct")
+	assert.InDelta(t, 75, value, 1e-9)
+}
+
+func TestNewInvalid(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	_, err := New(st, []Rule{{Expr: "1"}})
+	assert.ErrorIs(t, err, ErrInvalidRule)
+	_, err = New(st, []Rule{{Record: "x", Expr: "1 +"}})
+	assert.ErrorIs(t, err, ErrInvalidRule)
+}
+
+func TestEngineVector(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	require.NoError(t, st.UpdateCounter(`requests{code="200",method="GET"}`, 7, false))
+	require.NoError(t, st.UpdateCounter(`requests{code="200",method="POST"}`, 2, false))
+	require.NoError(t, st.UpdateCounter(`requests{code="500",method="GET"}`, 1, false))
+	e, err := New(
+		st, []Rule{
+			{Record: "requests:by_code", Expr: "sum by (code) (requests)"},
+			{Record: "requests:missing", Expr: "sum(missing)"},
+		},
+	)
+	require.NoError(t, err)
+
+	err = e.Evaluate()
+	assert.ErrorIs(t, err, expr.ErrNoData)
+	assert.ErrorContains(t, err, "requests:missing")
+	_, gauges := st.GetMetrics()
+	assert.Equal(
+		t, map[string]float64{
+			`requests:by_code{code="200"}`: 9,
+			`requests:by_code{code="500"}`: 1,
+		}, gauges,
+	)
+}
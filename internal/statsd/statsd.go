@@ -0,0 +1,379 @@
+// Package statsd receives metrics in the StatsD line protocol over UDP and
+// TCP, aggregates them per flush interval and writes the result through a
+// MetricsStorage.
+//
+// Supported lines are name:value|type[|@rate][|#tag:value,...] with the
+// types c (counter), g (gauge, +N and -N change the current value),
+// ms, h and d (timers) and s (sets). DogStatsD tags become labels of the
+// metric ID, e.g. requests{code="200"}.
+package statsd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+)
+
+var (
+	ErrInvalidLine = errors.New("invalid statsd line")
+	ErrInvalidType = errors.New("unsupported statsd metric type")
+)
+
+// maxPacketSize is the largest UDP datagram the listener reads.
+const maxPacketSize = 65535
+
+type gauge struct {
+	value    float64
+	relative bool
+}
+
+type timer struct {
+	values []float64
+	count  float64
+}
+
+// Server aggregates StatsD metrics and writes them to the storage on Flush.
+type Server struct {
+	st serviceInterface.MetricsStorage
+
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]gauge
+	timers   map[string]*timer
+	sets     map[string]map[string]struct{}
+	// remainders are the fractions of the counters written by the previous
+	// flushes, carried into the next interval.
+	remainders map[string]float64
+}
+
+// NewServer creates a Server writing into st.
+func NewServer(st serviceInterface.MetricsStorage) *Server {
+	s := &Server{st: st, remainders: make(map[string]float64)}
+	s.reset()
+	return s
+}
+
+func (s *Server) reset() {
+	s.counters = make(map[string]float64)
+	s.gauges = make(map[string]gauge)
+	s.timers = make(map[string]*timer)
+	s.sets = make(map[string]map[string]struct{})
+}
+
+// ServeUDP reads datagrams from conn until it is closed. A datagram may
+// carry several lines separated by newlines.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.HandlePacket(buf[:n])
+	}
+}
+
+// ServeTCP accepts connections on l until it is closed and reads
+// newline-separated lines from each of them.
+func (s *Server) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn io.ReadCloser) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		s.handleLine(scanner.Text())
+	}
+}
+
+// HandlePacket aggregates every line of a packet. Invalid lines are logged
+// and skipped.
+func (s *Server) HandlePacket(packet []byte) {
+	for _, line := range strings.Split(string(packet), "\n") {
+		s.handleLine(line)
+	}
+}
+
+func (s *Server) handleLine(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	if err := s.Handle(line); err != nil {
+		logger.Log.Error(err.Error())
+	}
+}
+
+// Handle parses a single StatsD line and adds it to the current interval.
+func (s *Server) Handle(line string) error {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return fmt.Errorf("%w: %q", ErrInvalidLine, line)
+	}
+	raw, mType := fields[0], fields[1]
+
+	rate := 1.0
+	var labels []f.Label
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			r, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return fmt.Errorf("%w: sample rate in %q", ErrInvalidLine, line)
+			}
+			rate = r
+		case strings.HasPrefix(field, "#"):
+			labels = append(labels, parseTags(field[1:])...)
+		}
+	}
+	id := f.SeriesID(name, labels)
+
+	if mType == "s" {
+		s.mu.Lock()
+		if s.sets[id] == nil {
+			s.sets[id] = make(map[string]struct{})
+		}
+		s.sets[id][raw] = struct{}{}
+		s.mu.Unlock()
+		return nil
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w: value in %q", ErrInvalidLine, line)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch mType {
+	case "c":
+		s.counters[id] += value / rate
+	case "g":
+		if raw[0] == '+' || raw[0] == '-' {
+			g := s.gauges[id]
+			g.value += value
+			if _, set := s.gauges[id]; !set {
+				g.relative = true
+			}
+			s.gauges[id] = g
+		} else {
+			s.gauges[id] = gauge{value: value}
+		}
+	case "ms", "h", "d":
+		t := s.timers[id]
+		if t == nil {
+			t = &timer{}
+			s.timers[id] = t
+		}
+		t.values = append(t.values, value)
+		t.count += 1 / rate
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidType, mType)
+	}
+	return nil
+}
+
+// Flush writes the metrics aggregated since the previous flush to the
+// storage and starts a new interval. Timers are written as the counter
+// name.count and the gauges name.sum, name.mean, name.min, name.max and
+// name.p90; sets as a gauge with the number of unique values. Counters are
+// written as whole numbers, their fractions are carried into the next
+// interval. Metrics the storage rejects for good, such as type conflicts,
+// are logged and dropped; others that could not be written are kept for
+// the next flush.
+func (s *Server) Flush() error {
+	s.mu.Lock()
+	counters, gauges, timers, sets := s.counters, s.gauges, s.timers, s.sets
+	previous := s.remainders
+	s.remainders = make(map[string]float64)
+	s.reset()
+	s.mu.Unlock()
+
+	var (
+		metrics    []f.Metric
+		failed     error
+		remainders = make(map[string]float64)
+	)
+	// delta returns the whole part of a counter and keeps its fraction.
+	delta := func(id string, value float64) int64 {
+		value += previous[id]
+		d := math.Round(value)
+		remainders[id] = value - d
+		return int64(d)
+	}
+	addCounter := func(id string, delta int64) {
+		metrics = append(metrics, f.Metric{ID: id, MType: config.Counter, Delta: &delta})
+	}
+	addGauge := func(id string, value float64) {
+		metrics = append(metrics, f.Metric{ID: id, MType: config.Gauge, Value: &value})
+	}
+
+	for _, id := range sortedKeys(counters) {
+		if d := delta(id, counters[id]); d != 0 {
+			addCounter(id, d)
+		}
+	}
+	for _, id := range sortedKeys(gauges) {
+		g := gauges[id]
+		if g.relative {
+			current, _, err := s.st.GetGauge(id)
+			if err != nil {
+				s.mu.Lock()
+				s.mergeGauge(id, g)
+				s.mu.Unlock()
+				failed = err
+				continue
+			}
+			g.value += current
+		}
+		addGauge(id, g.value)
+	}
+	for _, id := range sortedKeys(timers) {
+		t := timers[id]
+		sort.Float64s(t.values)
+		var sum float64
+		for _, v := range t.values {
+			sum += v
+		}
+		name, labels := f.ParseSeriesID(id)
+		suffixed := func(suffix string) string {
+			return f.SeriesID(name+suffix, labels)
+		}
+		addCounter(suffixed(".count"), delta(suffixed(".count"), t.count))
+		addGauge(suffixed(".sum"), sum)
+		addGauge(suffixed(".mean"), sum/float64(len(t.values)))
+		addGauge(suffixed(".min"), t.values[0])
+		addGauge(suffixed(".max"), t.values[len(t.values)-1])
+		addGauge(suffixed(".p90"), percentile(t.values, 0.9))
+	}
+	for _, id := range sortedKeys(sets) {
+		addGauge(id, float64(len(sets[id])))
+	}
+	for id, r := range previous {
+		if _, ok := remainders[id]; !ok {
+			remainders[id] = r
+		}
+	}
+
+	failures := storage.InsertEach(s.st, metrics)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, r := range remainders {
+		if r != 0 {
+			s.remainders[id] = r
+		}
+	}
+	for _, fail := range failures {
+		if errors.Is(fail.Err, storage.ErrRejected) {
+			logger.Log.Error(fmt.Sprintf("statsd metric %s dropped: %v", fail.Metric.ID, fail.Err))
+			continue
+		}
+		s.restore(fail.Metric)
+		failed = fail.Err
+	}
+	return failed
+}
+
+// restore adds a metric that could not be written to the current interval.
+// The caller must hold s.mu.
+func (s *Server) restore(m f.Metric) {
+	if m.MType == config.Counter {
+		s.counters[m.ID] += float64(*m.Delta)
+	} else {
+		s.mergeGauge(m.ID, gauge{value: *m.Value})
+	}
+}
+
+// mergeGauge merges a gauge of a previous interval into the current one,
+// where a value set since then wins and changes are applied on top of it.
+// The caller must hold s.mu.
+func (s *Server) mergeGauge(id string, g gauge) {
+	current, ok := s.gauges[id]
+	switch {
+	case !ok:
+		s.gauges[id] = g
+	case current.relative:
+		s.gauges[id] = gauge{value: g.value + current.value, relative: g.relative}
+	}
+}
+
+// Run flushes every interval until stop is closed, then flushes once more.
+func (s *Server) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			if err := s.Flush(); err != nil {
+				logger.Log.Error(fmt.Sprintf("statsd flush failed: %v", err))
+			}
+			return
+		}
+		if err := s.Flush(); err != nil {
+			logger.Log.Error(fmt.Sprintf("statsd flush failed: %v", err))
+		}
+	}
+}
+
+// parseTags parses DogStatsD tags. Tags without a value get an empty one.
+func parseTags(tags string) []f.Label {
+	var labels []f.Label
+	for _, tag := range strings.Split(tags, ",") {
+		if tag == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(tag, ":")
+		labels = append(labels, f.Label{Name: name, Value: value})
+	}
+	return labels
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,179 @@
+package statsd
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		wantErr error
+	}{
+		{name: "Counter", line: "requests:1|c"},
+		{name: "Sampled Counter", line: "requests:1|c|@0.5"},
+		{name: "Gauge", line: "temperature:21.5|g"},
+		{name: "Timer With Tags", line: "latency:120|ms|#route:/api,code:200"},
+		{name: "Set", line: "users:alice|s"},
+		{name: "Missing Type", line: "requests:1", wantErr: ErrInvalidLine},
+		{name: "Invalid Value", line: "requests:x|c", wantErr: ErrInvalidLine},
+		{name: "Invalid Rate", line: "requests:1|c|@2", wantErr: ErrInvalidLine},
+		{name: "Unknown Type", line: "requests:1|x", wantErr: ErrInvalidType},
+	}
+	s := NewServer(filememory.NewMemStorage(false, nil))
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				err := s.Handle(tt.line)
+				if tt.wantErr != nil {
+					assert.ErrorIs(t, err, tt.wantErr)
+				} else {
+					assert.NoError(t, err)
+				}
+			},
+		)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	require.NoError(t, st.UpdateGauge("queue", 10))
+	s := NewServer(st)
+
+	s.HandlePacket(
+		[]byte(
+			"requests:1|c\nrequests:1|c|@0.1\n" +
+				"queue:-3|g\n" +
+				"temperature:20|g\ntemperature:+1.5|g\n" +
+				"latency:10|ms|#code:200\nlatency:30|ms|#code:200\nlatency:20|ms|#code:200\n" +
+				"users:alice|s\nusers:bob|s\nusers:alice|s\n",
+		),
+	)
+	require.NoError(t, s.Flush())
+
+	counters, gauges := st.GetMetrics()
+	assert.Equal(t, map[string]int64{"requests": 11, `latency.count{code="200"}`: 3}, counters)
+	assert.Equal(
+		t, map[string]float64{
+			"queue":                    7,
+			"temperature":              21.5,
+			`latency.sum{code="200"}`:  60,
+			`latency.mean{code="200"}`: 20,
+			`latency.min{code="200"}`:  10,
+			`latency.max{code="200"}`:  30,
+			`latency.p90{code="200"}`:  30,
+			"users":                    2,
+		}, gauges,
+	)
+
+	require.NoError(t, s.Handle("requests:2|c"))
+	require.NoError(t, s.Flush())
+	counters, _ = st.GetMetrics()
+	assert.Equal(t, int64(13), counters["requests"])
+}
+
+func TestFlushCarriesFractions(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	s := NewServer(st)
+
+	var written []int64
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Handle("requests:1|c|@0.3"))
+		require.NoError(t, s.Flush())
+		counters, _ := st.GetMetrics()
+		written = append(written, counters["requests"])
+	}
+	// 3.33 is written as 3, 3 and 4, adding up to the 10 requests sent.
+	assert.Equal(t, []int64{3, 7, 10}, written)
+}
+
+// failingStorage fails every write while fail is set.
+type failingStorage struct {
+	*filememory.MemStorage
+	fail bool
+}
+
+func (s *failingStorage) InsertBatchMetrics(metrics []f.Metric) error {
+	if s.fail {
+		return errors.New("storage is down")
+	}
+	return s.MemStorage.InsertBatchMetrics(metrics)
+}
+
+func TestFlushFailureKeepsAggregates(t *testing.T) {
+	st := &failingStorage{MemStorage: filememory.NewMemStorage(false, nil), fail: true}
+	s := NewServer(st)
+
+	s.HandlePacket([]byte("requests:2|c\nqueue:5|g\nlatency:10|ms\nusers:alice|s\n"))
+	require.Error(t, s.Flush())
+	s.HandlePacket([]byte("requests:1|c\nqueue:+1|g\nlatency:30|ms\nusers:bob|s\n"))
+	st.fail = false
+	require.NoError(t, s.Flush())
+
+	counters, gauges := st.GetMetrics()
+	assert.Equal(t, map[string]int64{"requests": 3, "latency.count": 2}, counters)
+	assert.Equal(t, 6.0, gauges["queue"])
+	assert.Equal(t, 30.0, gauges["latency.max"])
+	assert.Equal(t, 1.0, gauges["users"])
+}
+
+func TestFlushDropsRejectedMetrics(t *testing.T) {
+	logger.LogInit("info")
+	st := metadata.NewStorage(filememory.NewMemStorage(false, nil), metadata.NewRegistry(""))
+	require.NoError(t, st.UpdateGauge("aaa", 1))
+	s := NewServer(st)
+
+	require.NoError(t, s.Handle("aaa:1|c"))
+	require.NoError(t, s.Handle("zzz:5|c"))
+	require.NoError(t, s.Flush())
+	require.NoError(t, s.Flush())
+
+	counters, _ := st.GetMetrics()
+	assert.Equal(t, map[string]int64{"zzz": 5}, counters)
+	assert.Empty(t, s.counters)
+}
+
+func TestListeners(t *testing.T) {
+	logger.LogInit("info")
+	st := filememory.NewMemStorage(false, nil)
+	s := NewServer(st)
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go s.ServeUDP(udp)
+	go s.ServeTCP(tcp)
+	defer udp.Close()
+	defer tcp.Close()
+
+	conn, err := net.Dial("udp", udp.LocalAddr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("udp_requests:2|c\ninvalid"))
+	require.NoError(t, err)
+	conn.Close()
+
+	conn, err = net.Dial("tcp", tcp.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("tcp_gauge:4|g\n"))
+	require.NoError(t, err)
+	conn.Close()
+
+	assert.Eventually(
+		t, func() bool {
+			require.NoError(t, s.Flush())
+			counters, gauges := st.GetMetrics()
+			return counters["udp_requests"] == 2 && gauges["tcp_gauge"] == 4
+		}, time.Second, 10*time.Millisecond,
+	)
+}
@@ -0,0 +1,157 @@
+// Package backends links every storage backend into the binary and builds
+// the storage configured for the server. Both the HTTP and the gRPC server
+// use it, so a new backend only has to be imported here.
+package backends
+
+import (
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/cache"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/hub"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/mirror"
+	"github.com/elina-chertova/metrics-alerting.git/internal/tenant"
+
+	_ "github.com/elina-chertova/metrics-alerting.git/internal/storage/bolt"
+	_ "github.com/elina-chertova/metrics-alerting.git/internal/storage/db"
+	_ "github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+)
+
+// cacheBus is implemented by backends shared between server instances that
+// can deliver cache invalidations.
+type cacheBus interface {
+	CacheBus() cache.Bus
+}
+
+// withCache wraps st with a cache when it is enabled in the configuration.
+func withCache(st serviceInterface.MetricsStorage, cfg *config.Server) serviceInterface.MetricsStorage {
+	if !cfg.Cache {
+		return st
+	}
+	var bus cache.Bus
+	for s := st; s != nil; {
+		if b, ok := s.(cacheBus); ok {
+			bus = b.CacheBus()
+			break
+		}
+		u, ok := s.(interface {
+			Unwrap() serviceInterface.MetricsStorage
+		})
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+	return cache.New(st, time.Duration(cfg.CacheTTL)*time.Second, bus)
+}
+
+// withHistory records the history of metric values when it is enabled in
+// the configuration.
+func withHistory(st serviceInterface.MetricsStorage, cfg *config.Server) serviceInterface.MetricsStorage {
+	if cfg.HistoryRetention <= 0 {
+		return st
+	}
+	return history.New(st, time.Duration(cfg.HistoryRetention)*time.Second)
+}
+
+// withMirror mirrors all writes to st into secondary when a mirror storage
+// is configured, and starts the periodic comparison of both until stop is
+// closed.
+func withMirror(
+	st serviceInterface.MetricsStorage,
+	secondary serviceInterface.MetricsStorage,
+	cfg *config.Server,
+	stop <-chan struct{},
+) serviceInterface.MetricsStorage {
+	if secondary == nil {
+		return st
+	}
+	m := mirror.New(st, secondary)
+	if cfg.MirrorCheck > 0 {
+		go m.Check(time.Duration(cfg.MirrorCheck)*time.Second, stop)
+	}
+	return m
+}
+
+// openMirror opens the mirror storage, or returns nil if none is configured.
+func openMirror(cfg *config.Server) (serviceInterface.MetricsStorage, error) {
+	if cfg.MirrorURL == "" {
+		return nil, nil
+	}
+	return storage.Open(cfg.MirrorURL, cfg)
+}
+
+// Build opens the storage selected by the server configuration and wraps
+// it with the optional mirror, the optional cache, the history, the update
+// hub and the metric metadata registry. The unwrapped backend is returned
+// as well for backend-specific routes such as /ping. The mirror comparison
+// stops when stop is closed.
+func Build(cfg *config.Server, stop <-chan struct{}) (
+	st serviceInterface.MetricsStorage,
+	backend serviceInterface.MetricsStorage,
+	err error,
+) {
+	backend, err = storage.Open(storage.URL(cfg), cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	secondary, err := openMirror(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	st = hub.New(withHistory(withCache(withMirror(backend, secondary, cfg, stop), cfg), cfg))
+	return metadata.NewStorage(st, metadata.NewRegistry(cfg.MetadataFile)), backend, nil
+}
+
+// BuildTenants opens the storage selected by the server configuration and
+// returns a tenant registry partitioning it, together with the backend of
+// the default tenant. The mirror comparisons stop when stop is closed.
+func BuildTenants(
+	cfg *config.Server,
+	stop <-chan struct{},
+) (*tenant.Registry, serviceInterface.MetricsStorage, error) {
+	var tokens map[string]string
+	if cfg.TenantTokens != "" {
+		var err error
+		if tokens, err = tenant.LoadTokens(cfg.TenantTokens); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	rawURL := storage.URL(cfg)
+	backend, err := storage.Open(rawURL, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	secondary, err := openMirror(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	factory := func(id string) (serviceInterface.MetricsStorage, error) {
+		st, mirrored := backend, secondary
+		if id != tenant.Default {
+			partition, err := storage.Partition(backend, rawURL, cfg, id, tenant.FilePath)
+			if err != nil {
+				return nil, err
+			}
+			st = partition
+			if mirrored != nil {
+				mirrored, err = storage.Partition(secondary, cfg.MirrorURL, cfg, id, tenant.FilePath)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		st = hub.New(withHistory(withCache(withMirror(st, mirrored, cfg, stop), cfg), cfg))
+		registry := metadata.NewRegistry(tenant.FilePath(cfg.MetadataFile, id))
+		return metadata.NewStorage(st, registry), nil
+	}
+	limits := tenant.Limits{MaxSeries: cfg.TenantMaxSeries, MaxRate: cfg.TenantMaxRate}
+	return tenant.NewRegistry(factory, limits, tokens), backend, nil
+}
@@ -0,0 +1,207 @@
+// Package bolt provides a metrics storage kept in a single BoltDB file.
+// Counters and gauges live in separate buckets keyed by metric name.
+package bolt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	ErrEmptyPath       = errors.New("bolt storage path is empty")
+	ErrUnsupportedType = errors.New("unsupported metric type")
+	ErrMissingValue    = errors.New("metric has no value")
+)
+
+var (
+	counterBucket = []byte(config.Counter)
+	gaugeBucket   = []byte(config.Gauge)
+)
+
+func init() {
+	storage.Register(openURL, "bolt")
+}
+
+// Storage stores metrics in a BoltDB file.
+type Storage struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the BoltDB file at path.
+func Open(path string) (*Storage, error) {
+	if path == "" {
+		return nil, ErrEmptyPath
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0666, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(
+		func(tx *bolt.Tx) error {
+			for _, name := range [][]byte{counterBucket, gaugeBucket} {
+				if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Storage{db: db}, nil
+}
+
+// openURL opens the file of a bolt:// URL.
+func openURL(u *url.URL, _ *config.Server) (serviceInterface.MetricsStorage, error) {
+	return Open(storage.Path(u))
+}
+
+// Close closes the BoltDB file.
+func (s *Storage) Close() error {
+	return s.db.Close()
+}
+
+// UpdateCounter adds value to the named counter.
+func (s *Storage) UpdateCounter(name string, value int64, ok bool) error {
+	return s.db.Update(
+		func(tx *bolt.Tx) error {
+			return addCounter(tx, name, value)
+		},
+	)
+}
+
+// UpdateGauge sets the value of the named gauge.
+func (s *Storage) UpdateGauge(name string, value float64) error {
+	return s.db.Update(
+		func(tx *bolt.Tx) error {
+			return setGauge(tx, name, value)
+		},
+	)
+}
+
+// GetCounter retrieves the value of the named counter.
+func (s *Storage) GetCounter(name string) (int64, bool, error) {
+	var (
+		value int64
+		ok    bool
+	)
+	err := s.db.View(
+		func(tx *bolt.Tx) error {
+			if v := tx.Bucket(counterBucket).Get([]byte(name)); v != nil {
+				value, ok = int64(binary.BigEndian.Uint64(v)), true
+			}
+			return nil
+		},
+	)
+	return value, ok, err
+}
+
+// GetGauge retrieves the value of the named gauge.
+func (s *Storage) GetGauge(name string) (float64, bool, error) {
+	var (
+		value float64
+		ok    bool
+	)
+	err := s.db.View(
+		func(tx *bolt.Tx) error {
+			if v := tx.Bucket(gaugeBucket).Get([]byte(name)); v != nil {
+				value, ok = math.Float64frombits(binary.BigEndian.Uint64(v)), true
+			}
+			return nil
+		},
+	)
+	return value, ok, err
+}
+
+// GetMetrics returns all stored counter and gauge metrics.
+func (s *Storage) GetMetrics() (map[string]int64, map[string]float64) {
+	counter := make(map[string]int64)
+	gauge := make(map[string]float64)
+	err := s.db.View(
+		func(tx *bolt.Tx) error {
+			err := tx.Bucket(counterBucket).ForEach(
+				func(k, v []byte) error {
+					counter[string(k)] = int64(binary.BigEndian.Uint64(v))
+					return nil
+				},
+			)
+			if err != nil {
+				return err
+			}
+			return tx.Bucket(gaugeBucket).ForEach(
+				func(k, v []byte) error {
+					gauge[string(k)] = math.Float64frombits(binary.BigEndian.Uint64(v))
+					return nil
+				},
+			)
+		},
+	)
+	if err != nil {
+		logger.Log.Error(fmt.Sprintf("failed to read metrics: %v", err))
+	}
+	return counter, gauge
+}
+
+// InsertBatchMetrics stores all metrics in a single transaction.
+func (s *Storage) InsertBatchMetrics(metrics []formatter.Metric) error {
+	return s.db.Update(
+		func(tx *bolt.Tx) error {
+			for _, m := range metrics {
+				var err error
+				switch m.MType {
+				case config.Counter:
+					if m.Delta == nil {
+						return fmt.Errorf("%w: %s", ErrMissingValue, m.ID)
+					}
+					err = addCounter(tx, m.ID, *m.Delta)
+				case config.Gauge:
+					if m.Value == nil {
+						return fmt.Errorf("%w: %s", ErrMissingValue, m.ID)
+					}
+					err = setGauge(tx, m.ID, *m.Value)
+				default:
+					err = fmt.Errorf("%w: %s", ErrUnsupportedType, m.MType)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+}
+
+func addCounter(tx *bolt.Tx, name string, delta int64) error {
+	b := tx.Bucket(counterBucket)
+	var current int64
+	if v := b.Get([]byte(name)); v != nil {
+		current = int64(binary.BigEndian.Uint64(v))
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(current+delta))
+	return b.Put([]byte(name), buf)
+}
+
+func setGauge(tx *bolt.Tx, name string, value float64) error {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(value))
+	return tx.Bucket(gaugeBucket).Put([]byte(name), buf)
+}
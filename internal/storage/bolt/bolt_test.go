@@ -0,0 +1,52 @@
+package bolt
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metrics.db")
+	s, err := Open(path)
+	require.NoError(t, err)
+
+	assert.NoError(t, s.UpdateCounter("PollCount", 2, false))
+	assert.NoError(t, s.UpdateCounter("PollCount", 3, true))
+	assert.NoError(t, s.UpdateGauge("Alloc", 1.5))
+
+	delta := int64(5)
+	value := 2.5
+	assert.NoError(
+		t, s.InsertBatchMetrics(
+			[]formatter.Metric{
+				{ID: "PollCount", MType: config.Counter, Delta: &delta},
+				{ID: "Alloc", MType: config.Gauge, Value: &value},
+			},
+		),
+	)
+	assert.ErrorIs(t, s.InsertBatchMetrics([]formatter.Metric{{ID: "x", MType: "x"}}), ErrUnsupportedType)
+	require.NoError(t, s.Close())
+
+	reopened, err := storage.Open("bolt://"+path, nil)
+	require.NoError(t, err)
+	defer reopened.(*Storage).Close()
+
+	counter, ok, err := reopened.GetCounter("PollCount")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), counter)
+
+	counters, gauges := reopened.GetMetrics()
+	assert.Equal(t, map[string]int64{"PollCount": 10}, counters)
+	assert.Equal(t, map[string]float64{"Alloc": 2.5}, gauges)
+
+	_, ok, err = reopened.GetGauge("missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
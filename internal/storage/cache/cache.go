@@ -0,0 +1,258 @@
+// Package cache provides a caching decorator for any MetricsStorage.
+// Reads are served from memory, writes go through to the wrapped storage
+// and update the cache. When several server instances share one backend,
+// a Bus carries invalidations between them.
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+)
+
+// Event tells other server instances that a metric has changed. An Event
+// with an empty Name drops the whole cache, for example after a Bus had to
+// reconnect and may have missed events.
+type Event struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// Bus delivers invalidation events between server instances. Subscribers
+// must not be called for events published by the same Bus.
+type Bus interface {
+	Publish(events []Event) error
+	Subscribe(fn func(Event))
+}
+
+type counterEntry struct {
+	value   int64
+	ok      bool
+	expires time.Time
+}
+
+type gaugeEntry struct {
+	value   float64
+	ok      bool
+	expires time.Time
+}
+
+type snapshot struct {
+	counter map[string]int64
+	gauge   map[string]float64
+	expires time.Time
+}
+
+// Cache wraps a MetricsStorage with a read-through, write-through cache.
+type Cache struct {
+	serviceInterface.MetricsStorage
+	ttl time.Duration
+	bus Bus
+
+	mu       sync.RWMutex
+	counters map[string]counterEntry
+	gauges   map[string]gaugeEntry
+	all      *snapshot
+	// gen is incremented by every write and invalidation, so a read that
+	// raced with one does not put a stale value into the cache.
+	gen uint64
+}
+
+// New wraps st with a cache. Entries expire after ttl, or never if ttl is
+// zero. bus may be nil when the storage is not shared with other instances.
+func New(st serviceInterface.MetricsStorage, ttl time.Duration, bus Bus) *Cache {
+	c := &Cache{
+		MetricsStorage: st,
+		ttl:            ttl,
+		bus:            bus,
+		counters:       make(map[string]counterEntry),
+		gauges:         make(map[string]gaugeEntry),
+	}
+	if bus != nil {
+		bus.Subscribe(c.invalidate)
+	}
+	return c
+}
+
+// Unwrap returns the wrapped storage.
+func (c *Cache) Unwrap() serviceInterface.MetricsStorage {
+	return c.MetricsStorage
+}
+
+// UpdateCounter updates the counter in the wrapped storage and drops the
+// cached value, since the new total is only known to the storage.
+func (c *Cache) UpdateCounter(name string, value int64, ok bool) error {
+	if err := c.MetricsStorage.UpdateCounter(name, value, ok); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	delete(c.counters, name)
+	c.all = nil
+	c.gen++
+	c.mu.Unlock()
+	c.publish([]Event{{Type: config.Counter, Name: name}})
+	return nil
+}
+
+// UpdateGauge updates the gauge in the wrapped storage and in the cache.
+func (c *Cache) UpdateGauge(name string, value float64) error {
+	if err := c.MetricsStorage.UpdateGauge(name, value); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.gauges[name] = gaugeEntry{value: value, ok: true, expires: c.expiry()}
+	c.all = nil
+	c.gen++
+	c.mu.Unlock()
+	c.publish([]Event{{Type: config.Gauge, Name: name}})
+	return nil
+}
+
+// InsertBatchMetrics inserts the batch into the wrapped storage and
+// updates the cache the same way as the single metric updates.
+func (c *Cache) InsertBatchMetrics(metrics []f.Metric) error {
+	if err := c.MetricsStorage.InsertBatchMetrics(metrics); err != nil {
+		return err
+	}
+	events := make([]Event, 0, len(metrics))
+	c.mu.Lock()
+	for _, m := range metrics {
+		switch m.MType {
+		case config.Counter:
+			delete(c.counters, m.ID)
+		case config.Gauge:
+			if m.Value != nil {
+				c.gauges[m.ID] = gaugeEntry{value: *m.Value, ok: true, expires: c.expiry()}
+			}
+		}
+		events = append(events, Event{Type: m.MType, Name: m.ID})
+	}
+	c.all = nil
+	c.gen++
+	c.mu.Unlock()
+	c.publish(events)
+	return nil
+}
+
+// GetCounter returns the cached counter or reads it from the wrapped storage.
+func (c *Cache) GetCounter(name string) (int64, bool, error) {
+	c.mu.RLock()
+	e, hit := c.counters[name]
+	gen := c.gen
+	c.mu.RUnlock()
+	if hit && c.fresh(e.expires) {
+		return e.value, e.ok, nil
+	}
+	value, ok, err := c.MetricsStorage.GetCounter(name)
+	if err != nil {
+		return value, ok, err
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		c.counters[name] = counterEntry{value: value, ok: ok, expires: c.expiry()}
+	}
+	c.mu.Unlock()
+	return value, ok, nil
+}
+
+// GetGauge returns the cached gauge or reads it from the wrapped storage.
+func (c *Cache) GetGauge(name string) (float64, bool, error) {
+	c.mu.RLock()
+	e, hit := c.gauges[name]
+	gen := c.gen
+	c.mu.RUnlock()
+	if hit && c.fresh(e.expires) {
+		return e.value, e.ok, nil
+	}
+	value, ok, err := c.MetricsStorage.GetGauge(name)
+	if err != nil {
+		return value, ok, err
+	}
+	c.mu.Lock()
+	if c.gen == gen {
+		c.gauges[name] = gaugeEntry{value: value, ok: ok, expires: c.expiry()}
+	}
+	c.mu.Unlock()
+	return value, ok, nil
+}
+
+// GetMetrics returns a copy of the cached list of all metrics, reading it
+// from the wrapped storage after any write or expiry.
+func (c *Cache) GetMetrics() (map[string]int64, map[string]float64) {
+	c.mu.RLock()
+	all := c.all
+	gen := c.gen
+	c.mu.RUnlock()
+	if all == nil || !c.fresh(all.expires) {
+		counter, gauge := c.MetricsStorage.GetMetrics()
+		all = &snapshot{
+			counter: copyCounters(counter),
+			gauge:   copyGauges(gauge),
+			expires: c.expiry(),
+		}
+		c.mu.Lock()
+		if c.gen == gen {
+			c.all = all
+		}
+		c.mu.Unlock()
+	}
+	return copyCounters(all.counter), copyGauges(all.gauge)
+}
+
+// invalidate drops the cached value of the metric in e.
+func (c *Cache) invalidate(e Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case e.Name == "":
+		c.counters = make(map[string]counterEntry)
+		c.gauges = make(map[string]gaugeEntry)
+	case e.Type == config.Counter:
+		delete(c.counters, e.Name)
+	case e.Type == config.Gauge:
+		delete(c.gauges, e.Name)
+	}
+	c.all = nil
+	c.gen++
+}
+
+func (c *Cache) publish(events []Event) {
+	if c.bus == nil {
+		return
+	}
+	if err := c.bus.Publish(events); err != nil {
+		logger.Log.Error(fmt.Sprintf("failed to publish cache invalidation: %v", err))
+	}
+}
+
+func (c *Cache) expiry() time.Time {
+	if c.ttl == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(c.ttl)
+}
+
+func (c *Cache) fresh(expires time.Time) bool {
+	return expires.IsZero() || time.Now().Before(expires)
+}
+
+func copyCounters(src map[string]int64) map[string]int64 {
+	dst := make(map[string]int64, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func copyGauges(src map[string]float64) map[string]float64 {
+	dst := make(map[string]float64, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingStorage struct {
+	*filememory.MemStorage
+	reads int
+}
+
+func (s *countingStorage) GetCounter(name string) (int64, bool, error) {
+	s.reads++
+	return s.MemStorage.GetCounter(name)
+}
+
+func (s *countingStorage) GetGauge(name string) (float64, bool, error) {
+	s.reads++
+	return s.MemStorage.GetGauge(name)
+}
+
+func (s *countingStorage) GetMetrics() (map[string]int64, map[string]float64) {
+	s.reads++
+	return s.MemStorage.GetMetrics()
+}
+
+func (s *countingStorage) InsertBatchMetrics(metrics []f.Metric) error {
+	for _, m := range metrics {
+		if m.MType == config.Counter {
+			_ = s.UpdateCounter(m.ID, *m.Delta, true)
+		} else {
+			_ = s.UpdateGauge(m.ID, *m.Value)
+		}
+	}
+	return nil
+}
+
+type fakeBus struct {
+	published   []Event
+	subscribers []func(Event)
+}
+
+func (b *fakeBus) Publish(events []Event) error {
+	b.published = append(b.published, events...)
+	return nil
+}
+
+func (b *fakeBus) Subscribe(fn func(Event)) {
+	b.subscribers = append(b.subscribers, fn)
+}
+
+func newStorage() *countingStorage {
+	return &countingStorage{MemStorage: filememory.NewMemStorage(false, nil)}
+}
+
+func TestCacheReadThrough(t *testing.T) {
+	st := newStorage()
+	c := New(st, 0, nil)
+
+	require.NoError(t, c.UpdateCounter("PollCount", 2, false))
+	for i := 0; i < 3; i++ {
+		value, ok, err := c.GetCounter("PollCount")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.Equal(t, int64(2), value)
+	}
+	assert.Equal(t, 1, st.reads)
+
+	require.NoError(t, c.UpdateCounter("PollCount", 3, true))
+	value, _, _ := c.GetCounter("PollCount")
+	assert.Equal(t, int64(5), value)
+	assert.Equal(t, 2, st.reads)
+
+	_, ok, err := c.GetGauge("missing")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, _, _ = c.GetGauge("missing")
+	assert.Equal(t, 3, st.reads)
+}
+
+func TestCacheWriteThrough(t *testing.T) {
+	st := newStorage()
+	c := New(st, 0, nil)
+
+	require.NoError(t, c.UpdateGauge("Alloc", 1.5))
+	value, ok, err := c.GetGauge("Alloc")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, value)
+	assert.Equal(t, 0, st.reads)
+
+	delta, gauge := int64(4), 2.5
+	require.NoError(
+		t, c.InsertBatchMetrics(
+			[]f.Metric{
+				{ID: "PollCount", MType: config.Counter, Delta: &delta},
+				{ID: "Alloc", MType: config.Gauge, Value: &gauge},
+			},
+		),
+	)
+	value, _, _ = c.GetGauge("Alloc")
+	assert.Equal(t, 2.5, value)
+	assert.Equal(t, 0, st.reads)
+
+	counters, gauges := c.GetMetrics()
+	assert.Equal(t, map[string]int64{"PollCount": 4}, counters)
+	assert.Equal(t, map[string]float64{"Alloc": 2.5}, gauges)
+	counters["PollCount"] = 100
+	counters, _ = c.GetMetrics()
+	assert.Equal(t, int64(4), counters["PollCount"])
+	assert.Equal(t, 1, st.reads)
+}
+
+func TestCacheTTL(t *testing.T) {
+	st := newStorage()
+	c := New(st, 10*time.Millisecond, nil)
+
+	require.NoError(t, st.UpdateGauge("Alloc", 1))
+	_, _, _ = c.GetGauge("Alloc")
+	_, _, _ = c.GetGauge("Alloc")
+	assert.Equal(t, 1, st.reads)
+
+	time.Sleep(20 * time.Millisecond)
+	_, _, _ = c.GetGauge("Alloc")
+	assert.Equal(t, 2, st.reads)
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	st := newStorage()
+	bus := &fakeBus{}
+	c := New(st, 0, bus)
+	require.Len(t, bus.subscribers, 1)
+
+	require.NoError(t, c.UpdateGauge("Alloc", 1))
+	assert.Equal(t, []Event{{Type: config.Gauge, Name: "Alloc"}}, bus.published)
+
+	// Another instance changes the shared storage and announces it.
+	require.NoError(t, st.UpdateGauge("Alloc", 2))
+	value, _, _ := c.GetGauge("Alloc")
+	assert.Equal(t, 1.0, value)
+
+	bus.subscribers[0](Event{Type: config.Gauge, Name: "Alloc"})
+	value, _, _ = c.GetGauge("Alloc")
+	assert.Equal(t, 2.0, value)
+
+	require.NoError(t, st.UpdateGauge("Alloc", 3))
+	bus.subscribers[0](Event{})
+	value, _, _ = c.GetGauge("Alloc")
+	assert.Equal(t, 3.0, value)
+}
@@ -4,8 +4,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
 	"github.com/gin-gonic/gin"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
@@ -14,15 +18,73 @@ import (
 
 type DB struct {
 	Database *gorm.DB
+	Tenant   string
+	notifier *Notifier
+}
+
+func init() {
+	storage.Register(openPostgres, "postgres", "postgresql")
 }
 
 func Connect(dsn string) *DB {
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := open(dsn)
 	if err != nil {
 		log.Fatalf("Unable to connect to database because %s", err)
 	}
-	db.AutoMigrate(&Metrics{})
-	return &DB{Database: db}
+	return db
+}
+
+// open connects to the database and migrates the metrics table.
+func open(dsn string) (*DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
+	return &DB{Database: db, Tenant: defaultTenant, notifier: newNotifier(dsn, db)}, nil
+}
+
+// migrate migrates the metrics table and drops the unique indexes on the
+// metric name left by earlier schemas.
+func migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&Metrics{}); err != nil {
+		return err
+	}
+	m := db.Migrator()
+	for _, name := range legacyNameIndexes {
+		if m.HasConstraint(&Metrics{}, name) {
+			if err := m.DropConstraint(&Metrics{}, name); err != nil {
+				return err
+			}
+		}
+		if m.HasIndex(&Metrics{}, name) {
+			if err := m.DropIndex(&Metrics{}, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// openPostgres opens the database of a postgres:// URL.
+func openPostgres(u *url.URL, _ *config.Server) (serviceInterface.MetricsStorage, error) {
+	return open(u.String())
+}
+
+// defaultTenant is the tenant of rows written by a single-tenant server.
+const defaultTenant = "default"
+
+// WithTenant returns a DB whose queries only see rows of the given tenant.
+func (db DB) WithTenant(id string) *DB {
+	return &DB{Database: db.Database, Tenant: id, notifier: db.notifier}
+}
+
+// Partition implements storage.Partitioner by sharing the connection
+// between tenants.
+func (db DB) Partition(id string) serviceInterface.MetricsStorage {
+	return db.WithTenant(id)
 }
 
 func (db *DB) PingDB() gin.HandlerFunc {
@@ -7,10 +7,12 @@ package db
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/elina-chertova/metrics-alerting.git/internal/config"
 	"github.com/elina-chertova/metrics-alerting.git/internal/formatter"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
 	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
 	"gorm.io/gorm"
 )
@@ -32,6 +34,21 @@ func TypeIsGauge(db *gorm.DB) *gorm.DB {
 	return db.Where("type = ?", config.Gauge)
 }
 
+// tenantScope returns a GORM scope function that restricts queries to the tenant of db.
+func (db DB) tenantScope() func(*gorm.DB) *gorm.DB {
+	return func(d *gorm.DB) *gorm.DB {
+		return d.Where("tenant = ?", db.tenant())
+	}
+}
+
+// tenant returns the tenant of db, falling back to the default one.
+func (db DB) tenant() string {
+	if db.Tenant == "" {
+		return defaultTenant
+	}
+	return db.Tenant
+}
+
 // UpdateCounter updates the value of a counter metric in the database.
 //
 // Parameters:
@@ -44,7 +61,8 @@ func TypeIsGauge(db *gorm.DB) *gorm.DB {
 func (db DB) UpdateCounter(name string, value int64, ok bool) error {
 	var m Metrics
 	if ok {
-		result := db.Database.Scopes(TypeIsCounter).Where("name = ?", name).Order("").First(&m)
+		result := db.Database.Scopes(TypeIsCounter, db.tenantScope()).
+			Where("name = ?", name).Order("").First(&m)
 		if result.Error != nil {
 			logger.Log.Error(fmt.Sprintf("%s: %v", ErrRetrieveMetric, result.Error))
 		}
@@ -61,9 +79,10 @@ func (db DB) UpdateCounter(name string, value int64, ok bool) error {
 	}
 	data := db.Database.Create(
 		&Metrics{
-			Name:  name,
-			Type:  config.Counter,
-			Delta: value,
+			Name:   name,
+			Type:   config.Counter,
+			Tenant: db.tenant(),
+			Delta:  value,
 		},
 	)
 	if data.Error != nil {
@@ -83,15 +102,16 @@ func (db DB) UpdateCounter(name string, value int64, ok bool) error {
 func (db DB) UpdateGauge(name string, value float64) error {
 	var m Metrics
 
-	if result := db.Database.Scopes(TypeIsGauge).Where(
+	if result := db.Database.Scopes(TypeIsGauge, db.tenantScope()).Where(
 		"name = ?",
 		name,
 	).Order("").First(&m); errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		data := db.Database.Create(
 			&Metrics{
-				Name:  name,
-				Type:  config.Gauge,
-				Value: value,
+				Name:   name,
+				Type:   config.Gauge,
+				Tenant: db.tenant(),
+				Value:  value,
 			},
 		)
 		if data.Error != nil {
@@ -119,7 +139,8 @@ func (db DB) UpdateGauge(name string, value float64) error {
 // - An error if the retrieval fails.
 func (db DB) GetCounter(name string) (int64, bool, error) {
 	var m Metrics
-	result := db.Database.Scopes(TypeIsCounter).Where("name = ?", name).Order("").First(&m)
+	result := db.Database.Scopes(TypeIsCounter, db.tenantScope()).
+		Where("name = ?", name).Order("").First(&m)
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return 0, false, nil
 	}
@@ -137,7 +158,8 @@ func (db DB) GetCounter(name string) (int64, bool, error) {
 // - An error if the retrieval fails.
 func (db DB) GetGauge(name string) (float64, bool, error) {
 	var m Metrics
-	result := db.Database.Scopes(TypeIsGauge).Where("name = ?", name).Order("").First(&m)
+	result := db.Database.Scopes(TypeIsGauge, db.tenantScope()).
+		Where("name = ?", name).Order("").First(&m)
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 		return 0, false, nil
 	}
@@ -160,8 +182,10 @@ func (db DB) GetMetrics() (map[string]int64, map[string]float64) {
 	}
 
 	var m filememory.MemStorage
-	db.Database.Table("metrics").Select("name, delta").Scopes(TypeIsCounter).Order("").Scan(&counterStruct)
-	db.Database.Table("metrics").Select("name, value").Scopes(TypeIsGauge).Order("").Scan(&gaugeStruct)
+	db.Database.Table("metrics").Select("name, delta").
+		Scopes(TypeIsCounter, db.tenantScope()).Order("").Scan(&counterStruct)
+	db.Database.Table("metrics").Select("name, value").
+		Scopes(TypeIsGauge, db.tenantScope()).Order("").Scan(&gaugeStruct)
 
 	m.Counter = make(map[string]int64)
 	m.Gauge = make(map[string]float64)
@@ -175,6 +199,99 @@ func (db DB) GetMetrics() (map[string]int64, map[string]float64) {
 	return m.Counter, m.Gauge
 }
 
+// ListMetrics implements storage.Lister. The metrics are selected, sorted
+// and paged by the database with keyset pagination, so that only the rows
+// of the page are read. IDs are compared byte by byte as in memory.
+// opts.Pattern is matched in Go, like by the other backends, on pages of
+// the rows selected by the other options.
+//
+// Parameters:
+// - opts: The selection, order and page of the metrics.
+//
+// Returns:
+// - The selected metrics in order.
+// - An error if the query fails.
+func (db DB) ListMetrics(opts storage.ListOptions) ([]formatter.Metric, error) {
+	pageSize := opts.Limit
+	if opts.Pattern != nil && pageSize < listScanSize {
+		pageSize = listScanSize
+	}
+	metrics := make([]formatter.Metric, 0, opts.Limit)
+	after := opts.After
+	for {
+		rows, err := db.listPage(opts, after, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if opts.Pattern != nil && !opts.Pattern.MatchString(row.Name) {
+				continue
+			}
+			metrics = append(metrics, metricOf(row))
+			if len(metrics) == opts.Limit {
+				return metrics, nil
+			}
+		}
+		if pageSize == 0 || len(rows) < pageSize {
+			return metrics, nil
+		}
+		last := rows[len(rows)-1]
+		after = &storage.Key{ID: last.Name, MType: last.Type}
+	}
+}
+
+// listScanSize is the number of rows read at once when the rows are
+// filtered by a pattern.
+const listScanSize = 1000
+
+// listPage returns up to limit rows selected by opts, except its pattern,
+// that follow after, all of them if limit is 0.
+func (db DB) listPage(opts storage.ListOptions, after *storage.Key, limit int) ([]Metrics, error) {
+	query := db.Database.Table("metrics").Select("name, type, delta, value").Scopes(db.tenantScope())
+	if opts.Type != "" {
+		query = query.Where("type = ?", opts.Type)
+	}
+	if opts.Prefix != "" {
+		query = query.Where(`name COLLATE "C" LIKE ?`, likeEscaper.Replace(opts.Prefix)+"%")
+	}
+	order := `name COLLATE "C", type`
+	if opts.Descending {
+		order = `name COLLATE "C" DESC, type DESC`
+	}
+	if after != nil {
+		cmp := ">"
+		if opts.Descending {
+			cmp = "<"
+		}
+		query = query.Where(`(name COLLATE "C", type) `+cmp+" (?, ?)", after.ID, after.MType)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var rows []Metrics
+	if err := query.Order(order).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("%s: %v", ErrRetrieveMetric, err)
+	}
+	return rows, nil
+}
+
+// metricOf returns the metric stored in row.
+func metricOf(row Metrics) formatter.Metric {
+	m := formatter.Metric{ID: row.Name, MType: row.Type}
+	if row.Type == config.Counter {
+		delta := row.Delta
+		m.Delta = &delta
+	} else {
+		value := row.Value
+		m.Value = &value
+	}
+	return m
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // typeCondition is a helper function that returns a GORM scope function
 // based on the metric type.
 //
@@ -213,7 +330,8 @@ func (db DB) InsertBatchMetrics(metrics []formatter.Metric) error {
 
 	for _, param := range metrics {
 		var m Metrics
-		result := tx.Scopes(typeCondition(param)).Where("name = ?", param.ID).Order("").First(&m)
+		result := tx.Scopes(typeCondition(param), db.tenantScope()).
+			Where("name = ?", param.ID).Order("").First(&m)
 		if result.Error != nil && !errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			logger.Log.Error(fmt.Sprintf("error finding metric: %v", result.Error))
 			return result.Error
@@ -225,17 +343,19 @@ func (db DB) InsertBatchMetrics(metrics []formatter.Metric) error {
 			case config.Counter:
 				data = tx.Create(
 					&Metrics{
-						Name:  param.ID,
-						Type:  config.Counter,
-						Delta: *param.Delta,
+						Name:   param.ID,
+						Type:   config.Counter,
+						Tenant: db.tenant(),
+						Delta:  *param.Delta,
 					},
 				)
 			case config.Gauge:
 				data = tx.Create(
 					&Metrics{
-						Name:  param.ID,
-						Type:  config.Gauge,
-						Value: *param.Value,
+						Name:   param.ID,
+						Type:   config.Gauge,
+						Tenant: db.tenant(),
+						Value:  *param.Value,
 					},
 				)
 			}
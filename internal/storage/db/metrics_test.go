@@ -0,0 +1,159 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// fakeConnector is a database/sql connector that records the queries it
+// receives and answers them with the next of its pages of rows.
+type fakeConnector struct {
+	mu      sync.Mutex
+	pages   [][]Metrics
+	queries []string
+	args    [][]driver.Value
+}
+
+func (c *fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{c: c}, nil
+}
+
+func (c *fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+type fakeConn struct {
+	c *fakeConnector
+}
+
+func (conn fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return fakeStmt{c: conn.c, query: query}, nil
+}
+
+func (conn fakeConn) Close() error {
+	return nil
+}
+
+func (conn fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	c     *fakeConnector
+	query string
+}
+
+func (s fakeStmt) Close() error {
+	return nil
+}
+
+func (s fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec is not supported")
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	s.c.queries = append(s.c.queries, s.query)
+	s.c.args = append(s.c.args, args)
+	if len(s.c.pages) == 0 {
+		return &fakeRows{}, nil
+	}
+	page := s.c.pages[0]
+	s.c.pages = s.c.pages[1:]
+	return &fakeRows{rows: page}, nil
+}
+
+type fakeRows struct {
+	rows []Metrics
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"name", "type", "delta", "value"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	dest[0], dest[1], dest[2], dest[3] = row.Name, row.Type, row.Delta, row.Value
+	return nil
+}
+
+func newFakeDB(t *testing.T, pages ...[]Metrics) (*DB, *fakeConnector) {
+	c := &fakeConnector{pages: pages}
+	database, err := gorm.Open(
+		postgres.New(postgres.Config{Conn: sql.OpenDB(c)}), &gorm.Config{DisableAutomaticPing: true},
+	)
+	require.NoError(t, err)
+	return &DB{Database: database, Tenant: defaultTenant}, c
+}
+
+// gauges returns rows of the gauges name0000 to name<n-1>, starting at from.
+func gauges(from, n int) []Metrics {
+	rows := make([]Metrics, 0, n)
+	for i := from; i < from+n; i++ {
+		rows = append(rows, Metrics{Name: fmt.Sprintf("name%04d", i), Type: config.Gauge, Value: float64(i)})
+	}
+	return rows
+}
+
+func TestListMetrics(t *testing.T) {
+	db, c := newFakeDB(t, gauges(0, 3))
+	metrics, err := db.ListMetrics(
+		storage.ListOptions{Type: config.Gauge, Prefix: "name_", Limit: 3, Descending: true},
+	)
+	require.NoError(t, err)
+	require.Len(t, metrics, 3)
+	assert.Equal(t, "name0002", metrics[2].ID)
+	assert.Equal(t, 2.0, *metrics[2].Value)
+
+	require.Len(t, c.queries, 1)
+	assert.Contains(t, c.queries[0], `name COLLATE "C" LIKE`)
+	assert.Contains(t, c.queries[0], `ORDER BY name COLLATE "C" DESC, type DESC LIMIT 3`)
+	assert.Equal(t, []driver.Value{config.Gauge, `name\_%`, defaultTenant}, c.args[0])
+}
+
+func TestListMetricsPattern(t *testing.T) {
+	// Named groups are valid in Go but not in PostgreSQL regular expressions.
+	pattern := regexp.MustCompile(`^name\d{3}(?P<last>0)$`)
+	db, c := newFakeDB(t, gauges(0, listScanSize), gauges(listScanSize, 500))
+
+	metrics, err := db.ListMetrics(storage.ListOptions{Pattern: pattern, Limit: 120})
+	require.NoError(t, err)
+	require.Len(t, metrics, 120)
+	assert.Equal(t, "name0000", metrics[0].ID)
+	assert.Equal(t, "name1190", metrics[119].ID)
+
+	require.Len(t, c.queries, 2)
+	for _, query := range c.queries {
+		assert.False(t, strings.Contains(query, "~"), query)
+		assert.Contains(t, query, fmt.Sprintf("LIMIT %d", listScanSize))
+	}
+	assert.Equal(t, []driver.Value{"name0999", config.Gauge, defaultTenant}, c.args[1])
+}
@@ -2,10 +2,18 @@ package db
 
 import "github.com/jinzhu/gorm"
 
+// Metrics is a row of the metrics table. A metric is identified by its
+// tenant, type and name, so tenants may use the same names.
 type Metrics struct {
 	gorm.Model
-	Name  string `gorm:"unique_index"`
-	Type  string
-	Delta int64
-	Value float64 `gorm:"type:double precision"`
+	Name   string `gorm:"uniqueIndex:idx_tenant_type_name,priority:3"`
+	Type   string `gorm:"uniqueIndex:idx_tenant_type_name,priority:2"`
+	Delta  int64
+	Value  float64 `gorm:"type:double precision"`
+	Tenant string  `gorm:"uniqueIndex:idx_tenant_type_name,priority:1;not null;default:'default'"`
 }
+
+// legacyNameIndexes are the unique indexes and constraints on the metric
+// name alone created by earlier schemas, which reject the same name in
+// different tenants.
+var legacyNameIndexes = []string{"uix_metrics_name", "idx_metrics_name", "metrics_name_key"}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/cache"
+	"github.com/goccy/go-json"
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// notifyChannel is the LISTEN/NOTIFY channel used for cache invalidation.
+const notifyChannel = "metrics_cache"
+
+// maxNotifyPayload keeps NOTIFY payloads below the Postgres limit of 8000 bytes.
+const maxNotifyPayload = 7000
+
+// notification is the payload of a NOTIFY on notifyChannel.
+type notification struct {
+	Source string        `json:"source"`
+	Tenant string        `json:"tenant"`
+	Events []cache.Event `json:"events"`
+}
+
+// Notifier delivers cache invalidations between server instances sharing
+// one database through Postgres LISTEN/NOTIFY.
+type Notifier struct {
+	dsn      string
+	database *gorm.DB
+	source   string
+	start    sync.Once
+
+	mu          sync.RWMutex
+	subscribers map[string][]func(cache.Event)
+}
+
+func newNotifier(dsn string, database *gorm.DB) *Notifier {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	return &Notifier{
+		dsn:         dsn,
+		database:    database,
+		source:      hex.EncodeToString(id),
+		subscribers: make(map[string][]func(cache.Event)),
+	}
+}
+
+// CacheBus returns the invalidation bus of the tenant of db.
+func (db DB) CacheBus() cache.Bus {
+	if db.notifier == nil {
+		return nil
+	}
+	return &tenantBus{notifier: db.notifier, tenant: db.tenant()}
+}
+
+// tenantBus is the cache.Bus of a single tenant.
+type tenantBus struct {
+	notifier *Notifier
+	tenant   string
+}
+
+// Publish sends events to the other server instances, splitting them into
+// several notifications when they do not fit into one payload.
+func (b *tenantBus) Publish(events []cache.Event) error {
+	for len(events) > 0 {
+		n := len(events)
+		var payload []byte
+		for {
+			var err error
+			payload, err = json.Marshal(
+				notification{Source: b.notifier.source, Tenant: b.tenant, Events: events[:n]},
+			)
+			if err != nil {
+				return err
+			}
+			if len(payload) <= maxNotifyPayload || n == 1 {
+				break
+			}
+			n /= 2
+		}
+		result := b.notifier.database.Exec("SELECT pg_notify(?, ?)", notifyChannel, string(payload))
+		if result.Error != nil {
+			return result.Error
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+// Subscribe registers fn for events of the tenant published by other
+// instances and starts listening on first use.
+func (b *tenantBus) Subscribe(fn func(cache.Event)) {
+	n := b.notifier
+	n.mu.Lock()
+	n.subscribers[b.tenant] = append(n.subscribers[b.tenant], fn)
+	n.mu.Unlock()
+	n.start.Do(
+		func() {
+			go n.listen(context.Background())
+		},
+	)
+}
+
+// listen receives notifications on a dedicated connection and reconnects
+// after failures. Every reconnect drops all caches, since notifications
+// sent in between are lost.
+func (n *Notifier) listen(ctx context.Context) {
+	for {
+		if err := n.receive(ctx); err != nil {
+			logger.Log.Error(fmt.Sprintf("cache invalidation listener: %v", err))
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		n.dispatchAll(cache.Event{})
+		time.Sleep(5 * time.Second)
+	}
+}
+
+func (n *Notifier) receive(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, n.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{notifyChannel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		msg, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var payload notification
+		if err := json.Unmarshal([]byte(msg.Payload), &payload); err != nil {
+			logger.Log.Error(fmt.Sprintf("invalid cache invalidation payload: %v", err))
+			continue
+		}
+		if payload.Source == n.source {
+			continue
+		}
+		n.dispatch(payload.Tenant, payload.Events)
+	}
+}
+
+func (n *Notifier) dispatch(tenant string, events []cache.Event) {
+	n.mu.RLock()
+	subscribers := n.subscribers[tenant]
+	n.mu.RUnlock()
+	for _, fn := range subscribers {
+		for _, e := range events {
+			fn(e)
+		}
+	}
+}
+
+func (n *Notifier) dispatchAll(e cache.Event) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	for _, subscribers := range n.subscribers {
+		for _, fn := range subscribers {
+			fn(e)
+		}
+	}
+}
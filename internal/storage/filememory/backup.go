@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
 	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
 	"github.com/goccy/go-json"
 )
@@ -28,7 +29,8 @@ func (e BackupError) Error() string {
 // Parameters:
 // - fileName: The name of the file where the backup data will be stored.
 func (s *MemStorage) backup(fileName string) {
-	combinedData := generateCombinedData(s)
+	counter, gauge := s.GetMetrics()
+	combinedData := map[string]interface{}{config.Gauge: gauge, config.Counter: counter}
 	data, err := json.MarshalIndent(combinedData, "", "   ")
 	if err != nil {
 		logger.Log.Error(BackupError{Err: err, Message: "failed to marshal data"}.Error())
@@ -46,3 +48,11 @@ func (s *MemStorage) backup(fileName string) {
 		logger.Log.Error(BackupError{Err: err, Message: "failed to write data"}.Error())
 	}
 }
+
+// Flush writes the metrics to the backup file right away instead of waiting
+// for the next store interval. It does nothing without a backup file.
+func (s *MemStorage) Flush() {
+	if s.path != "" {
+		s.backup(s.path)
+	}
+}
@@ -2,13 +2,14 @@
 package filememory
 
 import (
-	"errors"
 	"fmt"
+	"maps"
 	"sync"
 	"time"
 
 	"github.com/elina-chertova/metrics-alerting.git/internal/config"
 	"github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
 )
 
 // MemStorage represents an in-memory storage structure for metrics data.
@@ -17,6 +18,7 @@ type MemStorage struct {
 	Gauge     map[string]float64 `json:"gauge"`
 	CounterMu sync.Mutex
 	Counter   map[string]int64 `json:"counter"`
+	path      string
 }
 
 // NewMemStorage initializes a new instance of MemStorage. It optionally loads existing data
@@ -34,6 +36,7 @@ func NewMemStorage(serverConfigEnable bool, configuration *config.Server) *MemSt
 		Counter: make(map[string]int64),
 	}
 	if serverConfigEnable {
+		s.path = configuration.FileStoragePath
 		if configuration.FlagRestore {
 			s.load(configuration.FileStoragePath)
 		}
@@ -99,16 +102,19 @@ func (s *MemStorage) GetGauge(name string) (float64, bool, error) {
 	return value, ok, nil
 }
 
-// GetMetrics returns all stored counter and gauge metrics.
+// GetMetrics returns copies of all stored counter and gauge metrics, so
+// that callers can range over them while the storage is being updated.
 func (s *MemStorage) GetMetrics() (map[string]int64, map[string]float64) {
 	s.lockGauge()
 	s.lockCounter()
 	defer s.unlockGauge()
 	defer s.unlockCounter()
-	return s.Counter, s.Gauge
+	return maps.Clone(s.Counter), maps.Clone(s.Gauge)
 }
 
-var ErrNotAllowed = errors.New("method not allowed")
+// ErrNotAllowed is returned for batch inserts; use storage.InsertBatch to
+// insert batches into a MemStorage one metric at a time.
+var ErrNotAllowed = fmt.Errorf("method not allowed: %w", storage.ErrBatchUnsupported)
 
 func (s *MemStorage) InsertBatchMetrics(metrics []formatter.Metric) error {
 	return fmt.Errorf("%w", ErrNotAllowed)
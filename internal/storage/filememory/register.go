@@ -0,0 +1,47 @@
+package filememory
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+)
+
+func init() {
+	storage.Register(openMemory, "memory")
+	storage.Register(openFile, "file")
+}
+
+// openMemory creates a MemStorage without a backup file for memory:// URLs.
+func openMemory(_ *url.URL, _ *config.Server) (serviceInterface.MetricsStorage, error) {
+	return NewMemStorage(false, nil), nil
+}
+
+// openFile creates a MemStorage backed up to the file of a file:// URL.
+// The "interval" and "restore" query parameters override the server settings.
+func openFile(u *url.URL, cfg *config.Server) (serviceInterface.MetricsStorage, error) {
+	fileConfig := config.Server{StoreInterval: 300, FlagRestore: true}
+	if cfg != nil {
+		fileConfig = *cfg
+	}
+	fileConfig.FileStoragePath = storage.Path(u)
+
+	query := u.Query()
+	if interval := query.Get("interval"); interval != "" {
+		seconds, err := strconv.Atoi(interval)
+		if err != nil {
+			return nil, err
+		}
+		fileConfig.StoreInterval = seconds
+	}
+	if restore := query.Get("restore"); restore != "" {
+		value, err := strconv.ParseBool(restore)
+		if err != nil {
+			return nil, err
+		}
+		fileConfig.FlagRestore = value
+	}
+	return NewMemStorage(true, &fileConfig), nil
+}
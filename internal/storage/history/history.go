@@ -0,0 +1,191 @@
+// Package history provides a storage decorator that keeps the recent
+// values of every metric in memory, so that they can be queried over a
+// time range. The wrapped storage still only holds the current values.
+//
+// Only writes going through the decorator are recorded: when several
+// server instances share one backend, each one sees its own writes.
+package history
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+)
+
+// MaxSamples limits the number of samples kept per series regardless of
+// the retention, the oldest samples are dropped first.
+const MaxSamples = 10000
+
+// Sample is the value of a metric at a point in time. Counters are
+// recorded with their total after the update.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+type key struct {
+	mType string
+	id    string
+}
+
+// Storage wraps a MetricsStorage and records a sample for every update.
+type Storage struct {
+	serviceInterface.MetricsStorage
+	retention time.Duration
+	now       func() time.Time
+
+	mu     sync.RWMutex
+	series map[key][]Sample
+}
+
+// New wraps st, keeping samples for retention.
+func New(st serviceInterface.MetricsStorage, retention time.Duration) *Storage {
+	return &Storage{
+		MetricsStorage: st,
+		retention:      retention,
+		now:            time.Now,
+		series:         make(map[key][]Sample),
+	}
+}
+
+// Unwrap returns the wrapped storage.
+func (s *Storage) Unwrap() serviceInterface.MetricsStorage {
+	return s.MetricsStorage
+}
+
+// Find returns the history of st, looking through its decorators, or nil
+// if the history is not enabled.
+func Find(st serviceInterface.MetricsStorage) *Storage {
+	for st != nil {
+		if h, ok := st.(*Storage); ok {
+			return h
+		}
+		u, ok := st.(interface {
+			Unwrap() serviceInterface.MetricsStorage
+		})
+		if !ok {
+			return nil
+		}
+		st = u.Unwrap()
+	}
+	return nil
+}
+
+// Retention returns how long samples are kept.
+func (s *Storage) Retention() time.Duration {
+	return s.retention
+}
+
+func (s *Storage) UpdateCounter(name string, value int64, ok bool) error {
+	if err := s.MetricsStorage.UpdateCounter(name, value, ok); err != nil {
+		return err
+	}
+	return s.recordCounter(name)
+}
+
+func (s *Storage) UpdateGauge(name string, value float64) error {
+	if err := s.MetricsStorage.UpdateGauge(name, value); err != nil {
+		return err
+	}
+	s.record(config.Gauge, name, value)
+	return nil
+}
+
+func (s *Storage) InsertBatchMetrics(metrics []f.Metric) error {
+	if err := s.MetricsStorage.InsertBatchMetrics(metrics); err != nil {
+		return err
+	}
+	counters := make(map[string]bool)
+	for _, m := range metrics {
+		switch {
+		case m.MType == config.Counter:
+			counters[m.ID] = true
+		case m.MType == config.Gauge && m.Value != nil:
+			s.record(config.Gauge, m.ID, *m.Value)
+		}
+	}
+	for name := range counters {
+		if err := s.recordCounter(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Storage) recordCounter(name string) error {
+	value, _, err := s.MetricsStorage.GetCounter(name)
+	if err != nil {
+		return err
+	}
+	s.record(config.Counter, name, float64(value))
+	return nil
+}
+
+func (s *Storage) record(mType, id string, value float64) {
+	now := s.now()
+	k := key{mType: mType, id: id}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.series[k] = s.trim(append(s.series[k], Sample{Time: now, Value: value}), now)
+}
+
+// Restore merges samples of a metric, for example read from an export,
+// into its history. Samples outside the retention are dropped.
+func (s *Storage) Restore(mType, id string, samples []Sample) {
+	now := s.now()
+	k := key{mType: mType, id: id}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged := append(append([]Sample(nil), s.series[k]...), samples...)
+	sort.SliceStable(
+		merged, func(i, j int) bool {
+			return merged[i].Time.Before(merged[j].Time)
+		},
+	)
+	s.series[k] = s.trim(merged, now)
+}
+
+// trim drops the samples older than the retention and the oldest samples
+// beyond MaxSamples.
+func (s *Storage) trim(samples []Sample, now time.Time) []Sample {
+	drop := sort.Search(
+		len(samples), func(i int) bool {
+			return !samples[i].Time.Before(now.Add(-s.retention))
+		},
+	)
+	if n := len(samples) - MaxSamples; n > drop {
+		drop = n
+	}
+	if drop > 0 {
+		samples = append([]Sample(nil), samples[drop:]...)
+	}
+	return samples
+}
+
+// Range returns the samples of a metric recorded between from and to,
+// both inclusive, oldest first.
+func (s *Storage) Range(mType, id string, from, to time.Time) []Sample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	samples := s.series[key{mType: mType, id: id}]
+	start := sort.Search(
+		len(samples), func(i int) bool {
+			return !samples[i].Time.Before(from)
+		},
+	)
+	end := sort.Search(
+		len(samples), func(i int) bool {
+			return samples[i].Time.After(to)
+		},
+	)
+	if start >= end {
+		return nil
+	}
+	return append([]Sample(nil), samples[start:end]...)
+}
@@ -0,0 +1,85 @@
+package history
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	base := time.Unix(1000, 0)
+	now := base
+	h := New(filememory.NewMemStorage(false, nil), time.Minute)
+	h.now = func() time.Time { return now }
+
+	require.NoError(t, h.UpdateGauge("Alloc", 1))
+	require.NoError(t, h.UpdateCounter("PollCount", 2, false))
+	now = now.Add(30 * time.Second)
+	require.NoError(t, h.UpdateGauge("Alloc", 2))
+	require.NoError(t, h.UpdateCounter("PollCount", 3, true))
+	now = now.Add(45 * time.Second)
+	delta := int64(5)
+	value := 3.0
+	// MemStorage has no batch support, the fallback updates one by one.
+	require.NoError(
+		t, storage.InsertBatch(
+			h, []f.Metric{
+				{ID: "Alloc", MType: config.Gauge, Value: &value},
+				{ID: "PollCount", MType: config.Counter, Delta: &delta},
+			},
+		),
+	)
+
+	// The first samples are older than the retention.
+	assert.Equal(
+		t, []Sample{{Time: base.Add(30 * time.Second), Value: 2}, {Time: now, Value: 3}},
+		h.Range(config.Gauge, "Alloc", base, now),
+	)
+	assert.Equal(
+		t, []Sample{{Time: base.Add(30 * time.Second), Value: 5}},
+		h.Range(config.Counter, "PollCount", base, base.Add(time.Minute)),
+	)
+	assert.Equal(t, []Sample{{Time: now, Value: 10}}, h.Range(config.Counter, "PollCount", now, now))
+	assert.Empty(t, h.Range(config.Counter, "Alloc", base, now))
+}
+
+func TestMaxSamples(t *testing.T) {
+	h := New(filememory.NewMemStorage(false, nil), time.Hour)
+	for i := 0; i < MaxSamples+10; i++ {
+		require.NoError(t, h.UpdateGauge("Alloc", float64(i)))
+	}
+	samples := h.Range(config.Gauge, "Alloc", time.Time{}, time.Now())
+	require.Len(t, samples, MaxSamples)
+	assert.Equal(t, float64(10), samples[0].Value)
+}
+
+func TestFind(t *testing.T) {
+	h := New(filememory.NewMemStorage(false, nil), time.Hour)
+	assert.Same(t, h, Find(metadata.NewStorage(h, metadata.NewRegistry(""))))
+	assert.Nil(t, Find(filememory.NewMemStorage(false, nil)))
+}
+
+func TestRestore(t *testing.T) {
+	base := time.Unix(1000, 0)
+	h := New(filememory.NewMemStorage(false, nil), time.Minute)
+	h.now = func() time.Time { return base }
+
+	require.NoError(t, h.UpdateGauge("Alloc", 2))
+	h.Restore(
+		config.Gauge, "Alloc", []Sample{
+			{Time: base.Add(-2 * time.Minute), Value: 0},
+			{Time: base.Add(-20 * time.Second), Value: 1},
+		},
+	)
+	assert.Equal(
+		t, []Sample{{Time: base.Add(-20 * time.Second), Value: 1}, {Time: base, Value: 2}},
+		h.Range(config.Gauge, "Alloc", time.Time{}, base),
+	)
+}
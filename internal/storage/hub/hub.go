@@ -0,0 +1,174 @@
+// Package hub provides a storage decorator that publishes every stored
+// metric update to in-process subscribers, so that live consoles and
+// downstream processors can follow the updates instead of polling.
+package hub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+)
+
+// BufferSize is the number of updates buffered per subscriber. Updates for
+// a subscriber whose buffer is full are dropped.
+const BufferSize = 256
+
+// Update is a stored metric update. For counters Delta is the increment
+// and Value the total after the update; for gauges Value is the new value.
+type Update struct {
+	Type  string
+	ID    string
+	Delta int64
+	Value float64
+	Time  time.Time
+}
+
+// Subscription receives the updates selected by its filter on C until it
+// is closed.
+type Subscription struct {
+	C <-chan Update
+
+	hub    *Hub
+	ch     chan Update
+	filter func(Update) bool
+}
+
+// Close ends the subscription and closes C.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	if _, ok := s.hub.subs[s]; ok {
+		delete(s.hub.subs, s)
+		close(s.ch)
+	}
+}
+
+// Hub wraps a MetricsStorage and publishes its successful writes.
+type Hub struct {
+	serviceInterface.MetricsStorage
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// New wraps st with a hub.
+func New(st serviceInterface.MetricsStorage) *Hub {
+	return &Hub{MetricsStorage: st, subs: make(map[*Subscription]struct{})}
+}
+
+// Unwrap returns the wrapped storage.
+func (h *Hub) Unwrap() serviceInterface.MetricsStorage {
+	return h.MetricsStorage
+}
+
+// Find returns the hub of st, looking through its decorators, or nil if
+// there is none.
+func Find(st serviceInterface.MetricsStorage) *Hub {
+	for st != nil {
+		if h, ok := st.(*Hub); ok {
+			return h
+		}
+		u, ok := st.(interface {
+			Unwrap() serviceInterface.MetricsStorage
+		})
+		if !ok {
+			return nil
+		}
+		st = u.Unwrap()
+	}
+	return nil
+}
+
+// Subscribe subscribes to the updates filter returns true for, or to all
+// updates if filter is nil.
+func (h *Hub) Subscribe(filter func(Update) bool) *Subscription {
+	ch := make(chan Update, BufferSize)
+	s := &Subscription{C: ch, hub: h, ch: ch, filter: filter}
+	h.mu.Lock()
+	h.subs[s] = struct{}{}
+	h.mu.Unlock()
+	return s
+}
+
+func (h *Hub) subscribed() bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs) > 0
+}
+
+func (h *Hub) publish(updates ...Update) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, u := range updates {
+		for s := range h.subs {
+			if s.filter != nil && !s.filter(u) {
+				continue
+			}
+			select {
+			case s.ch <- u:
+			default:
+			}
+		}
+	}
+}
+
+func (h *Hub) UpdateCounter(name string, value int64, ok bool) error {
+	if err := h.MetricsStorage.UpdateCounter(name, value, ok); err != nil {
+		return err
+	}
+	if !h.subscribed() {
+		return nil
+	}
+	total, _, err := h.MetricsStorage.GetCounter(name)
+	if err != nil {
+		return err
+	}
+	h.publish(
+		Update{Type: config.Counter, ID: name, Delta: value, Value: float64(total), Time: time.Now()},
+	)
+	return nil
+}
+
+func (h *Hub) UpdateGauge(name string, value float64) error {
+	if err := h.MetricsStorage.UpdateGauge(name, value); err != nil {
+		return err
+	}
+	h.publish(Update{Type: config.Gauge, ID: name, Value: value, Time: time.Now()})
+	return nil
+}
+
+func (h *Hub) InsertBatchMetrics(metrics []f.Metric) error {
+	if err := h.MetricsStorage.InsertBatchMetrics(metrics); err != nil {
+		return err
+	}
+	if !h.subscribed() {
+		return nil
+	}
+	now := time.Now()
+	totals := make(map[string]int64)
+	updates := make([]Update, 0, len(metrics))
+	for _, m := range metrics {
+		switch {
+		case m.MType == config.Counter && m.Delta != nil:
+			total, ok := totals[m.ID]
+			if !ok {
+				var err error
+				if total, _, err = h.MetricsStorage.GetCounter(m.ID); err != nil {
+					return err
+				}
+				totals[m.ID] = total
+			}
+			updates = append(
+				updates,
+				Update{Type: config.Counter, ID: m.ID, Delta: *m.Delta, Value: float64(total), Time: now},
+			)
+		case m.MType == config.Gauge && m.Value != nil:
+			updates = append(updates, Update{Type: config.Gauge, ID: m.ID, Value: *m.Value, Time: now})
+		}
+	}
+	h.publish(updates...)
+	return nil
+}
@@ -0,0 +1,85 @@
+package hub
+
+import (
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/metadata"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(sub *Subscription) []Update {
+	var updates []Update
+	for {
+		select {
+		case u := <-sub.C:
+			updates = append(updates, Update{Type: u.Type, ID: u.ID, Delta: u.Delta, Value: u.Value})
+		default:
+			return updates
+		}
+	}
+}
+
+func TestHub(t *testing.T) {
+	h := New(filememory.NewMemStorage(false, nil))
+	all := h.Subscribe(nil)
+	counters := h.Subscribe(
+		func(u Update) bool {
+			return u.Type == config.Counter
+		},
+	)
+
+	require.NoError(t, h.UpdateGauge("Alloc", 1.5))
+	require.NoError(t, h.UpdateCounter("PollCount", 2, false))
+	delta := int64(3)
+	value := 2.5
+	require.NoError(
+		t, storage.InsertBatch(
+			h, []f.Metric{
+				{ID: "PollCount", MType: config.Counter, Delta: &delta},
+				{ID: "Alloc", MType: config.Gauge, Value: &value},
+			},
+		),
+	)
+
+	assert.Equal(
+		t, []Update{
+			{Type: config.Gauge, ID: "Alloc", Value: 1.5},
+			{Type: config.Counter, ID: "PollCount", Delta: 2, Value: 2},
+			{Type: config.Counter, ID: "PollCount", Delta: 3, Value: 5},
+			{Type: config.Gauge, ID: "Alloc", Value: 2.5},
+		}, receive(all),
+	)
+	assert.Equal(
+		t, []Update{
+			{Type: config.Counter, ID: "PollCount", Delta: 2, Value: 2},
+			{Type: config.Counter, ID: "PollCount", Delta: 3, Value: 5},
+		}, receive(counters),
+	)
+
+	all.Close()
+	all.Close()
+	_, open := <-all.C
+	assert.False(t, open)
+	require.NoError(t, h.UpdateGauge("Alloc", 3))
+	assert.Len(t, receive(counters), 0)
+}
+
+func TestHubDropsWhenFull(t *testing.T) {
+	h := New(filememory.NewMemStorage(false, nil))
+	sub := h.Subscribe(nil)
+	for i := 0; i < BufferSize+10; i++ {
+		require.NoError(t, h.UpdateGauge("Alloc", float64(i)))
+	}
+	assert.Len(t, receive(sub), BufferSize)
+}
+
+func TestFind(t *testing.T) {
+	h := New(filememory.NewMemStorage(false, nil))
+	assert.Same(t, h, Find(metadata.NewStorage(h, metadata.NewRegistry(""))))
+	assert.Nil(t, Find(filememory.NewMemStorage(false, nil)))
+}
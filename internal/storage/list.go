@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+)
+
+// Key identifies a metric in a listing. Metrics are listed in the order of
+// their ID and then of their type.
+type Key struct {
+	ID    string
+	MType string
+}
+
+// Less reports whether k is listed before other in ascending order.
+func (k Key) Less(other Key) bool {
+	if k.ID != other.ID {
+		return k.ID < other.ID
+	}
+	return k.MType < other.MType
+}
+
+// ListOptions selects the metrics returned by List.
+type ListOptions struct {
+	// Type restricts the listing to counters or gauges.
+	Type string
+	// Prefix and Pattern restrict the listing to the IDs starting with
+	// Prefix and matching Pattern.
+	Prefix  string
+	Pattern *regexp.Regexp
+	// Descending lists the metrics in descending order.
+	Descending bool
+	// After continues a listing after the metric with this key.
+	After *Key
+	// Limit is the maximum number of metrics returned, all of them if 0.
+	Limit int
+}
+
+// Match reports whether the metric with key k is selected by opts,
+// ignoring After and Limit.
+func (opts ListOptions) Match(k Key) bool {
+	return (opts.Type == "" || opts.Type == k.MType) &&
+		strings.HasPrefix(k.ID, opts.Prefix) &&
+		(opts.Pattern == nil || opts.Pattern.MatchString(k.ID))
+}
+
+// Lister is implemented by backends that select, sort and page metrics
+// themselves instead of loading all of them.
+type Lister interface {
+	ListMetrics(opts ListOptions) ([]f.Metric, error)
+}
+
+// List returns the metrics of st selected by opts. The first Lister found
+// through the decorators of st serves the listing; for other backends the
+// metrics are filtered and sorted in memory.
+func List(st serviceInterface.MetricsStorage, opts ListOptions) ([]f.Metric, error) {
+	for s := st; s != nil; {
+		if l, ok := s.(Lister); ok {
+			return l.ListMetrics(opts)
+		}
+		u, ok := s.(interface {
+			Unwrap() serviceInterface.MetricsStorage
+		})
+		if !ok {
+			break
+		}
+		s = u.Unwrap()
+	}
+
+	counters, gauges := st.GetMetrics()
+	var metrics []f.Metric
+	for id, delta := range counters {
+		if selected(opts, Key{ID: id, MType: config.Counter}) {
+			delta := delta
+			metrics = append(metrics, f.Metric{ID: id, MType: config.Counter, Delta: &delta})
+		}
+	}
+	for id, value := range gauges {
+		if selected(opts, Key{ID: id, MType: config.Gauge}) {
+			value := value
+			metrics = append(metrics, f.Metric{ID: id, MType: config.Gauge, Value: &value})
+		}
+	}
+	sort.Slice(
+		metrics, func(i, j int) bool {
+			a, b := Key{metrics[i].ID, metrics[i].MType}, Key{metrics[j].ID, metrics[j].MType}
+			if opts.Descending {
+				return b.Less(a)
+			}
+			return a.Less(b)
+		},
+	)
+	if opts.Limit > 0 && len(metrics) > opts.Limit {
+		metrics = metrics[:opts.Limit]
+	}
+	return metrics, nil
+}
+
+// selected reports whether the metric with key k is selected by opts and
+// listed after opts.After.
+func selected(opts ListOptions, k Key) bool {
+	if !opts.Match(k) {
+		return false
+	}
+	switch {
+	case opts.After == nil:
+		return true
+	case opts.Descending:
+		return k.Less(*opts.After)
+	default:
+		return opts.After.Less(k)
+	}
+}
@@ -0,0 +1,115 @@
+package storage_test
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lister records the options of the listings it serves.
+type lister struct {
+	*filememory.MemStorage
+	opts storage.ListOptions
+}
+
+func (l *lister) ListMetrics(opts storage.ListOptions) ([]formatter.Metric, error) {
+	l.opts = opts
+	return nil, nil
+}
+
+func TestList(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	require.NoError(t, st.UpdateGauge("Alloc", 1))
+	require.NoError(t, st.UpdateGauge("HeapAlloc", 2))
+	require.NoError(t, st.UpdateGauge("PollCount", 3))
+	require.NoError(t, st.UpdateCounter("PollCount", 4, false))
+	require.NoError(t, st.UpdateCounter("RandomValue", 5, false))
+
+	tests := []struct {
+		name     string
+		opts     storage.ListOptions
+		expected []string
+	}{
+		{
+			name: "All",
+			expected: []string{
+				"Alloc gauge", "HeapAlloc gauge", "PollCount counter", "PollCount gauge",
+				"RandomValue counter",
+			},
+		},
+		{
+			name:     "Type",
+			opts:     storage.ListOptions{Type: "counter"},
+			expected: []string{"PollCount counter", "RandomValue counter"},
+		},
+		{
+			name:     "Prefix And Pattern",
+			opts:     storage.ListOptions{Prefix: "P", Pattern: regexp.MustCompile("Count$"), Type: "gauge"},
+			expected: []string{"PollCount gauge"},
+		},
+		{
+			name:     "Descending Page",
+			opts:     storage.ListOptions{Descending: true, Limit: 2},
+			expected: []string{"RandomValue counter", "PollCount gauge"},
+		},
+		{
+			name:     "After",
+			opts:     storage.ListOptions{After: &storage.Key{ID: "PollCount", MType: "counter"}, Limit: 2},
+			expected: []string{"PollCount gauge", "RandomValue counter"},
+		},
+		{
+			name: "Descending After",
+			opts: storage.ListOptions{
+				Descending: true,
+				After:      &storage.Key{ID: "PollCount", MType: "gauge"},
+			},
+			expected: []string{"PollCount counter", "HeapAlloc gauge", "Alloc gauge"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				metrics, err := storage.List(st, tt.opts)
+				require.NoError(t, err)
+				keys := make([]string, 0, len(metrics))
+				for _, m := range metrics {
+					keys = append(keys, m.ID+" "+m.MType)
+				}
+				assert.Equal(t, tt.expected, keys)
+			},
+		)
+	}
+}
+
+func TestListUsesLister(t *testing.T) {
+	l := &lister{MemStorage: filememory.NewMemStorage(false, nil)}
+	opts := storage.ListOptions{Prefix: "Poll", Limit: 10}
+
+	_, err := storage.List(history.New(l, 0), opts)
+	require.NoError(t, err)
+	assert.Equal(t, opts, l.opts)
+}
+
+func TestListConcurrentWrites(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			assert.NoError(t, st.UpdateGauge(fmt.Sprintf("gauge%d", i), 1))
+			assert.NoError(t, st.UpdateCounter(fmt.Sprintf("counter%d", i), 1, false))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		_, err := storage.List(st, storage.ListOptions{Prefix: "gauge"})
+		require.NoError(t, err)
+	}
+	<-done
+}
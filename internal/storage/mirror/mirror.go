@@ -0,0 +1,264 @@
+// Package mirror provides a storage that writes to two backends at once and
+// helpers to copy and compare metrics between backends. It is used to move
+// metrics from one backend to another without losing data: copy the
+// existing metrics first, then run the server with both backends mirrored
+// until the secondary can take over.
+package mirror
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+)
+
+// Difference describes a metric that is not the same in both backends.
+// Missing values are nil.
+type Difference struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Primary   *float64 `json:"primary"`
+	Secondary *float64 `json:"secondary"`
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf(
+		"%s %s: primary %s, secondary %s",
+		d.Type, d.Name, format(d.Primary), format(d.Secondary),
+	)
+}
+
+// Storage writes every update to a primary and a secondary storage and
+// reads from the primary. Failed secondary writes do not fail the update;
+// they are logged and counted as divergence.
+type Storage struct {
+	serviceInterface.MetricsStorage
+	secondary serviceInterface.MetricsStorage
+
+	mu          sync.Mutex
+	writeErrors int
+}
+
+// New returns a Storage mirroring writes from primary to secondary.
+func New(primary, secondary serviceInterface.MetricsStorage) *Storage {
+	return &Storage{MetricsStorage: primary, secondary: secondary}
+}
+
+// Unwrap returns the primary storage.
+func (s *Storage) Unwrap() serviceInterface.MetricsStorage {
+	return s.MetricsStorage
+}
+
+// Secondary returns the storage that receives the mirrored writes.
+func (s *Storage) Secondary() serviceInterface.MetricsStorage {
+	return s.secondary
+}
+
+// WriteErrors returns the number of writes that failed on the secondary.
+func (s *Storage) WriteErrors() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeErrors
+}
+
+// Diff compares the metrics of the primary and the secondary storage.
+func (s *Storage) Diff() []Difference {
+	return Diff(s.MetricsStorage, s.secondary)
+}
+
+// UpdateCounter adds value to the counter in both storages. ok refers to
+// the primary; whether the counter exists in the secondary is checked there.
+func (s *Storage) UpdateCounter(name string, value int64, ok bool) error {
+	if err := s.MetricsStorage.UpdateCounter(name, value, ok); err != nil {
+		return err
+	}
+	s.report(addCounter(s.secondary, name, value), config.Counter, name)
+	return nil
+}
+
+// UpdateGauge sets the gauge in both storages.
+func (s *Storage) UpdateGauge(name string, value float64) error {
+	if err := s.MetricsStorage.UpdateGauge(name, value); err != nil {
+		return err
+	}
+	s.report(s.secondary.UpdateGauge(name, value), config.Gauge, name)
+	return nil
+}
+
+// InsertBatchMetrics inserts the batch into both storages. Secondaries
+// without batch support receive the metrics one by one.
+func (s *Storage) InsertBatchMetrics(metrics []f.Metric) error {
+	if err := s.MetricsStorage.InsertBatchMetrics(metrics); err != nil {
+		return err
+	}
+	err := storage.InsertBatch(s.secondary, metrics)
+	s.report(err, "batch", fmt.Sprintf("%d metrics", len(metrics)))
+	return nil
+}
+
+// Check compares both storages every interval and logs the differences
+// until stop is closed.
+func (s *Storage) Check(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+		diffs := s.Diff()
+		if len(diffs) == 0 {
+			continue
+		}
+		logger.Log.Warn(
+			fmt.Sprintf(
+				"mirror: %d metrics differ, %d failed secondary writes, first: %s",
+				len(diffs), s.WriteErrors(), diffs[0],
+			),
+		)
+	}
+}
+
+func (s *Storage) report(err error, mType string, name string) {
+	if err == nil {
+		return
+	}
+	s.mu.Lock()
+	s.writeErrors++
+	s.mu.Unlock()
+	logger.Log.Error(fmt.Sprintf("mirror: secondary write of %s %s failed: %v", mType, name, err))
+}
+
+// Copy writes all metrics of src to dst so that both hold the same values
+// afterwards. Counters are adjusted by the difference to the value already
+// in dst, so running Copy again does not count twice, and gauges already
+// holding the value are skipped. It returns the number of metrics written.
+func Copy(dst, src serviceInterface.MetricsStorage) (int, error) {
+	counters, gauges := src.GetMetrics()
+	n := 0
+	for _, name := range sortedKeys(counters) {
+		current, _, err := dst.GetCounter(name)
+		if err != nil {
+			return n, err
+		}
+		if current == counters[name] {
+			continue
+		}
+		if err := addCounter(dst, name, counters[name]-current); err != nil {
+			return n, err
+		}
+		n++
+	}
+	for _, name := range sortedKeys(gauges) {
+		current, ok, err := dst.GetGauge(name)
+		if err != nil {
+			return n, err
+		}
+		if ok && current == gauges[name] {
+			continue
+		}
+		if err := dst.UpdateGauge(name, gauges[name]); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Diff returns the metrics whose values differ between primary and
+// secondary, sorted by type and name.
+func Diff(primary, secondary serviceInterface.MetricsStorage) []Difference {
+	pc, pg := primary.GetMetrics()
+	sc, sg := secondary.GetMetrics()
+
+	var diffs []Difference
+	for _, name := range sortedKeys(union(pc, sc)) {
+		p, pok := pc[name]
+		s, sok := sc[name]
+		if pok != sok || p != s {
+			diffs = append(
+				diffs,
+				Difference{
+					Name:      name,
+					Type:      config.Counter,
+					Primary:   counterValue(p, pok),
+					Secondary: counterValue(s, sok),
+				},
+			)
+		}
+	}
+	for _, name := range sortedKeys(union(pg, sg)) {
+		p, pok := pg[name]
+		s, sok := sg[name]
+		if pok != sok || p != s {
+			diffs = append(
+				diffs,
+				Difference{
+					Name:      name,
+					Type:      config.Gauge,
+					Primary:   gaugeValue(p, pok),
+					Secondary: gaugeValue(s, sok),
+				},
+			)
+		}
+	}
+	return diffs
+}
+
+// addCounter adds delta to the counter, creating it when st does not have it yet.
+func addCounter(st serviceInterface.MetricsStorage, name string, delta int64) error {
+	_, ok, err := st.GetCounter(name)
+	if err != nil {
+		return err
+	}
+	return st.UpdateCounter(name, delta, ok)
+}
+
+func union[V int64 | float64](a, b map[string]V) map[string]V {
+	all := make(map[string]V, len(a))
+	for k, v := range a {
+		all[k] = v
+	}
+	for k, v := range b {
+		all[k] = v
+	}
+	return all
+}
+
+func sortedKeys[V int64 | float64](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func counterValue(v int64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	value := float64(v)
+	return &value
+}
+
+func gaugeValue(v float64, ok bool) *float64 {
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+func format(v *float64) string {
+	if v == nil {
+		return "missing"
+	}
+	return fmt.Sprintf("%g", *v)
+}
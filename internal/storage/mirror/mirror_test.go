@@ -0,0 +1,96 @@
+package mirror
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStorage struct {
+	*filememory.MemStorage
+}
+
+func (s failingStorage) UpdateGauge(string, float64) error {
+	return errors.New("unavailable")
+}
+
+func TestStorage(t *testing.T) {
+	logger.LogInit("info")
+	primary := filememory.NewMemStorage(false, nil)
+	secondary := filememory.NewMemStorage(false, nil)
+	s := New(primary, secondary)
+
+	require.NoError(t, s.UpdateCounter("PollCount", 2, false))
+	require.NoError(t, s.UpdateCounter("PollCount", 3, true))
+	require.NoError(t, s.UpdateGauge("Alloc", 1.5))
+
+	delta := int64(1)
+	assert.ErrorIs(
+		t,
+		s.InsertBatchMetrics([]f.Metric{{ID: "PollCount", MType: config.Counter, Delta: &delta}}),
+		filememory.ErrNotAllowed,
+	)
+
+	value, _, _ := secondary.GetCounter("PollCount")
+	assert.Equal(t, int64(5), value)
+	assert.Empty(t, s.Diff())
+
+	require.NoError(t, primary.UpdateGauge("Alloc", 2))
+	require.NoError(t, primary.UpdateGauge("HeapIdle", 3))
+	diffs := s.Diff()
+	require.Len(t, diffs, 2)
+	assert.Equal(t, "gauge Alloc: primary 2, secondary 1.5", diffs[0].String())
+	assert.Nil(t, diffs[1].Secondary)
+
+	failing := New(primary, failingStorage{secondary})
+	assert.NoError(t, failing.UpdateGauge("Alloc", 4))
+	assert.Equal(t, 1, failing.WriteErrors())
+}
+
+func TestCopy(t *testing.T) {
+	src := filememory.NewMemStorage(false, nil)
+	dst := filememory.NewMemStorage(false, nil)
+	require.NoError(t, src.UpdateCounter("PollCount", 7, false))
+	require.NoError(t, src.UpdateGauge("Alloc", 1.5))
+	require.NoError(t, dst.UpdateCounter("PollCount", 2, false))
+
+	n, err := Copy(dst, src)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Empty(t, Diff(src, dst))
+
+	n, err = Copy(dst, src)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	value, _, _ := dst.GetCounter("PollCount")
+	assert.Equal(t, int64(7), value)
+
+	require.NoError(t, src.UpdateGauge("Alloc", 2.5))
+	n, err = Copy(dst, src)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Empty(t, Diff(src, dst))
+}
+
+func TestCheckStops(t *testing.T) {
+	s := New(filememory.NewMemStorage(false, nil), filememory.NewMemStorage(false, nil))
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.Check(time.Millisecond, stop)
+		close(done)
+	}()
+	close(stop)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Check did not return after stop was closed")
+	}
+}
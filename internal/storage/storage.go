@@ -0,0 +1,217 @@
+// Package storage keeps a registry of metrics storage backends. Backends
+// register themselves under a URL scheme, and the server selects one with
+// a single storage URL such as memory://, file:///path or postgres://...
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+)
+
+var (
+	ErrUnknownScheme    = errors.New("unknown storage scheme")
+	ErrInvalidURL       = errors.New("invalid storage URL")
+	ErrBatchUnsupported = errors.New("batch insert not supported")
+	// ErrRejected matches the errors of metrics that are refused for good,
+	// such as a type conflict, as opposed to failures of the storage.
+	// Writing such a metric again fails the same way.
+	ErrRejected          = errors.New("metric rejected")
+	ErrUnsupportedMetric = Rejection("unsupported metric")
+)
+
+// Rejection returns an error with the given text that matches ErrRejected.
+func Rejection(text string) error {
+	return &rejection{text: text}
+}
+
+type rejection struct {
+	text string
+}
+
+func (e *rejection) Error() string {
+	return e.text
+}
+
+func (e *rejection) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Opener creates a storage for the given URL. cfg carries the server
+// settings that are not part of the URL, such as the store interval.
+type Opener func(u *url.URL, cfg *config.Server) (serviceInterface.MetricsStorage, error)
+
+// Partitioner is implemented by backends that can serve several tenants
+// from one connection.
+type Partitioner interface {
+	Partition(id string) serviceInterface.MetricsStorage
+}
+
+var (
+	mu      sync.RWMutex
+	openers = make(map[string]Opener)
+)
+
+// Register makes a backend available under the given URL schemes.
+// It panics if a scheme is registered twice.
+func Register(open Opener, schemes ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, scheme := range schemes {
+		if _, ok := openers[scheme]; ok {
+			panic("storage: scheme registered twice: " + scheme)
+		}
+		openers[scheme] = open
+	}
+}
+
+// Schemes returns the registered URL schemes in sorted order.
+func Schemes() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	schemes := make([]string, 0, len(openers))
+	for scheme := range openers {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Open creates the storage selected by the scheme of rawURL.
+func Open(rawURL string, cfg *config.Server) (serviceInterface.MetricsStorage, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	mu.RLock()
+	open, ok := openers[u.Scheme]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf(
+			"%w: %q, available: %s",
+			ErrUnknownScheme, u.Scheme, strings.Join(Schemes(), ", "),
+		)
+	}
+	return open(u, cfg)
+}
+
+// URL returns the storage URL configured for the server. When the storage
+// flag is not set it falls back to the database DSN and then to the backup file.
+func URL(cfg *config.Server) string {
+	switch {
+	case cfg.StorageURL != "":
+		return cfg.StorageURL
+	case cfg.DatabaseDSN != "":
+		return cfg.DatabaseDSN
+	case cfg.FileStoragePath != "":
+		return "file:" + cfg.FileStoragePath
+	default:
+		return "memory://"
+	}
+}
+
+// Path returns the file system path of a file-based storage URL. Both
+// file:///abs/path and the relative forms file:rel/path and file://rel/path
+// are accepted.
+func Path(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	return u.Host + u.Path
+}
+
+// Partition returns the storage of tenant id. Backends implementing
+// Partitioner share st; the others are opened again at a tenant-specific
+// path next to the one in rawURL.
+func Partition(
+	st serviceInterface.MetricsStorage,
+	rawURL string,
+	cfg *config.Server,
+	id string,
+	tenantPath func(path string, id string) string,
+) (serviceInterface.MetricsStorage, error) {
+	if p, ok := st.(Partitioner); ok {
+		return p.Partition(id), nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if path := Path(u); path != "" {
+		u = &url.URL{Scheme: u.Scheme, Path: tenantPath(path, id), RawQuery: u.RawQuery}
+		if !strings.HasPrefix(u.Path, "/") {
+			u = &url.URL{Scheme: u.Scheme, Opaque: tenantPath(path, id), RawQuery: u.RawQuery}
+		}
+	}
+	return Open(u.String(), cfg)
+}
+
+// InsertBatch inserts metrics with InsertBatchMetrics, or one by one when
+// the backend does not support batches. Listeners receiving metrics from
+// other protocols use it to write into any backend.
+func InsertBatch(st serviceInterface.MetricsStorage, metrics []f.Metric) error {
+	err := st.InsertBatchMetrics(metrics)
+	if !errors.Is(err, ErrBatchUnsupported) {
+		return err
+	}
+	for _, m := range metrics {
+		switch {
+		case m.MType == config.Counter && m.Delta != nil:
+			_, ok, err := st.GetCounter(m.ID)
+			if err != nil {
+				return err
+			}
+			if err = st.UpdateCounter(m.ID, *m.Delta, ok); err != nil {
+				return err
+			}
+		case m.MType == config.Gauge && m.Value != nil:
+			if err := st.UpdateGauge(m.ID, *m.Value); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: %s %s", ErrUnsupportedMetric, m.MType, m.ID)
+		}
+	}
+	return nil
+}
+
+// Failure is a metric that InsertEach could not write.
+type Failure struct {
+	Metric f.Metric
+	Err    error
+}
+
+// InsertEach inserts metrics as one batch and, when the backend does not
+// support batches or rejects a metric of it, one metric at a time, so that
+// a failing metric does not keep the others from being written. It returns
+// the metrics that were not written. When the batch fails for another
+// reason, none of them is.
+func InsertEach(st serviceInterface.MetricsStorage, metrics []f.Metric) []Failure {
+	if len(metrics) == 0 {
+		return nil
+	}
+	err := st.InsertBatchMetrics(metrics)
+	if err == nil {
+		return nil
+	}
+	var failures []Failure
+	if !errors.Is(err, ErrBatchUnsupported) && !errors.Is(err, ErrRejected) {
+		for _, m := range metrics {
+			failures = append(failures, Failure{Metric: m, Err: err})
+		}
+		return failures
+	}
+	for _, m := range metrics {
+		if err := InsertBatch(st, []f.Metric{m}); err != nil {
+			failures = append(failures, Failure{Metric: m, Err: err})
+		}
+	}
+	return failures
+}
@@ -0,0 +1,121 @@
+package storage_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	"github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	st, err := storage.Open("memory://", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &filememory.MemStorage{}, st)
+
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	st, err = storage.Open("file://"+path+"?interval=3600&restore=false", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &filememory.MemStorage{}, st)
+
+	_, err = storage.Open("redis://localhost", nil)
+	assert.ErrorIs(t, err, storage.ErrUnknownScheme)
+
+	_, err = storage.Open("file://"+path+"?interval=x", nil)
+	assert.Error(t, err)
+}
+
+func TestURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Server
+		expected string
+	}{
+		{
+			name:     "Storage Flag",
+			cfg:      config.Server{StorageURL: "bolt:///tmp/m.db", DatabaseDSN: "postgres://db"},
+			expected: "bolt:///tmp/m.db",
+		},
+		{
+			name:     "Database DSN",
+			cfg:      config.Server{DatabaseDSN: "postgres://db", FileStoragePath: "tmp/m.json"},
+			expected: "postgres://db",
+		},
+		{
+			name:     "Backup File",
+			cfg:      config.Server{FileStoragePath: "tmp/m.json"},
+			expected: "file:tmp/m.json",
+		},
+		{
+			name:     "Memory",
+			expected: "memory://",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(
+			tt.name, func(t *testing.T) {
+				assert.Equal(t, tt.expected, storage.URL(&tt.cfg))
+			},
+		)
+	}
+}
+
+func TestPartition(t *testing.T) {
+	dir := t.TempDir()
+	rawURL := "file://" + filepath.Join(dir, "metrics.json") + "?restore=false"
+	st, err := storage.Open(rawURL, nil)
+	require.NoError(t, err)
+
+	var used []string
+	tenantPath := func(path string, id string) string {
+		used = append(used, path)
+		return filepath.Join(filepath.Dir(path), id, filepath.Base(path))
+	}
+	partition, err := storage.Partition(st, rawURL, nil, "team-a", tenantPath)
+	require.NoError(t, err)
+	assert.NotSame(t, st, partition)
+	assert.Equal(t, []string{filepath.Join(dir, "metrics.json")}, used)
+}
+
+func TestInsertBatch(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	require.ErrorIs(t, st.InsertBatchMetrics(nil), storage.ErrBatchUnsupported)
+
+	delta, value := int64(3), 1.5
+	metrics := []formatter.Metric{
+		{ID: "PollCount", MType: config.Counter, Delta: &delta},
+		{ID: "Alloc", MType: config.Gauge, Value: &value},
+	}
+	require.NoError(t, storage.InsertBatch(st, metrics))
+	require.NoError(t, storage.InsertBatch(st, metrics))
+	counters, gauges := st.GetMetrics()
+	assert.Equal(t, map[string]int64{"PollCount": 6}, counters)
+	assert.Equal(t, map[string]float64{"Alloc": 1.5}, gauges)
+
+	err := storage.InsertBatch(st, []formatter.Metric{{ID: "x", MType: config.Gauge}})
+	assert.ErrorIs(t, err, storage.ErrUnsupportedMetric)
+}
+
+func TestInsertEach(t *testing.T) {
+	st := filememory.NewMemStorage(false, nil)
+	delta, value := int64(3), 1.5
+	metrics := []formatter.Metric{
+		{ID: "Invalid", MType: config.Gauge},
+		{ID: "PollCount", MType: config.Counter, Delta: &delta},
+		{ID: "Alloc", MType: config.Gauge, Value: &value},
+	}
+
+	failures := storage.InsertEach(st, metrics)
+	require.Len(t, failures, 1)
+	assert.Equal(t, "Invalid", failures[0].Metric.ID)
+	assert.ErrorIs(t, failures[0].Err, storage.ErrRejected)
+	assert.ErrorIs(t, failures[0].Err, storage.ErrUnsupportedMetric)
+
+	counters, gauges := st.GetMetrics()
+	assert.Equal(t, map[string]int64{"PollCount": 3}, counters)
+	assert.Equal(t, map[string]float64{"Alloc": 1.5}, gauges)
+}
@@ -0,0 +1,131 @@
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/middleware/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Header is the HTTP header (and gRPC metadata key) carrying the tenant id.
+const Header = "X-Tenant-ID"
+
+const contextKey = "tenant"
+
+type ctxKey struct{}
+
+// Middleware resolves the tenant of every request from the Authorization
+// bearer token or the X-Tenant-ID header and stores it in the gin context.
+func Middleware(r *Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.GetHeader("Authorization"))
+		id, err := r.Resolve(token, c.GetHeader(Header))
+		if err != nil {
+			logger.Log.Info(err.Error(), zap.String("path", c.Request.URL.Path))
+			c.AbortWithStatusJSON(httpStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.Set(contextKey, id)
+		c.Next()
+	}
+}
+
+// FromContext returns the tenant resolved by Middleware.
+func FromContext(c *gin.Context) string {
+	if id := c.GetString(contextKey); id != "" {
+		return id
+	}
+	return Default
+}
+
+// UnaryServerInterceptor resolves the tenant of every unary gRPC call from
+// the "authorization" or "x-tenant-id" metadata.
+func UnaryServerInterceptor(r *Registry) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		ctx, err := resolveIncoming(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is the streaming counterpart of UnaryServerInterceptor.
+func StreamServerInterceptor(r *Registry) grpc.StreamServerInterceptor {
+	return func(
+		srv interface{},
+		ss grpc.ServerStream,
+		info *grpc.StreamServerInfo,
+		handler grpc.StreamHandler,
+	) error {
+		ctx, err := resolveIncoming(ss.Context(), r)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &tenantStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// FromIncomingContext returns the tenant resolved by the gRPC interceptors.
+func FromIncomingContext(ctx context.Context) string {
+	if id, ok := ctx.Value(ctxKey{}).(string); ok && id != "" {
+		return id
+	}
+	return Default
+}
+
+type tenantStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tenantStream) Context() context.Context {
+	return s.ctx
+}
+
+func resolveIncoming(ctx context.Context, r *Registry) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id, err := r.Resolve(
+		bearerToken(firstValue(md, "authorization")),
+		firstValue(md, strings.ToLower(Header)),
+	)
+	if err != nil {
+		if errors.Is(err, ErrUnknownToken) {
+			return ctx, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return ctx, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return context.WithValue(ctx, ctxKey{}, id), nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func bearerToken(header string) string {
+	return strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+}
+
+// httpStatus maps tenant resolution errors to HTTP status codes.
+func httpStatus(err error) int {
+	if errors.Is(err, ErrUnknownToken) {
+		return http.StatusUnauthorized
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,291 @@
+// Package tenant partitions the server storage between several teams.
+// Every tenant gets its own MetricsStorage built by a factory, wrapped with
+// per-tenant limits on the number of series and on the ingest rate.
+package tenant
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/elina-chertova/metrics-alerting.git/internal/config"
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage"
+	"github.com/goccy/go-json"
+)
+
+// Default is the tenant used when a request carries no tenant information.
+const Default = "default"
+
+var (
+	ErrSeriesLimit   = storage.Rejection("tenant series limit exceeded")
+	ErrRateLimit     = errors.New("tenant ingest rate limit exceeded")
+	ErrUnknownToken  = errors.New("unknown tenant token")
+	ErrInvalidTenant = errors.New("invalid tenant id")
+)
+
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Limits describes the restrictions applied to every tenant.
+// Zero values mean no limit.
+type Limits struct {
+	MaxSeries int
+	MaxRate   float64
+}
+
+// Factory builds the storage of a single tenant.
+type Factory func(id string) (serviceInterface.MetricsStorage, error)
+
+// Registry keeps the storages of all known tenants and resolves
+// incoming credentials to a tenant id.
+type Registry struct {
+	mu       sync.Mutex
+	factory  Factory
+	limits   Limits
+	tokens   map[string]string
+	storages map[string]*Storage
+}
+
+// NewRegistry creates a Registry. When tokens is not empty every request
+// must present one of its keys, and the mapped value is used as the tenant id.
+func NewRegistry(factory Factory, limits Limits, tokens map[string]string) *Registry {
+	return &Registry{
+		factory:  factory,
+		limits:   limits,
+		tokens:   tokens,
+		storages: make(map[string]*Storage),
+	}
+}
+
+// Resolve returns the tenant id for the given API token and tenant header.
+func (r *Registry) Resolve(token string, header string) (string, error) {
+	if len(r.tokens) > 0 {
+		id, ok := r.tokens[token]
+		if !ok {
+			return "", ErrUnknownToken
+		}
+		return id, nil
+	}
+	if header == "" {
+		return Default, nil
+	}
+	if !validID.MatchString(header) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTenant, header)
+	}
+	return header, nil
+}
+
+// Storage returns the storage of the tenant, creating it on first use.
+func (r *Registry) Storage(id string) (serviceInterface.MetricsStorage, error) {
+	if id == "" {
+		id = Default
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.storages[id]
+	if !ok {
+		st, err := r.factory(id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open storage of tenant %s: %w", id, err)
+		}
+		s = NewStorage(st, r.limits)
+		r.storages[id] = s
+	}
+	return s, nil
+}
+
+// Tenants returns the ids of all tenants that have been used so far.
+func (r *Registry) Tenants() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ids := make([]string, 0, len(r.storages))
+	for id := range r.storages {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// LoadTokens reads a JSON object mapping API tokens to tenant ids.
+func LoadTokens(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(map[string]string)
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, err
+	}
+	for token, id := range tokens {
+		if !validID.MatchString(id) {
+			return nil, fmt.Errorf("%w: %q for token %q", ErrInvalidTenant, id, token)
+		}
+	}
+	return tokens, nil
+}
+
+// FilePath returns the backup file of the tenant. The default tenant keeps
+// the configured path, others get a sub-directory named after the tenant.
+func FilePath(path string, id string) string {
+	if id == Default || path == "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(path), id, filepath.Base(path))
+}
+
+// Storage wraps a tenant's MetricsStorage and enforces its Limits.
+type Storage struct {
+	serviceInterface.MetricsStorage
+	limits Limits
+
+	mu       sync.Mutex
+	series   map[string]struct{}
+	reserved int
+	tokens   float64
+	last     time.Time
+}
+
+// NewStorage wraps st with the given limits.
+func NewStorage(st serviceInterface.MetricsStorage, limits Limits) *Storage {
+	return &Storage{
+		MetricsStorage: st,
+		limits:         limits,
+		tokens:         math.Max(limits.MaxRate, 1),
+		last:           time.Now(),
+	}
+}
+
+// Unwrap returns the wrapped storage.
+func (s *Storage) Unwrap() serviceInterface.MetricsStorage {
+	return s.MetricsStorage
+}
+
+// UpdateCounter updates a counter if the tenant limits allow it.
+func (s *Storage) UpdateCounter(name string, value int64, ok bool) error {
+	return s.write(
+		[]string{seriesKey(config.Counter, name)}, func() error {
+			return s.MetricsStorage.UpdateCounter(name, value, ok)
+		},
+	)
+}
+
+// UpdateGauge updates a gauge if the tenant limits allow it.
+func (s *Storage) UpdateGauge(name string, value float64) error {
+	return s.write(
+		[]string{seriesKey(config.Gauge, name)}, func() error {
+			return s.MetricsStorage.UpdateGauge(name, value)
+		},
+	)
+}
+
+// InsertBatchMetrics inserts the batch if the tenant limits allow all of it.
+func (s *Storage) InsertBatchMetrics(metrics []f.Metric) error {
+	keys := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		keys = append(keys, seriesKey(m.MType, m.ID))
+	}
+	return s.write(
+		keys, func() error {
+			return s.MetricsStorage.InsertBatchMetrics(metrics)
+		},
+	)
+}
+
+// write calls fn if the limits admit the series keys and records their new
+// series once fn succeeded.
+func (s *Storage) write(keys []string, fn func() error) error {
+	fresh, err := s.admit(keys)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	s.settle(fresh, err == nil)
+	return err
+}
+
+// admit checks the rate and series limits for the given series keys and
+// returns the keys of new series, which stay reserved until settle.
+//
+// The rate bucket holds one second of updates. A batch larger than that is
+// admitted when the bucket is full and leaves it in debt, which the
+// following seconds pay off, so agents and flushes sending more metrics
+// per request than the rate are slowed down instead of always rejected.
+func (s *Storage) admit(keys []string) (map[string]struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.limits.MaxRate > 0 {
+		burst := s.burst()
+		now := time.Now()
+		s.tokens = math.Min(s.tokens+now.Sub(s.last).Seconds()*s.limits.MaxRate, burst)
+		s.last = now
+		if s.tokens < math.Min(float64(len(keys)), burst) {
+			return nil, ErrRateLimit
+		}
+	}
+
+	var fresh map[string]struct{}
+	if s.limits.MaxSeries > 0 {
+		if s.series == nil {
+			s.loadSeries()
+		}
+		fresh = make(map[string]struct{})
+		for _, k := range keys {
+			if _, ok := s.series[k]; !ok {
+				fresh[k] = struct{}{}
+			}
+		}
+		if len(s.series)+s.reserved+len(fresh) > s.limits.MaxSeries {
+			return nil, ErrSeriesLimit
+		}
+		s.reserved += len(fresh)
+	}
+
+	if s.limits.MaxRate > 0 {
+		s.tokens -= float64(len(keys))
+	}
+	return fresh, nil
+}
+
+// settle releases the series reserved by admit and records them when the
+// write succeeded.
+func (s *Storage) settle(fresh map[string]struct{}, written bool) {
+	if len(fresh) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reserved -= len(fresh)
+	if written {
+		for k := range fresh {
+			s.series[k] = struct{}{}
+		}
+	}
+}
+
+// burst returns the capacity of the rate bucket: one second of updates,
+// but at least one.
+func (s *Storage) burst() float64 {
+	return math.Max(s.limits.MaxRate, 1)
+}
+
+// loadSeries seeds the set of known series from the underlying storage.
+func (s *Storage) loadSeries() {
+	s.series = make(map[string]struct{})
+	counter, gauge := s.MetricsStorage.GetMetrics()
+	for name := range counter {
+		s.series[seriesKey(config.Counter, name)] = struct{}{}
+	}
+	for name := range gauge {
+		s.series[seriesKey(config.Gauge, name)] = struct{}{}
+	}
+}
+
+func seriesKey(metricType string, name string) string {
+	return metricType + ":" + name
+}
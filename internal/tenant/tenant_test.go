@@ -0,0 +1,176 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	f "github.com/elina-chertova/metrics-alerting.git/internal/formatter"
+	serviceInterface "github.com/elina-chertova/metrics-alerting.git/internal/handlers"
+	"github.com/elina-chertova/metrics-alerting.git/internal/storage/filememory"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMemRegistry(limits Limits, tokens map[string]string) *Registry {
+	return NewRegistry(
+		func(id string) (serviceInterface.MetricsStorage, error) {
+			return filememory.NewMemStorage(false, nil), nil
+		}, limits, tokens,
+	)
+}
+
+func TestRegistryIsolatesTenants(t *testing.T) {
+	r := newMemRegistry(Limits{}, nil)
+
+	teamA, err := r.Storage("team-a")
+	assert.NoError(t, err)
+	teamB, err := r.Storage("team-b")
+	assert.NoError(t, err)
+
+	assert.NoError(t, teamA.UpdateGauge("Alloc", 1.5))
+
+	_, ok, _ := teamB.GetGauge("Alloc")
+	assert.False(t, ok)
+
+	value, ok, _ := teamA.GetGauge("Alloc")
+	assert.True(t, ok)
+	assert.Equal(t, 1.5, value)
+	assert.ElementsMatch(t, []string{"team-a", "team-b"}, r.Tenants())
+}
+
+func TestResolve(t *testing.T) {
+	r := newMemRegistry(Limits{}, nil)
+
+	id, err := r.Resolve("", "")
+	assert.NoError(t, err)
+	assert.Equal(t, Default, id)
+
+	id, err = r.Resolve("", "team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", id)
+
+	_, err = r.Resolve("", "../etc")
+	assert.ErrorIs(t, err, ErrInvalidTenant)
+
+	withTokens := newMemRegistry(Limits{}, map[string]string{"secret": "team-a"})
+	id, err = withTokens.Resolve("secret", "team-b")
+	assert.NoError(t, err)
+	assert.Equal(t, "team-a", id)
+
+	_, err = withTokens.Resolve("", "team-b")
+	assert.ErrorIs(t, err, ErrUnknownToken)
+}
+
+func TestSeriesLimit(t *testing.T) {
+	s := NewStorage(filememory.NewMemStorage(false, nil), Limits{MaxSeries: 2})
+
+	assert.NoError(t, s.UpdateGauge("a", 1))
+	assert.NoError(t, s.UpdateCounter("b", 1, false))
+	assert.NoError(t, s.UpdateGauge("a", 2))
+	assert.ErrorIs(t, s.UpdateGauge("c", 1), ErrSeriesLimit)
+
+	value := 1.0
+	err := s.InsertBatchMetrics([]f.Metric{{ID: "d", MType: "gauge", Value: &value}})
+	assert.True(t, errors.Is(err, ErrSeriesLimit))
+}
+
+func TestRateLimit(t *testing.T) {
+	s := NewStorage(filememory.NewMemStorage(false, nil), Limits{MaxRate: 2})
+
+	assert.NoError(t, s.UpdateGauge("a", 1))
+	assert.NoError(t, s.UpdateGauge("a", 2))
+	assert.ErrorIs(t, s.UpdateGauge("a", 3), ErrRateLimit)
+}
+
+// batchStorage adds a batch insert to MemStorage that fails for metrics
+// named "fail".
+type batchStorage struct {
+	*filememory.MemStorage
+}
+
+func (s batchStorage) InsertBatchMetrics(metrics []f.Metric) error {
+	for _, m := range metrics {
+		if m.ID == "fail" {
+			return errors.New("write failed")
+		}
+	}
+	for _, m := range metrics {
+		if err := s.UpdateGauge(m.ID, *m.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func gauges(ids ...string) []f.Metric {
+	metrics := make([]f.Metric, 0, len(ids))
+	for _, id := range ids {
+		value := 1.0
+		metrics = append(metrics, f.Metric{ID: id, MType: "gauge", Value: &value})
+	}
+	return metrics
+}
+
+func TestRateLimitBatch(t *testing.T) {
+	s := NewStorage(batchStorage{filememory.NewMemStorage(false, nil)}, Limits{MaxRate: 2})
+
+	// A full bucket admits a batch larger than the rate and goes into debt.
+	assert.NoError(t, s.InsertBatchMetrics(gauges("a", "b", "c")))
+	assert.ErrorIs(t, s.InsertBatchMetrics(gauges("a")), ErrRateLimit)
+	assert.ErrorIs(t, s.InsertBatchMetrics(gauges("a", "b", "c")), ErrRateLimit)
+
+	s.tokens = 1
+	assert.ErrorIs(t, s.InsertBatchMetrics(gauges("a", "b")), ErrRateLimit)
+	assert.NoError(t, s.InsertBatchMetrics(gauges("a")))
+}
+
+func TestSeriesLimitRepeatedKeys(t *testing.T) {
+	s := NewStorage(batchStorage{filememory.NewMemStorage(false, nil)}, Limits{MaxSeries: 2})
+
+	assert.NoError(t, s.InsertBatchMetrics(gauges("a", "a", "b")))
+	assert.ErrorIs(t, s.UpdateGauge("c", 1), ErrSeriesLimit)
+}
+
+func TestSeriesRecordedAfterWrite(t *testing.T) {
+	s := NewStorage(batchStorage{filememory.NewMemStorage(false, nil)}, Limits{MaxSeries: 2})
+
+	assert.Error(t, s.InsertBatchMetrics(gauges("fail", "b")))
+	assert.NoError(t, s.InsertBatchMetrics(gauges("a", "b")))
+	assert.ErrorIs(t, s.UpdateGauge("c", 1), ErrSeriesLimit)
+	assert.Zero(t, s.reserved)
+}
+
+func TestFilePath(t *testing.T) {
+	path := filepath.Join("tmp", "metrics-db.json")
+
+	assert.Equal(t, path, FilePath(path, Default))
+	assert.Equal(t, filepath.Join("tmp", "team-a", "metrics-db.json"), FilePath(path, "team-a"))
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Middleware(newMemRegistry(Limits{}, map[string]string{"secret": "team-a"})))
+	router.GET(
+		"/test", func(c *gin.Context) {
+			c.String(http.StatusOK, FromContext(c))
+		},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "team-a", w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}